@@ -31,9 +31,15 @@ resource "kubernetes_config_map" "ingestor_config_map" {
   }
 }
 
-resource "kubernetes_manifest" "ingestor_deployment" {
+resource "kubernetes_manifest" "ingestor_state" {
   provider = kubernetes
-  manifest = yamldecode(file("./../../k8s/ingestor/ingestor-deployment.yaml"))
+  manifest = yamldecode(file("./../../k8s/ingestor/ingestor-state-pvc.yaml"))
+}
+
+resource "kubernetes_manifest" "ingestor_deployment" {
+  provider   = kubernetes
+  manifest   = yamldecode(file("./../../k8s/ingestor/ingestor-deployment.yaml"))
+  depends_on = [kubernetes_manifest.ingestor_state]
 }
 
 resource "kubernetes_manifest" "ingestor_service" {
@@ -55,6 +61,12 @@ resource "kubernetes_manifest" "persistor_deployment" {
   depends_on = [kubernetes_manifest.ingestor_service]
 }
 
+resource "kubernetes_manifest" "persistor_service" {
+  provider   = kubernetes
+  manifest   = yamldecode(file("./../../k8s/persistor/persistor-service.yaml"))
+  depends_on = [kubernetes_manifest.persistor_deployment]
+}
+
 resource "kubernetes_secret" "persistor_secrets" {
   metadata {
     name      = "persistor-secrets"
@@ -77,6 +89,9 @@ resource "kubernetes_config_map" "persistor_config_map" {
     app_name : "binance-trading-chart-service/persistor"
     app_env : var.app_env
     app_debug : var.app_debug
+    app_grpc_port : var.persistor_grpc_port
+    app_http_port : var.persistor_http_port
+    db_migrate_on_start : "true"
     server_address : format("ingestor-service.default.svc.cluster.local:%s", var.app_grpc_port)
   }
 }
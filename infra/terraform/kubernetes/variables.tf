@@ -48,6 +48,18 @@ variable "app_grpc_port" {
   default     = "50051"
 }
 
+variable "persistor_grpc_port" {
+  type        = string
+  description = "Persistor candle history gRPC port"
+  default     = "50052"
+}
+
+variable "persistor_http_port" {
+  type        = string
+  description = "Persistor candle history HTTP port"
+  default     = "8080"
+}
+
 variable "app_env" {
   type        = string
   description = "Environment"
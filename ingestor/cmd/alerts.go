@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/metrics"
+	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/alerts"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/webhook"
+)
+
+// alertDispatcher evaluates the alert rules on the trades and candlesticks, and delivers their firings to the
+// alert streams and to the webhooks of their rules.
+type alertDispatcher struct {
+	evaluator *alerts.Evaluator
+	sender    *webhook.Sender
+	server    *aggregator.AlertServer
+}
+
+// newAlertDispatcher loads the saved alert rules. The synthetic symbols have no volume to spike.
+func newAlertDispatcher(cfg *config.AppConfig, synthetics []string) (*alertDispatcher, error) {
+	evaluator := alerts.NewEvaluator(alerts.WithStore(alerts.NewFileStore(cfg.Alerts.RulesFile)),
+		alerts.WithMaxRules(cfg.Alerts.MaxRules), alerts.WithoutVolume(synthetics...))
+
+	loaded, err := evaluator.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := cfg.WebhookAllowedNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("alerts enabled", "rules", loaded, "path", cfg.Alerts.RulesFile)
+
+	return &alertDispatcher{
+		evaluator: evaluator,
+		sender: webhook.NewSender(
+			webhook.WithTimeout(cfg.Alerts.WebhookTimeout),
+			webhook.WithAllowedNetworks(allowed...),
+			webhook.WithRetries(cfg.Alerts.WebhookRetries, cfg.Alerts.WebhookBackoff),
+			webhook.WithResults(func(result string) {
+				metrics.WebhookDeliveries.WithLabelValues(result).Inc()
+			}),
+		),
+		server: aggregator.NewAlertServer(evaluator),
+	}, nil
+}
+
+// trade evaluates the rules of the trade's symbol on it.
+func (d *alertDispatcher) trade(tick binance.TradeData) {
+	price, err := strconv.ParseFloat(tick.Price, 64)
+	if err != nil {
+		// Reported by the aggregator.
+		return
+	}
+
+	d.dispatch(d.evaluator.Trade(tick.Symbol, time.UnixMilli(tick.TradeTime).UTC(), price))
+}
+
+// candle evaluates the rules of the candlestick's symbol on it.
+func (d *alertDispatcher) candle(candle *aggregatorsvc.Candlestick) {
+	d.dispatch(d.evaluator.Candle(candle))
+}
+
+func (d *alertDispatcher) dispatch(firings []alerts.Firing) {
+	for _, firing := range firings {
+		metrics.AlertsFired.WithLabelValues(firing.Rule.Symbol).Inc()
+		logger.Info("alert fired", "alert_id", firing.ID, "rule_id", firing.Rule.ID, "message", firing.Message)
+
+		d.server.Publish(firing)
+
+		if firing.Rule.WebhookURL != "" {
+			secret := []byte(firing.Rule.WebhookSecret)
+			// The receiver holds the secret, which must not travel with the payload.
+			firing.Rule.WebhookSecret = ""
+
+			d.sender.Send(webhook.Delivery{URL: firing.Rule.WebhookURL, ID: firing.ID, Secret: secret, Payload: firing})
+		}
+	}
+}
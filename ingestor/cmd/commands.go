@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
+)
+
+const usage = `usage: ingestor [command]
+
+Without a command the ingestor aggregates the Binance trades into candles and streams them over gRPC.
+
+Commands:
+  config check   print the effective configuration with secrets redacted, and validate it`
+
+// runCommand runs the command of args, given the configuration and the error loading it.
+func runCommand(cfg *config.AppConfig, loadErr error, args []string) error {
+	switch args[0] {
+	case "config":
+		return runConfig(cfg, loadErr, args[1:])
+	case "help", "-h", "--help":
+		_, err := fmt.Fprintln(os.Stdout, usage)
+
+		return err
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
+
+// runConfig prints the effective configuration, then fails when it is invalid.
+func runConfig(cfg *config.AppConfig, loadErr error, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errors.New("usage: ingestor config check")
+	}
+
+	if err := cfg.Write(os.Stdout); err != nil {
+		return err
+	}
+
+	if loadErr != nil {
+		_, _ = fmt.Fprintln(os.Stderr, loadErr)
+
+		return errors.New("configuration is invalid")
+	}
+
+	return nil
+}
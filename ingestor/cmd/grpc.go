@@ -1,25 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
 	"net"
 
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
 	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
-	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/services/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/limits"
+	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
 	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/api/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 type options struct {
 	candlestickChan chan *aggregatorsvc.Candlestick
+	indicators      *indicators.Engine
+	observe         func(candle *aggregatorsvc.Candlestick)
+	alertServer     *aggregator.AlertServer
+	tlsConfig       *tls.Config
+	authenticator   *auth.Authenticator
+	limiter         *limits.Limiter
+	healthServer    *health.Server
+	reflection      bool
 }
 
 type Option func(o *options)
 
 type ServerWrapper struct {
-	grpcServer *grpc.Server
-	options    *options
+	grpcServer       *grpc.Server
+	aggregatorServer *aggregator.Server
+	options          *options
 }
 
 func NewGrpcServer(opts ...Option) *ServerWrapper {
@@ -29,10 +47,38 @@ func NewGrpcServer(opts ...Option) *ServerWrapper {
 		o(&opt)
 	}
 
-	return &ServerWrapper{
-		grpcServer: grpc.NewServer(),
+	var serverOpts []grpc.ServerOption
+
+	if opt.tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opt.tlsConfig)))
+	}
+
+	// Limits apply after authentication, so they count per identity.
+	if opt.authenticator != nil {
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(opt.authenticator.UnaryServerInterceptor()),
+			grpc.ChainStreamInterceptor(opt.authenticator.StreamServerInterceptor()),
+		)
+	}
+
+	if opt.limiter != nil {
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(opt.limiter.UnaryServerInterceptor()),
+			grpc.ChainStreamInterceptor(opt.limiter.StreamServerInterceptor()),
+		)
+	}
+
+	wrapper := &ServerWrapper{
+		grpcServer: grpc.NewServer(serverOpts...),
 		options:    &opt,
 	}
+
+	if opt.candlestickChan != nil {
+		wrapper.aggregatorServer = aggregator.NewServer(opt.candlestickChan, aggregator.WithIndicators(opt.indicators),
+			aggregator.WithCandleObserver(opt.observe))
+	}
+
+	return wrapper
 }
 
 func WithCandlestickChan(candlestickChan chan *aggregatorsvc.Candlestick) Option {
@@ -41,27 +87,163 @@ func WithCandlestickChan(candlestickChan chan *aggregatorsvc.Candlestick) Option
 	}
 }
 
+// WithIndicators computes the indicators candlestick streams request with engine.
+func WithIndicators(engine *indicators.Engine) Option {
+	return func(o *options) {
+		o.indicators = engine
+	}
+}
+
+// WithCandleObserver calls observe with every completed candlestick, see aggregator.WithCandleObserver.
+func WithCandleObserver(observe func(candle *aggregatorsvc.Candlestick)) Option {
+	return func(o *options) {
+		o.observe = observe
+	}
+}
+
+// WithAlertServer serves the alert rules API and alert streams of alertServer.
+func WithAlertServer(alertServer *aggregator.AlertServer) Option {
+	return func(o *options) {
+		o.alertServer = alertServer
+	}
+}
+
+// WithTLSConfig serves over TLS, the config decides whether client certificates are required.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithAuthenticator rejects calls without a valid API key or JWT allowed to use the RPC.
+func WithAuthenticator(authenticator *auth.Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithLimiter rejects calls over the per-client and total limits with RESOURCE_EXHAUSTED.
+func WithLimiter(limiter *limits.Limiter) Option {
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
+
+// WithHealthServer serves grpc.health.v1, open to unauthenticated callers so probes need no credentials.
+func WithHealthServer(healthServer *health.Server) Option {
+	return func(o *options) {
+		o.healthServer = healthServer
+	}
+}
+
+// WithReflection serves the gRPC reflection service, so tools like grpcurl work without the protos.
+func WithReflection(enabled bool) Option {
+	return func(o *options) {
+		o.reflection = enabled
+	}
+}
+
 func (s *ServerWrapper) StartGRPCServer(port uint16) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return fmt.Errorf("failed to serve: %w", err)
 	}
 
-	if s.options.candlestickChan != nil {
-		aggregatorpb.RegisterAggregatorServiceServer(s.grpcServer, aggregator.NewServer(s.options.candlestickChan))
+	if s.aggregatorServer != nil {
+		aggregatorpb.RegisterAggregatorServiceServer(s.grpcServer, s.aggregatorServer)
+	}
+
+	if s.options.alertServer != nil {
+		aggregatorpb.RegisterAlertServiceServer(s.grpcServer, s.options.alertServer)
+	}
+
+	if s.options.healthServer != nil {
+		healthpb.RegisterHealthServer(s.grpcServer, s.options.healthServer)
+	}
+
+	if s.options.reflection {
+		reflection.Register(s.grpcServer)
 	}
 
+	logger.Info("gRPC serving", "port", port)
+
 	if err := s.grpcServer.Serve(lis); err != nil {
 		return fmt.Errorf("failed to serve gRPC: %w", err)
 	}
 
-	log.Printf("gRPC serving on port :%d", port)
-
 	return nil
 }
 
-func (s *ServerWrapper) GracefulStop() {
-	log.Println("stopping gRPC server gracefully...")
-	s.grpcServer.GracefulStop()
-	log.Println("gRPC server stopped.")
+// Subscribers returns the connected candlestick streams, none without WithCandlestickChan.
+func (s *ServerWrapper) Subscribers() []aggregator.Subscriber {
+	if s.aggregatorServer == nil {
+		return []aggregator.Subscriber{}
+	}
+
+	return s.aggregatorServer.Subscribers()
+}
+
+// NotifySymbolsChanged tells the candlestick streams about change, see aggregator.Server.NotifySymbolsChanged.
+func (s *ServerWrapper) NotifySymbolsChanged(change aggregator.SymbolsChange) {
+	if s.aggregatorServer != nil {
+		s.aggregatorServer.NotifySymbolsChanged(change)
+	}
+}
+
+// Shutdown stops the gRPC server gracefully, waiting for the streams to end, and closes the streams still open
+// when ctx is done. Candlestick streams end once the candlestick channel is closed and they sent what they
+// buffered, alert streams right away.
+func (s *ServerWrapper) Shutdown(ctx context.Context) {
+	logger.Info("stopping gRPC server gracefully")
+
+	if s.options.alertServer != nil {
+		s.options.alertServer.Close()
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		logger.Info("gRPC server stopped")
+	case <-ctx.Done():
+		logger.Warn("gRPC streams not drained in time, closing them", "subscribers", len(s.Subscribers()))
+		s.grpcServer.Stop()
+		<-stopped
+	}
+}
+
+func newAuthenticator(cfg *config.AppConfig) (*auth.Authenticator, error) {
+	policy, err := auth.LoadPolicy(cfg.GrpcAuth.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []auth.Option
+
+	if cfg.GrpcAuth.JWTHMACKeyFile != "" {
+		key, err := auth.LoadHMACKey(cfg.GrpcAuth.JWTHMACKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, auth.WithHMACKey(key))
+	}
+
+	if cfg.GrpcAuth.JWTRSAPublicKeyFile != "" {
+		key, err := auth.LoadRSAPublicKey(cfg.GrpcAuth.JWTRSAPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, auth.WithRSAPublicKey(key))
+	}
+
+	logger.Info("gRPC authentication enabled", "identities", len(policy.Identities))
+
+	return auth.NewAuthenticator(policy, opts...), nil
 }
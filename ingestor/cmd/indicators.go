@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+// warmupPageSize is the most candles the persistor returns per page.
+const warmupPageSize = 1000
+
+// indicatorWarmer seeds the indicator engine with the closes of the 1m candles the persistor stored, so the
+// indicators of a symbol are ready with its first candle instead of once enough candles were streamed.
+type indicatorWarmer struct {
+	client  *http.Client
+	baseURL string
+	engine  *indicators.Engine
+	history int
+	timeout time.Duration
+}
+
+// warm seeds the 1m series of symbols, logging the ones that failed. Series already fed by live candles are
+// left as they are.
+func (w *indicatorWarmer) warm(ctx context.Context, symbols ...string) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	for _, symbol := range symbols {
+		closes, err := w.closes(ctx, symbol)
+		if err != nil {
+			logger.Warn("failed to warm up indicators", logging.KeySymbol, symbol, logging.Err(err))
+
+			continue
+		}
+
+		w.engine.Warm(indicators.Key{Symbol: symbol, Interval: "1m"}, closes)
+		logger.Info("warmed up indicators", logging.KeySymbol, symbol, "closes", len(closes))
+	}
+}
+
+// closes returns the closes of the last history 1m candles of symbol stored by the persistor, oldest first.
+func (w *indicatorWarmer) closes(ctx context.Context, symbol string) ([]float64, error) {
+	to := time.Now().UTC().Truncate(time.Minute)
+	params := url.Values{
+		"symbol":     {symbol},
+		"interval":   {"1m"},
+		"start_time": {to.Add(-time.Duration(w.history) * time.Minute).Format(time.RFC3339)},
+		"end_time":   {to.Format(time.RFC3339)},
+		"page_size":  {strconv.Itoa(min(w.history, warmupPageSize))},
+	}
+
+	var closes []float64
+
+	for {
+		page, err := w.page(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, candle := range page.Candles {
+			closes = append(closes, candle.Close)
+		}
+
+		if page.NextPageToken == "" {
+			return closes, nil
+		}
+
+		params.Set("page_token", page.NextPageToken)
+	}
+}
+
+// candlesPage is the part of the persistor's ListCandlesResponse the warm-up reads.
+type candlesPage struct {
+	Candles []struct {
+		Close float64 `json:"close"`
+	} `json:"candles"`
+	NextPageToken string `json:"next_page_token"`
+	Error         string `json:"error"`
+}
+
+func (w *indicatorWarmer) page(ctx context.Context, params url.Values) (candlesPage, error) {
+	endpoint := strings.TrimSuffix(w.baseURL, "/") + "/v1/candles?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return candlesPage{}, fmt.Errorf("failed to create candles request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return candlesPage{}, fmt.Errorf("failed to request candles: %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var page candlesPage
+
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return candlesPage{}, fmt.Errorf("failed to decode candles, status %d: %w", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return candlesPage{}, fmt.Errorf("failed to list candles, status %d: %s", resp.StatusCode, page.Error)
+	}
+
+	return page, nil
+}
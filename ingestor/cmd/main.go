@@ -2,47 +2,186 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
-	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/clients/binance"
-	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/services/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/admin"
+	feedhealth "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/health"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/limits"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/metrics"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/reload"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/api/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tlsconfig"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/health"
 )
 
+const (
+	// feedCheckInterval is how often the health of the Binance feed is re-evaluated.
+	feedCheckInterval = 5 * time.Second
+	tracerName        = "github.com/majidmvulle/binance-trading-chart-service/ingestor/cmd"
+)
+
+var logger = logging.Component("main")
+
+// version is reported by the admin server, set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 //nolint:funlen
 func main() {
-	cfg := config.Config()
+	cfg, loadErr := config.Load()
+
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, loadErr, os.Args[1:]); err != nil {
+			logging.Fatal(logger, "command failed", "command", os.Args[1], logging.Err(err))
+		}
+
+		return
+	}
+
+	if loadErr != nil {
+		logging.Fatal(logger, "failed to load configuration", logging.Err(loadErr))
+	}
+
+	if err := logging.Setup(logging.Config{
+		Format:           cfg.Log.Format,
+		Level:            cfg.Log.Level,
+		ComponentLevels:  cfg.Log.ComponentLevels,
+		SampleInitial:    cfg.Log.SampleInitial,
+		SampleThereafter: cfg.Log.SampleThereafter,
+	}); err != nil {
+		logging.Fatal(logger, "failed to set up logging", logging.Err(err))
+	}
+
 	client := binance.NewClient(&binance.Config{
 		WebsocketBaseURL: cfg.Binance.WebsocketBaseURL,
 		Symbols:          cfg.Binance.Symbols,
+		OnConnect:        metrics.WebsocketConnects.Inc,
+		OnParseError:     metrics.ParseErrors.Inc,
 	})
-	aggregatorSvc := aggregator.NewAggregator()
-	grpcServer := NewGrpcServer(WithCandlestickChan(aggregatorSvc.CandlestickChan))
 
-	if err := client.Connect(); err != nil {
-		log.Fatalf("failed to connect to Binance WebSocket: %v", err)
+	barSpecs, err := cfg.BarSpecs()
+	if err != nil {
+		logging.Fatal(logger, "invalid bars", logging.Err(err))
+	}
+
+	footprintSpecs, err := cfg.FootprintSpecs()
+	if err != nil {
+		logging.Fatal(logger, "invalid footprints", logging.Err(err))
 	}
 
-	defer func(client *binance.Client) {
-		err := client.Close()
+	synthetics, err := newSynthetics(cfg)
+	if err != nil {
+		logging.Fatal(logger, "invalid synthetic symbols", logging.Err(err))
+	}
+
+	aggregatorSvc := aggregator.NewAggregator(aggregator.WithBars(barSpecs...),
+		aggregator.WithFootprints(footprintSpecs...), aggregator.WithValueArea(cfg.Aggregator.ValueArea),
+		aggregator.WithoutVolume(synthetics.Symbols()...))
+	indicatorEngine := indicators.NewEngine(cfg.Indicators.History)
+	grpcOpts := []Option{WithCandlestickChan(aggregatorSvc.CandlestickChan), WithIndicators(indicatorEngine)}
+
+	var alertsDispatcher *alertDispatcher
+
+	if cfg.Alerts.Enabled {
+		alertsDispatcher, err = newAlertDispatcher(cfg, synthetics.Symbols())
 		if err != nil {
-			log.Fatalf("failed to close Binance WebSocket: %v", err)
+			logging.Fatal(logger, "failed to set up alerts", logging.Err(err))
 		}
-	}(client)
 
-	tradeChan := make(chan binance.TradeData)
+		grpcOpts = append(grpcOpts, WithAlertServer(alertsDispatcher.server),
+			WithCandleObserver(alertsDispatcher.candle))
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName:  cfg.App.Name,
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logging.Fatal(logger, "failed to set up tracing", logging.Err(err))
+	}
+
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", logging.Err(err))
+		}
+	}()
+
+	if alertsDispatcher != nil {
+		go alertsDispatcher.sender.Run(ctx)
+	}
+
+	if cfg.GrpcTLS.CertFile != "" {
+		certs, err := tlsconfig.NewReloader(tlsconfig.Files{
+			CertFile: cfg.GrpcTLS.CertFile,
+			KeyFile:  cfg.GrpcTLS.KeyFile,
+			CAFile:   cfg.GrpcTLS.ClientCAFile,
+		})
+		if err != nil {
+			logging.Fatal(logger, "failed to load gRPC TLS certificates", logging.Err(err))
+		}
+
+		go certs.Watch(ctx, cfg.GrpcTLS.ReloadInterval)
+
+		grpcOpts = append(grpcOpts, WithTLSConfig(certs.ServerConfig()))
+		logger.Info("gRPC TLS enabled", "client_certificates_required", cfg.GrpcTLS.ClientCAFile != "")
+	}
+
+	if cfg.GrpcAuth.Enabled {
+		authenticator, err := newAuthenticator(cfg)
+		if err != nil {
+			logging.Fatal(logger, "failed to configure gRPC authentication", logging.Err(err))
+		}
+
+		grpcOpts = append(grpcOpts, WithAuthenticator(authenticator))
+	}
+
+	grpcOpts = append(grpcOpts, WithLimiter(limits.NewLimiter(limits.Config{
+		MaxStreamsPerClient: cfg.GrpcLimits.StreamsPerClient,
+		MaxStreams:          cfg.GrpcLimits.Streams,
+		UnaryRate:           cfg.GrpcLimits.UnaryRate,
+		UnaryBurst:          cfg.GrpcLimits.UnaryBurst,
+		StreamRetryAfter:    cfg.GrpcLimits.StreamRetryAfter,
+	})))
+
+	healthServer := health.NewServer()
+	feed := feedhealth.NewFeedMonitor(healthServer, cfg.GrpcHealth.FeedStaleAfter,
+		aggregatorpb.AggregatorService_ServiceDesc.ServiceName)
+
+	go feed.Run(ctx, feedCheckInterval)
+
+	grpcOpts = append(grpcOpts, WithHealthServer(healthServer), WithReflection(cfg.GrpcHealth.Reflection))
+
+	grpcServer := NewGrpcServer(grpcOpts...)
+
+	if err := client.Connect(); err != nil {
+		logging.Fatal(logger, "failed to connect to Binance WebSocket", logging.Err(err))
+	}
+
+	tradeChan := make(chan binance.TradeData)
+
 	go func() {
-		if err := client.ReadAggregatedTicks(ctx, tradeChan); err != nil {
-			log.Printf("error reading aggregated trades: %v", err)
+		if err := client.ReadAggregatedTicks(ctx, tradeChan); err != nil && ctx.Err() == nil {
+			logger.Error("error reading aggregated trades", logging.Err(err))
 			cancel()
 		}
 	}()
@@ -51,45 +190,186 @@ func main() {
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		if err := grpcServer.StartGRPCServer(cfg.App.GrpcPort); err != nil {
-			log.Fatalf("failed to start gRPC server: %v", err)
+		//nolint:gosec // Validate checks the range.
+		if err := grpcServer.StartGRPCServer(uint16(cfg.App.GrpcPort)); err != nil {
+			logging.Fatal(logger, "failed to start gRPC server", logging.Err(err))
+		}
+	}()
+
+	metricsServer := metrics.NewServer(uint16(cfg.App.MetricsPort)) //nolint:gosec // Validate checks the range.
+
+	go func() {
+		if err := metricsServer.Start(); err != nil {
+			logger.Error("metrics server failed", logging.Err(err))
 		}
 	}()
 
 	defer func() {
-		grpcServer.GracefulStop()
+		if err := metricsServer.Close(); err != nil {
+			logger.Error("failed to close metrics server", logging.Err(err))
+		}
 	}()
 
-	log.Println("listening for aggTrades...")
+	trades := &admin.Trades{}
+
+	if cfg.Admin.Addr != "" {
+		adminServer := admin.NewServer(cfg.Admin.Addr,
+			admin.WithConnections(client),
+			admin.WithCandles(aggregatorSvc),
+			admin.WithSubscribers(grpcServer),
+			admin.WithTrades(trades),
+			admin.WithVersion(version),
+		)
+
+		go func() {
+			if err := adminServer.Start(); err != nil {
+				logger.Error("admin server failed", logging.Err(err))
+			}
+		}()
+
+		defer func() {
+			if err := adminServer.Close(); err != nil {
+				logger.Error("failed to close admin server", logging.Err(err))
+			}
+		}()
+	}
+
+	symbols := newSymbolSet(cfg.Binance.Symbols)
+	// aggregated are the symbols with candles, the synthetic ones too.
+	aggregated := newSymbolSet(slices.Concat(cfg.Binance.Symbols, synthetics.Symbols()))
+
+	stop := &shutdown{
+		cancel:       cancel,
+		client:       client,
+		aggregator:   aggregatorSvc,
+		healthServer: healthServer,
+		grpcServer:   grpcServer,
+		flushCandles: cfg.Shutdown.FlushCandles,
+		drainTimeout: cfg.Shutdown.DrainTimeout,
+	}
+
+	if cfg.Snapshot.File != "" {
+		stop.snapshots = &snapshotter{path: cfg.Snapshot.File, aggregator: aggregatorSvc}
+		stop.snapshots.restore(aggregated, cfg.Snapshot.MaxAge)
+
+		go stop.snapshots.run(ctx, cfg.Snapshot.Interval)
+	}
+
+	reloader := &symbolReloader{client: client, aggregator: aggregatorSvc, grpcServer: grpcServer}
+
+	if cfg.Indicators.WarmupURL != "" && cfg.Indicators.History > 0 {
+		reloader.warmer = &indicatorWarmer{
+			client:  &http.Client{},
+			baseURL: cfg.Indicators.WarmupURL,
+			engine:  indicatorEngine,
+			history: cfg.Indicators.History,
+			timeout: cfg.Indicators.WarmupTimeout,
+		}
+
+		// In the background, the first candles are at least a minute away and the trades must be read meanwhile.
+		go reloader.warmer.warm(ctx, aggregated.sorted()...)
+	}
+
+	reloads := make(chan struct{}, 1)
+
+	if symbolsFromEnv() {
+		logger.Warn("symbols won't be reloaded, " + symbolsEnv +
+			" is set in the environment, which overrides CONFIG_FILE and .env")
+	}
+
+	go func() {
+		err := reload.NewWatcher(cfg.File).Run(ctx, func() {
+			// A reload already pending covers this one.
+			select {
+			case reloads <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			logger.Error("config reloads disabled", logging.Err(err))
+		}
+	}()
+
+	logger.Info("listening for aggTrades")
 
 	for {
 		select {
 		case tick := <-tradeChan:
-			candle, err := aggregatorSvc.AggregateTrade(tick)
+			// Trades of removed symbols may arrive until the unsubscription is processed.
+			if !symbols.contains(tick.Symbol) {
+				continue
+			}
+
+			feed.TradeReceived()
+			trades.Record(tick.Symbol, time.UnixMilli(tick.TradeTime))
+			metrics.TradesReceived.WithLabelValues(tick.Symbol).Inc()
+			metrics.EventLatency.WithLabelValues(tick.Symbol).Observe(time.Since(time.UnixMilli(tick.EventTime)).Seconds())
+
+			candle, err := aggregateTrade(ctx, aggregatorSvc, tick)
 			if err != nil {
-				log.Printf("error aggregating trade: %v", err)
+				metrics.AggregationErrors.WithLabelValues(tick.Symbol).Inc()
+				logger.ErrorContext(ctx, "error aggregating trade", logging.KeySymbol, tick.Symbol, logging.Err(err))
 
 				continue
 			}
 
-			if cfg.App.Debug {
-				//nolint:forbidigo
-				fmt.Printf("Candlestick updated: Symbol=%s, Timestamp=%s, Open=%.2f, High=%.2f, "+
-					"Low=%.2f, Close=%.2f, Volume=%.2f\n",
-					candle.Symbol, candle.Timestamp.Format(time.RFC3339), candle.Open, candle.High, candle.Low,
-					candle.Close, candle.Volume)
+			if alertsDispatcher != nil {
+				alertsDispatcher.trade(tick)
 			}
 
+			metrics.TradeToCandleLatency.WithLabelValues(tick.Symbol).
+				Observe(time.Since(time.UnixMilli(tick.TradeTime)).Seconds())
+
+			// Logged per trade, so sampled by LOG_SAMPLE_INITIAL and LOG_SAMPLE_THEREAFTER.
+			logger.Debug("candlestick updated", logging.KeySymbol, candle.Symbol, logging.KeyInterval, "1m",
+				"timestamp", candle.Timestamp, "open", candle.Open, "high", candle.High, "low", candle.Low,
+				"close", candle.Close, "volume", candle.Volume)
+
+			// Synthetic symbols trade with their components and are aggregated the same way.
+			for _, derived := range synthetics.Trade(tick) {
+				if _, err := aggregateTrade(ctx, aggregatorSvc, derived); err != nil {
+					metrics.AggregationErrors.WithLabelValues(derived.Symbol).Inc()
+					logger.ErrorContext(ctx, "error aggregating synthetic trade", logging.KeySymbol, derived.Symbol,
+						logging.Err(err))
+
+					continue
+				}
+
+				if alertsDispatcher != nil {
+					alertsDispatcher.trade(derived)
+				}
+			}
+
+		case <-reloads:
+			symbols = reloader.reload(symbols)
 		case <-interrupt:
-			log.Println("interrupt, shutting down...")
-			cancel()
-			time.Sleep(time.Second)
+			logger.Info("interrupt, shutting down")
+			stop.run()
 
 			return
 		case <-ctx.Done():
-			log.Println("context done, exiting...")
+			logger.Info("context done, exiting")
+			stop.run()
 
 			return
 		}
 	}
 }
+
+// aggregateTrade aggregates tick in a span continuing the trace of its receipt.
+func aggregateTrade(ctx context.Context, aggregatorSvc *aggregator.Aggregator,
+	tick binance.TradeData) (*aggregator.Candlestick, error) {
+	_, span := otel.Tracer(tracerName).Start(trace.ContextWithSpanContext(ctx, tick.SpanContext), "aggregator.aggregate",
+		trace.WithAttributes(attribute.String("symbol", tick.Symbol)))
+	defer span.End()
+
+	tick.SpanContext = span.SpanContext()
+
+	candle, err := aggregatorSvc.AggregateTrade(tick)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, "aggregation failed")
+	}
+
+	return candle, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"google.golang.org/grpc/health"
+)
+
+// shutdown stops the ingestor in order, so the candlesticks aggregated before it reach the streams: stop
+// reading trades, save the snapshot, optionally flush the forming candlesticks, then drain the streams and stop
+// gRPC.
+type shutdown struct {
+	// cancel stops reading trades and the background jobs, e.g. the periodic snapshots.
+	cancel       context.CancelFunc
+	client       *binance.Client
+	aggregator   *aggregator.Aggregator
+	snapshots    *snapshotter
+	healthServer *health.Server
+	grpcServer   *ServerWrapper
+	flushCandles bool
+	drainTimeout time.Duration
+}
+
+func (s *shutdown) run() {
+	// Probes and new clients see the ingestor going away first.
+	s.healthServer.Shutdown()
+
+	s.cancel()
+
+	if err := s.client.Close(); err != nil {
+		logger.Warn("failed to close Binance WebSocket", logging.Err(err))
+	}
+
+	// Saved before flushing, so a restart resumes the forming candlesticks rather than the partial ones.
+	if s.snapshots != nil {
+		s.snapshots.close()
+	}
+
+	if s.flushCandles {
+		logger.Info("flushed forming candlesticks", "candlesticks", s.aggregator.FlushPartial())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+
+	s.aggregator.Close()
+	s.grpcServer.Shutdown(ctx)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+// snapshotter saves the state of the aggregator to a file, so a restart resumes the candlesticks being
+// aggregated instead of emitting partial ones that overwrite the persisted candles.
+type snapshotter struct {
+	// mu serializes saves, so a periodic save finishing late doesn't replace the one taken on shutdown.
+	mu         sync.Mutex
+	closed     bool
+	path       string
+	aggregator *aggregator.Aggregator
+}
+
+// restore resumes the candlesticks of symbols saved in the snapshot, unless it is older than maxAge.
+func (s *snapshotter) restore(symbols symbolSet, maxAge time.Duration) {
+	snapshot, err := aggregator.LoadSnapshot(s.path)
+	if errors.Is(err, aggregator.ErrNoSnapshot) {
+		logger.Info("no aggregator snapshot to restore", "path", s.path)
+
+		return
+	}
+
+	if err != nil {
+		logger.Error("failed to load aggregator snapshot", "path", s.path, logging.Err(err))
+
+		return
+	}
+
+	if age := time.Since(snapshot.TakenAt); age > maxAge {
+		logger.Warn("aggregator snapshot too old, not restoring", "path", s.path, "age", age, "max_age", maxAge)
+
+		return
+	}
+
+	restored := s.aggregator.Restore(snapshot.Symbols(symbols.contains))
+	logger.Info("restored aggregator snapshot", "path", s.path, "taken_at", snapshot.TakenAt,
+		"candlesticks", restored)
+}
+
+func (s *snapshotter) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.write()
+	}
+}
+
+// close saves the last snapshot, on shutdown. Later saves are skipped, so they don't replace it with the state
+// left after flushing.
+func (s *snapshotter) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.write()
+	s.closed = true
+}
+
+// write saves a snapshot, the caller holds mu.
+func (s *snapshotter) write() {
+	if err := aggregator.SaveSnapshot(s.path, s.aggregator.Snapshot(time.Now())); err != nil {
+		logger.Error("failed to save aggregator snapshot", "path", s.path, logging.Err(err))
+	}
+}
+
+// run saves a snapshot every interval until ctx is done.
+func (s *snapshotter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.save()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
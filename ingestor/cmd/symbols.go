@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
+	aggregatorgrpc "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+// symbolSet is the set of symbols the ingestor aggregates, trades of other symbols are ignored.
+type symbolSet map[string]struct{}
+
+func newSymbolSet(symbols []string) symbolSet {
+	set := make(symbolSet, len(symbols))
+
+	for _, symbol := range symbols {
+		set[strings.ToUpper(symbol)] = struct{}{}
+	}
+
+	return set
+}
+
+func (s symbolSet) contains(symbol string) bool {
+	_, ok := s[symbol]
+
+	return ok
+}
+
+// sorted returns the symbols of s, sorted.
+func (s symbolSet) sorted() []string {
+	symbols := make([]string, 0, len(s))
+	for symbol := range s {
+		symbols = append(symbols, symbol)
+	}
+
+	slices.Sort(symbols)
+
+	return symbols
+}
+
+// diff returns the symbols of next missing from s and the symbols of s missing from next, sorted.
+func (s symbolSet) diff(next symbolSet) ([]string, []string) {
+	var added, removed []string
+
+	for symbol := range next {
+		if !s.contains(symbol) {
+			added = append(added, symbol)
+		}
+	}
+
+	for symbol := range s {
+		if !next.contains(symbol) {
+			removed = append(removed, symbol)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+
+	return added, removed
+}
+
+// symbolsEnv is the setting of the symbols. Environment variables override CONFIG_FILE and .env, so reloads can't
+// change the symbols while it is set in the environment.
+const symbolsEnv = "BINANCE_SYMBOLS"
+
+// symbolsFromEnv reports whether the symbols are set in the environment, shadowing the reloaded files.
+func symbolsFromEnv() bool {
+	_, ok := os.LookupEnv(symbolsEnv)
+
+	return ok
+}
+
+// symbolReloader applies the symbols of a reloaded configuration: new symbols are subscribed and their
+// indicators warmed up, the candlesticks of removed ones finalized and emitted, and the streams told about both.
+type symbolReloader struct {
+	client     *binance.Client
+	aggregator *aggregator.Aggregator
+	grpcServer *ServerWrapper
+	// warmer is nil unless indicators are warmed up from the persistor.
+	warmer *indicatorWarmer
+}
+
+// reload returns the symbols to aggregate from now on, current when the configuration can't be reloaded.
+func (r *symbolReloader) reload(current symbolSet) symbolSet {
+	cfg, err := config.Reload()
+	if err != nil {
+		logger.Error("ignored invalid configuration, keeping the current symbols", logging.Err(err))
+
+		return current
+	}
+
+	added, removed := current.diff(newSymbolSet(cfg.Binance.Symbols))
+	if len(added) == 0 && len(removed) == 0 && symbolsFromEnv() {
+		logger.Warn("symbols unchanged, " + symbolsEnv +
+			" is set in the environment, which overrides CONFIG_FILE and .env")
+
+		return current
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		logger.Info("symbols unchanged, other settings apply after a restart")
+
+		return current
+	}
+
+	next := make(symbolSet, len(current))
+	for symbol := range current {
+		next[symbol] = struct{}{}
+	}
+
+	if err := r.client.Subscribe(added...); err != nil {
+		logger.Error("failed to subscribe to added symbols", "symbols", added, logging.Err(err))
+
+		added = nil
+	}
+
+	if err := r.client.Unsubscribe(removed...); err != nil {
+		// Trades of the symbols keep arriving, but are ignored.
+		logger.Warn("failed to unsubscribe from removed symbols", "symbols", removed, logging.Err(err))
+	}
+
+	for _, symbol := range added {
+		next[symbol] = struct{}{}
+	}
+
+	for _, symbol := range removed {
+		delete(next, symbol)
+	}
+
+	// The last candlesticks of removed symbols are sent before the streams are told about the change.
+	r.aggregator.Remove(removed...)
+	r.grpcServer.NotifySymbolsChanged(aggregatorgrpc.SymbolsChange{Added: added, Removed: removed})
+
+	if r.warmer != nil && len(added) > 0 {
+		go r.warmer.warm(context.Background(), added...)
+	}
+
+	logger.Info("symbols reloaded", "added", added, "removed", removed, "symbols", len(next))
+
+	return next
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/metrics"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/synthetic"
+)
+
+// newSynthetics returns the engine deriving the trades of the synthetic symbols, which reports them stale until
+// their components traded. Changes of AGGREGATOR_SYNTHETICS apply after a restart.
+func newSynthetics(cfg *config.AppConfig) (*synthetic.Engine, error) {
+	specs, err := cfg.SyntheticSpecs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		metrics.SyntheticStale.WithLabelValues(spec.Symbol).Set(1)
+		logger.Info("synthetic symbol configured", logging.KeySymbol, spec.Symbol, "spec", spec.String())
+	}
+
+	return synthetic.NewEngine(specs,
+		synthetic.WithStaleAfter(cfg.Aggregator.SyntheticStaleAfter),
+		synthetic.WithStaleness(func(symbol string, stale []string) {
+			if len(stale) == 0 {
+				metrics.SyntheticStale.WithLabelValues(symbol).Set(0)
+				logger.Info("synthetic symbol priced", logging.KeySymbol, symbol)
+
+				return
+			}
+
+			metrics.SyntheticStale.WithLabelValues(symbol).Set(1)
+			logger.Warn("synthetic symbol stale, not priced until its components trade", logging.KeySymbol, symbol,
+				"stale_components", stale, "stale_after", cfg.Aggregator.SyntheticStaleAfter)
+		}),
+	), nil
+}
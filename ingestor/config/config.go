@@ -1,50 +1,362 @@
 package config
 
 import (
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/alerts"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/envconfig"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/synthetic"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/webhook"
 	"github.com/spf13/viper"
 )
 
 var cfg *AppConfig
 
 type AppConfig struct {
+	// File is a YAML or TOML file of settings, which environment variables override.
+	File string `env:"CONFIG_FILE"`
+
 	App struct {
-		Name     string
-		Debug    bool
-		Env      string
-		GrpcPort uint16
+		Name        string `env:"APP_NAME"`
+		Debug       bool   `env:"APP_DEBUG"`
+		Env         string `env:"APP_ENV"`
+		GrpcPort    int    `env:"APP_GRPC_PORT"`
+		MetricsPort int    `env:"APP_METRICS_PORT"`
+	}
+
+	Admin struct {
+		Addr string `env:"ADMIN_ADDR"`
+	}
+
+	Log struct {
+		Format           string   `env:"LOG_FORMAT"`
+		Level            string   `env:"LOG_LEVEL"`
+		ComponentLevels  []string `env:"LOG_COMPONENT_LEVELS"`
+		SampleInitial    int      `env:"LOG_SAMPLE_INITIAL"`
+		SampleThereafter int      `env:"LOG_SAMPLE_THEREAFTER"`
 	}
 
 	Binance struct {
-		WebsocketBaseURL string
-		Symbols          []string
+		WebsocketBaseURL string   `env:"BINANCE_WEBSOCKET_BASE_URL"`
+		Symbols          []string `env:"BINANCE_SYMBOLS"`
+	}
+
+	GrpcTLS struct {
+		CertFile       string        `env:"GRPC_TLS_CERT_FILE"`
+		KeyFile        string        `env:"GRPC_TLS_KEY_FILE"`
+		ClientCAFile   string        `env:"GRPC_TLS_CLIENT_CA_FILE"`
+		ReloadInterval time.Duration `env:"GRPC_TLS_RELOAD_INTERVAL"`
+	}
+
+	GrpcAuth struct {
+		Enabled             bool   `env:"GRPC_AUTH_ENABLED"`
+		PolicyFile          string `env:"GRPC_AUTH_POLICY_FILE"`
+		JWTHMACKeyFile      string `env:"GRPC_AUTH_JWT_HMAC_KEY_FILE"`
+		JWTRSAPublicKeyFile string `env:"GRPC_AUTH_JWT_RSA_PUBLIC_KEY_FILE"`
+	}
+
+	GrpcLimits struct {
+		StreamsPerClient int           `env:"GRPC_LIMIT_STREAMS_PER_CLIENT"`
+		Streams          int           `env:"GRPC_LIMIT_STREAMS"`
+		UnaryRate        float64       `env:"GRPC_LIMIT_UNARY_RATE"`
+		UnaryBurst       int           `env:"GRPC_LIMIT_UNARY_BURST"`
+		StreamRetryAfter time.Duration `env:"GRPC_LIMIT_STREAM_RETRY_AFTER"`
+	}
+
+	Tracing struct {
+		Exporter     string  `env:"TRACING_EXPORTER"`
+		OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+		OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE"`
+		SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO"`
+	}
+
+	Aggregator struct {
+		Bars                []string      `env:"AGGREGATOR_BARS"`
+		Footprints          []string      `env:"AGGREGATOR_FOOTPRINTS"`
+		ValueArea           float64       `env:"AGGREGATOR_VALUE_AREA"`
+		Synthetics          []string      `env:"AGGREGATOR_SYNTHETICS"`
+		SyntheticStaleAfter time.Duration `env:"AGGREGATOR_SYNTHETIC_STALE_AFTER"`
+	}
+
+	Indicators struct {
+		History       int           `env:"INDICATORS_HISTORY"`
+		WarmupURL     string        `env:"INDICATORS_WARMUP_URL"`
+		WarmupTimeout time.Duration `env:"INDICATORS_WARMUP_TIMEOUT"`
+	}
+
+	Alerts struct {
+		Enabled                bool          `env:"ALERTS_ENABLED"`
+		RulesFile              string        `env:"ALERTS_RULES_FILE"`
+		MaxRules               int           `env:"ALERTS_MAX_RULES"`
+		WebhookAllowedNetworks []string      `env:"ALERTS_WEBHOOK_ALLOWED_NETWORKS"`
+		WebhookTimeout         time.Duration `env:"ALERTS_WEBHOOK_TIMEOUT"`
+		WebhookRetries         int           `env:"ALERTS_WEBHOOK_RETRIES"`
+		WebhookBackoff         time.Duration `env:"ALERTS_WEBHOOK_BACKOFF"`
+	}
+
+	Snapshot struct {
+		File     string        `env:"SNAPSHOT_FILE"`
+		Interval time.Duration `env:"SNAPSHOT_INTERVAL"`
+		MaxAge   time.Duration `env:"SNAPSHOT_MAX_AGE"`
+	}
+
+	Shutdown struct {
+		FlushCandles bool          `env:"SHUTDOWN_FLUSH_CANDLES"`
+		DrainTimeout time.Duration `env:"SHUTDOWN_DRAIN_TIMEOUT"`
+	}
+
+	GrpcHealth struct {
+		FeedStaleAfter time.Duration `env:"GRPC_HEALTH_FEED_STALE_AFTER"`
+		Reflection     bool          `env:"GRPC_REFLECTION_ENABLED"`
 	}
 }
 
+// Config returns the configuration, loaded on the first call without validation.
 func Config() *AppConfig {
 	if cfg == nil {
-		loadConfig()
+		_ = loadConfig()
 	}
 
 	return cfg
 }
 
-func loadConfig() {
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
-	viper.AutomaticEnv()
+// Load loads the configuration and validates it, reporting every invalid setting at once. The configuration is
+// returned along with the error, so it can still be printed.
+func Load() (*AppConfig, error) {
+	if err := loadConfig(); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// Reload reads and validates the configuration again, e.g. after its file changed, without replacing the one
+// returned by Config. Environment variables don't change in a running process, so only the files can.
+func Reload() (*AppConfig, error) {
+	loaded, err := read(viper.New())
+	if err != nil {
+		return loaded, err
+	}
+
+	if err := loaded.Validate(); err != nil {
+		return loaded, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return loaded, nil
+}
+
+// BarSpecs returns the information-driven bars of AGGREGATOR_BARS.
+func (c *AppConfig) BarSpecs() ([]aggregator.BarSpec, error) {
+	specs := make([]aggregator.BarSpec, 0, len(c.Aggregator.Bars))
 
-	_ = viper.ReadInConfig()
+	for _, bar := range c.Aggregator.Bars {
+		spec, err := aggregator.ParseBarSpec(bar)
+		if err != nil {
+			return nil, err
+		}
 
-	cfg = &AppConfig{}
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// FootprintSpecs returns the footprints of AGGREGATOR_FOOTPRINTS.
+func (c *AppConfig) FootprintSpecs() ([]aggregator.FootprintSpec, error) {
+	specs := make([]aggregator.FootprintSpec, 0, len(c.Aggregator.Footprints))
+
+	for _, footprint := range c.Aggregator.Footprints {
+		spec, err := aggregator.ParseFootprintSpec(footprint)
+		if err != nil {
+			return nil, err
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// WebhookAllowedNetworks returns the networks of ALERTS_WEBHOOK_ALLOWED_NETWORKS.
+func (c *AppConfig) WebhookAllowedNetworks() ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(c.Alerts.WebhookAllowedNetworks))
+
+	for _, s := range c.Alerts.WebhookAllowedNetworks {
+		network, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("network %q is not a CIDR: %w", s, err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// SyntheticSpecs returns the synthetic symbols of AGGREGATOR_SYNTHETICS.
+func (c *AppConfig) SyntheticSpecs() ([]synthetic.Spec, error) {
+	specs := make([]synthetic.Spec, 0, len(c.Aggregator.Synthetics))
+
+	for _, s := range c.Aggregator.Synthetics {
+		spec, err := synthetic.ParseSpec(s)
+		if err != nil {
+			return nil, err
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// Write writes the effective configuration as KEY=value lines.
+func (c *AppConfig) Write(w io.Writer) error {
+	return envconfig.Write(w, c)
+}
+
+func loadConfig() error {
+	loaded, err := read(viper.GetViper())
+	cfg = loaded
+
+	return err
+}
+
+// read reads the configuration with v.
+func read(v *viper.Viper) (*AppConfig, error) {
+	v.SetConfigName(".env")
+	v.SetConfigType("env")
+	v.AddConfigPath(".")
+	v.AutomaticEnv()
+	v.SetDefault("APP_METRICS_PORT", 9090)
+	v.SetDefault("ADMIN_ADDR", "127.0.0.1:6060")
+	v.SetDefault("LOG_FORMAT", "json")
+	v.SetDefault("LOG_SAMPLE_INITIAL", 10)
+	v.SetDefault("LOG_SAMPLE_THEREAFTER", 100)
+	v.SetDefault("GRPC_TLS_RELOAD_INTERVAL", time.Minute)
+	v.SetDefault("GRPC_HEALTH_FEED_STALE_AFTER", time.Minute)
+	v.SetDefault("TRACING_EXPORTER", "none")
+	v.SetDefault("TRACING_OTLP_ENDPOINT", "localhost:4317")
+	v.SetDefault("TRACING_OTLP_INSECURE", true)
+	v.SetDefault("TRACING_SAMPLE_RATIO", 0.01)
+	v.SetDefault("AGGREGATOR_VALUE_AREA", aggregator.DefaultValueArea)
+	v.SetDefault("AGGREGATOR_SYNTHETIC_STALE_AFTER", synthetic.DefaultStaleAfter)
+	v.SetDefault("INDICATORS_HISTORY", 500)
+	v.SetDefault("INDICATORS_WARMUP_TIMEOUT", 30*time.Second)
+	v.SetDefault("ALERTS_RULES_FILE", "alert-rules.json")
+	v.SetDefault("ALERTS_MAX_RULES", alerts.DefaultMaxRules)
+	v.SetDefault("ALERTS_WEBHOOK_TIMEOUT", webhook.DefaultTimeout)
+	v.SetDefault("ALERTS_WEBHOOK_RETRIES", webhook.DefaultRetries)
+	v.SetDefault("ALERTS_WEBHOOK_BACKOFF", webhook.DefaultBackoff)
+	v.SetDefault("SNAPSHOT_INTERVAL", 10*time.Second)
+	v.SetDefault("SNAPSHOT_MAX_AGE", 2*time.Minute)
+	v.SetDefault("SHUTDOWN_DRAIN_TIMEOUT", 10*time.Second)
+
+	_ = v.ReadInConfig()
+
+	c := &AppConfig{}
+
+	var fileErr error
+
+	if c.File = v.GetString("CONFIG_FILE"); c.File != "" {
+		fileErr = envconfig.ReadFile(v, c.File)
+	}
 
 	// App.
-	cfg.App.Name = viper.GetString("APP_NAME")
-	cfg.App.Debug = viper.GetBool("APP_DEBUG")
-	cfg.App.Env = viper.GetString("APP_ENV")
-	cfg.App.GrpcPort = uint16(viper.GetInt("APP_GRPC_PORT"))
+	c.App.Name = v.GetString("APP_NAME")
+	c.App.Debug = v.GetBool("APP_DEBUG")
+	c.App.Env = v.GetString("APP_ENV")
+	c.App.GrpcPort = v.GetInt("APP_GRPC_PORT")
+	c.App.MetricsPort = v.GetInt("APP_METRICS_PORT")
+
+	// Admin, disabled when empty.
+	c.Admin.Addr = v.GetString("ADMIN_ADDR")
+
+	// Logging, APP_DEBUG lowers the default level to debug unless LOG_LEVEL is set.
+	c.Log.Format = v.GetString("LOG_FORMAT")
+	c.Log.Level = v.GetString("LOG_LEVEL")
+	c.Log.ComponentLevels = v.GetStringSlice("LOG_COMPONENT_LEVELS")
+	c.Log.SampleInitial = v.GetInt("LOG_SAMPLE_INITIAL")
+	c.Log.SampleThereafter = v.GetInt("LOG_SAMPLE_THEREAFTER")
+
+	if c.Log.Level == "" && c.App.Debug {
+		c.Log.Level = "debug"
+	}
 
 	// Binance.
-	cfg.Binance.WebsocketBaseURL = viper.GetString("BINANCE_WEBSOCKET_BASE_URL")
-	cfg.Binance.Symbols = viper.GetStringSlice("BINANCE_SYMBOLS")
+	c.Binance.WebsocketBaseURL = v.GetString("BINANCE_WEBSOCKET_BASE_URL")
+	c.Binance.Symbols = v.GetStringSlice("BINANCE_SYMBOLS")
+
+	// gRPC TLS.
+	c.GrpcTLS.CertFile = v.GetString("GRPC_TLS_CERT_FILE")
+	c.GrpcTLS.KeyFile = v.GetString("GRPC_TLS_KEY_FILE")
+	c.GrpcTLS.ClientCAFile = v.GetString("GRPC_TLS_CLIENT_CA_FILE")
+	c.GrpcTLS.ReloadInterval = v.GetDuration("GRPC_TLS_RELOAD_INTERVAL")
+
+	// gRPC authentication.
+	c.GrpcAuth.Enabled = v.GetBool("GRPC_AUTH_ENABLED")
+	c.GrpcAuth.PolicyFile = v.GetString("GRPC_AUTH_POLICY_FILE")
+	c.GrpcAuth.JWTHMACKeyFile = v.GetString("GRPC_AUTH_JWT_HMAC_KEY_FILE")
+	c.GrpcAuth.JWTRSAPublicKeyFile = v.GetString("GRPC_AUTH_JWT_RSA_PUBLIC_KEY_FILE")
+
+	// gRPC limits.
+	c.GrpcLimits.StreamsPerClient = v.GetInt("GRPC_LIMIT_STREAMS_PER_CLIENT")
+	c.GrpcLimits.Streams = v.GetInt("GRPC_LIMIT_STREAMS")
+	c.GrpcLimits.UnaryRate = v.GetFloat64("GRPC_LIMIT_UNARY_RATE")
+	c.GrpcLimits.UnaryBurst = v.GetInt("GRPC_LIMIT_UNARY_BURST")
+	c.GrpcLimits.StreamRetryAfter = v.GetDuration("GRPC_LIMIT_STREAM_RETRY_AFTER")
+
+	// Tracing.
+	c.Tracing.Exporter = v.GetString("TRACING_EXPORTER")
+	c.Tracing.OTLPEndpoint = v.GetString("TRACING_OTLP_ENDPOINT")
+	c.Tracing.OTLPInsecure = v.GetBool("TRACING_OTLP_INSECURE")
+	c.Tracing.SampleRatio = v.GetFloat64("TRACING_SAMPLE_RATIO")
+
+	// Information-driven bars, as SYMBOL:TYPE:THRESHOLD.
+	c.Aggregator.Bars = v.GetStringSlice("AGGREGATOR_BARS")
+
+	// Footprints, as SYMBOL:TICK, and the value area of their volume profiles.
+	c.Aggregator.Footprints = v.GetStringSlice("AGGREGATOR_FOOTPRINTS")
+	c.Aggregator.ValueArea = v.GetFloat64("AGGREGATOR_VALUE_AREA")
+
+	// Synthetic symbols, as SYMBOL=BASE/QUOTE or SYMBOL=WEIGHT*SYMBOL+..., priced while their components are fresh.
+	c.Aggregator.Synthetics = v.GetStringSlice("AGGREGATOR_SYNTHETICS")
+	c.Aggregator.SyntheticStaleAfter = v.GetDuration("AGGREGATOR_SYNTHETIC_STALE_AFTER")
+
+	// Indicators, warmed up from the persistor's candle history when INDICATORS_WARMUP_URL is set.
+	c.Indicators.History = v.GetInt("INDICATORS_HISTORY")
+	c.Indicators.WarmupURL = v.GetString("INDICATORS_WARMUP_URL")
+	c.Indicators.WarmupTimeout = v.GetDuration("INDICATORS_WARMUP_TIMEOUT")
+
+	// Alert rules, saved to ALERTS_RULES_FILE, and the delivery of their firings to webhooks.
+	c.Alerts.Enabled = v.GetBool("ALERTS_ENABLED")
+	c.Alerts.RulesFile = v.GetString("ALERTS_RULES_FILE")
+	c.Alerts.MaxRules = v.GetInt("ALERTS_MAX_RULES")
+	// Networks webhooks may resolve to besides public addresses, as CIDRs, e.g. a receiver in the cluster.
+	c.Alerts.WebhookAllowedNetworks = v.GetStringSlice("ALERTS_WEBHOOK_ALLOWED_NETWORKS")
+	c.Alerts.WebhookTimeout = v.GetDuration("ALERTS_WEBHOOK_TIMEOUT")
+	c.Alerts.WebhookRetries = v.GetInt("ALERTS_WEBHOOK_RETRIES")
+	c.Alerts.WebhookBackoff = v.GetDuration("ALERTS_WEBHOOK_BACKOFF")
+
+	// Aggregator snapshots, disabled when SNAPSHOT_FILE is empty.
+	c.Snapshot.File = v.GetString("SNAPSHOT_FILE")
+	c.Snapshot.Interval = v.GetDuration("SNAPSHOT_INTERVAL")
+	c.Snapshot.MaxAge = v.GetDuration("SNAPSHOT_MAX_AGE")
+
+	// Shutdown.
+	c.Shutdown.FlushCandles = v.GetBool("SHUTDOWN_FLUSH_CANDLES")
+	c.Shutdown.DrainTimeout = v.GetDuration("SHUTDOWN_DRAIN_TIMEOUT")
+
+	// gRPC health and reflection.
+	c.GrpcHealth.FeedStaleAfter = v.GetDuration("GRPC_HEALTH_FEED_STALE_AFTER")
+	c.GrpcHealth.Reflection = v.GetBool("GRPC_REFLECTION_ENABLED")
+
+	return c, fileErr
 }
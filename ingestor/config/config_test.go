@@ -2,7 +2,9 @@ package config_test
 
 import (
 	"github.com/majidmvulle/binance-trading-chart-service/ingestor/config"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -20,3 +22,68 @@ func TestLoadConfig(t *testing.T) {
 		t.Fatalf("config: expected %s, got: %s", want, got)
 	}
 }
+
+func TestAppConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := config.AppConfig{}
+	valid.App.GrpcPort, valid.App.MetricsPort = 50051, 9090
+	valid.Log.Format = "json"
+	valid.Binance.WebsocketBaseURL = "wss://stream.binance.com:9443"
+	valid.Binance.Symbols = []string{"BTCUSDT"}
+	valid.GrpcHealth.FeedStaleAfter = time.Minute
+	valid.Tracing.Exporter = "none"
+	valid.Shutdown.DrainTimeout = 10 * time.Second
+	valid.Aggregator.ValueArea = 0.7
+
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+
+	invalid := valid
+	invalid.App.GrpcPort = 0
+	invalid.App.MetricsPort = 65536
+	invalid.Binance.WebsocketBaseURL = ""
+	invalid.Binance.Symbols = []string{"BTC-USDT"}
+	invalid.GrpcTLS.CertFile = "server.crt"
+	invalid.GrpcAuth.Enabled = true
+	invalid.Snapshot.File = "aggregator-snapshot.json"
+	invalid.Aggregator.Bars = []string{"BTCUSDT:kagi:10"}
+	invalid.Aggregator.Footprints = []string{"BTCUSDT"}
+	invalid.Aggregator.ValueArea = 1.5
+	invalid.Aggregator.Synthetics = []string{"ETHBTC=ETHUSDT/BTCUSDT"}
+	invalid.Indicators.History = -1
+	invalid.Indicators.WarmupURL = "persistor:8080"
+	invalid.Alerts.Enabled = true
+	invalid.Alerts.WebhookRetries = -1
+	invalid.Alerts.WebhookAllowedNetworks = []string{"10.0.0.1"}
+
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("expected an invalid config")
+	}
+
+	for _, key := range []string{
+		"APP_GRPC_PORT", "BINANCE_WEBSOCKET_BASE_URL", "BINANCE_SYMBOLS", "GRPC_TLS_CERT_FILE", "GRPC_AUTH_POLICY_FILE",
+		"SNAPSHOT_INTERVAL", "AGGREGATOR_BARS", "AGGREGATOR_FOOTPRINTS", "AGGREGATOR_VALUE_AREA", "INDICATORS_HISTORY",
+		"INDICATORS_WARMUP_URL", "ALERTS_RULES_FILE", "ALERTS_WEBHOOK_ALLOWED_NETWORKS",
+		"ALERTS_MAX_RULES", "APP_METRICS_PORT",
+		"ALERTS_WEBHOOK_TIMEOUT", "ALERTS_WEBHOOK_RETRIES", "ALERTS_WEBHOOK_BACKOFF", "AGGREGATOR_SYNTHETICS",
+		"AGGREGATOR_SYNTHETIC_STALE_AFTER",
+	} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("expected a problem with %s, got:\n%v", key, err)
+		}
+	}
+	// Synthetic symbols have no volume, so only AllSymbols volume bars, which skip them, are accepted.
+	synthetic := valid
+	synthetic.Binance.Symbols = []string{"BTCUSDT", "ETHUSDT"}
+	synthetic.Aggregator.Synthetics = []string{"ETHBTC=ETHUSDT/BTCUSDT"}
+	synthetic.Aggregator.SyntheticStaleAfter = time.Minute
+	synthetic.Aggregator.Bars = []string{"*:volume:10", "ETHBTC:dollar:1000"}
+
+	if err := synthetic.Validate(); err == nil || !strings.Contains(err.Error(), "AGGREGATOR_BARS: dollar bars of") ||
+		strings.Contains(err.Error(), "volume bars of") {
+		t.Errorf("expected a problem with the ETHBTC dollar bars only, got %v", err)
+	}
+}
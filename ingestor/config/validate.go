@@ -0,0 +1,193 @@
+package config
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/envconfig"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/synthetic"
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// maxWebhookRetries bounds ALERTS_WEBHOOK_RETRIES, past it the backoff reaches hours.
+const maxWebhookRetries = 20
+
+// Validate checks the required settings and the format of the others, returning every problem found.
+func (c *AppConfig) Validate() error {
+	var problems envconfig.Problems
+
+	problems.Port("APP_GRPC_PORT", c.App.GrpcPort)
+	problems.Port("APP_METRICS_PORT", c.App.MetricsPort)
+
+	if c.App.GrpcPort != 0 && c.App.GrpcPort == c.App.MetricsPort {
+		problems.Addf("APP_METRICS_PORT", "must differ from APP_GRPC_PORT")
+	}
+
+	problems.HostPort("ADMIN_ADDR", c.Admin.Addr)
+
+	problems.OneOf("LOG_FORMAT", c.Log.Format, "json", "text")
+	problems.LogLevel("LOG_LEVEL", c.Log.Level)
+
+	problems.Required("BINANCE_WEBSOCKET_BASE_URL", c.Binance.WebsocketBaseURL)
+	problems.URL("BINANCE_WEBSOCKET_BASE_URL", c.Binance.WebsocketBaseURL, "ws", "wss")
+
+	if len(c.Binance.Symbols) == 0 {
+		problems.Addf("BINANCE_SYMBOLS", "is required")
+	}
+
+	for _, symbol := range c.Binance.Symbols {
+		if !symbolPattern.MatchString(symbol) {
+			problems.Addf("BINANCE_SYMBOLS", "%q is not a symbol", symbol)
+		}
+	}
+
+	if (c.GrpcTLS.CertFile == "") != (c.GrpcTLS.KeyFile == "") {
+		problems.Addf("GRPC_TLS_CERT_FILE", "must be set together with GRPC_TLS_KEY_FILE")
+	}
+
+	if c.GrpcTLS.ClientCAFile != "" && c.GrpcTLS.CertFile == "" {
+		problems.Addf("GRPC_TLS_CLIENT_CA_FILE", "requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+	}
+
+	if c.GrpcTLS.CertFile != "" && c.GrpcTLS.ReloadInterval <= 0 {
+		problems.Addf("GRPC_TLS_RELOAD_INTERVAL", "must be positive")
+	}
+
+	if c.GrpcAuth.Enabled {
+		problems.Required("GRPC_AUTH_POLICY_FILE", c.GrpcAuth.PolicyFile)
+	}
+
+	problems.NotNegative("GRPC_LIMIT_STREAMS_PER_CLIENT", float64(c.GrpcLimits.StreamsPerClient))
+	problems.NotNegative("GRPC_LIMIT_STREAMS", float64(c.GrpcLimits.Streams))
+	problems.NotNegative("GRPC_LIMIT_UNARY_RATE", c.GrpcLimits.UnaryRate)
+	problems.NotNegative("GRPC_LIMIT_UNARY_BURST", float64(c.GrpcLimits.UnaryBurst))
+
+	if c.GrpcHealth.FeedStaleAfter <= 0 {
+		problems.Addf("GRPC_HEALTH_FEED_STALE_AFTER", "must be positive")
+	}
+
+	for _, bar := range c.Aggregator.Bars {
+		if _, err := aggregator.ParseBarSpec(bar); err != nil {
+			problems.Addf("AGGREGATOR_BARS", "%v", err)
+		}
+	}
+
+	for _, footprint := range c.Aggregator.Footprints {
+		if _, err := aggregator.ParseFootprintSpec(footprint); err != nil {
+			problems.Addf("AGGREGATOR_FOOTPRINTS", "%v", err)
+		}
+	}
+
+	if c.Aggregator.ValueArea <= 0 || c.Aggregator.ValueArea > 1 {
+		problems.Addf("AGGREGATOR_VALUE_AREA", "must be above 0 and at most 1")
+	}
+
+	c.validateSynthetics(&problems)
+
+	problems.Between("INDICATORS_HISTORY", float64(c.Indicators.History), 0, indicators.MaxHistory)
+	problems.URL("INDICATORS_WARMUP_URL", c.Indicators.WarmupURL, "http", "https")
+
+	if c.Indicators.WarmupURL != "" && c.Indicators.WarmupTimeout <= 0 {
+		problems.Addf("INDICATORS_WARMUP_TIMEOUT", "must be positive")
+	}
+
+	if c.Alerts.Enabled {
+		problems.Required("ALERTS_RULES_FILE", c.Alerts.RulesFile)
+
+		if _, err := c.WebhookAllowedNetworks(); err != nil {
+			problems.Addf("ALERTS_WEBHOOK_ALLOWED_NETWORKS", "%v", err)
+		}
+
+		if c.Alerts.MaxRules < 1 {
+			problems.Addf("ALERTS_MAX_RULES", "must be positive")
+		}
+
+		if c.Alerts.WebhookTimeout <= 0 {
+			problems.Addf("ALERTS_WEBHOOK_TIMEOUT", "must be positive")
+		}
+
+		problems.Between("ALERTS_WEBHOOK_RETRIES", float64(c.Alerts.WebhookRetries), 0, maxWebhookRetries)
+
+		if c.Alerts.WebhookBackoff <= 0 {
+			problems.Addf("ALERTS_WEBHOOK_BACKOFF", "must be positive")
+		}
+	}
+
+	if c.Snapshot.File != "" {
+		if c.Snapshot.Interval <= 0 {
+			problems.Addf("SNAPSHOT_INTERVAL", "must be positive")
+		}
+
+		if c.Snapshot.MaxAge <= 0 {
+			problems.Addf("SNAPSHOT_MAX_AGE", "must be positive")
+		}
+	}
+
+	if c.Shutdown.DrainTimeout <= 0 {
+		problems.Addf("SHUTDOWN_DRAIN_TIMEOUT", "must be positive")
+	}
+
+	problems.OneOf("TRACING_EXPORTER", c.Tracing.Exporter, "none", "otlp", "stdout")
+	problems.Between("TRACING_SAMPLE_RATIO", c.Tracing.SampleRatio, 0, 1)
+
+	if c.Tracing.Exporter == "otlp" {
+		problems.HostPort("TRACING_OTLP_ENDPOINT", c.Tracing.OTLPEndpoint)
+	}
+
+	return problems.Err()
+}
+
+// validateSynthetics checks the synthetic symbols are made of symbols traded on Binance, so removing a component
+// from BINANCE_SYMBOLS is rejected instead of leaving its synthetic symbols stale.
+func (c *AppConfig) validateSynthetics(problems *envconfig.Problems) {
+	symbols := make([]string, 0, len(c.Binance.Symbols))
+	for _, symbol := range c.Binance.Symbols {
+		symbols = append(symbols, strings.ToUpper(symbol))
+	}
+
+	var synthetics []string
+
+	for _, s := range c.Aggregator.Synthetics {
+		spec, err := synthetic.ParseSpec(s)
+		if err != nil {
+			problems.Addf("AGGREGATOR_SYNTHETICS", "%v", err)
+
+			continue
+		}
+
+		switch {
+		case slices.Contains(symbols, spec.Symbol):
+			problems.Addf("AGGREGATOR_SYNTHETICS", "%s is also in BINANCE_SYMBOLS", spec.Symbol)
+		case slices.Contains(synthetics, spec.Symbol):
+			problems.Addf("AGGREGATOR_SYNTHETICS", "%s is defined twice", spec.Symbol)
+		}
+
+		synthetics = append(synthetics, spec.Symbol)
+
+		for _, component := range spec.Components {
+			if !slices.Contains(symbols, component.Symbol) {
+				problems.Addf("AGGREGATOR_SYNTHETICS", "component %s of %s is not in BINANCE_SYMBOLS", component.Symbol,
+					spec.Symbol)
+			}
+		}
+	}
+
+	// Synthetic symbols trade without volume, so their volume and dollar bars would never close. AllSymbols ones
+	// skip them.
+	for _, s := range c.Aggregator.Bars {
+		spec, err := aggregator.ParseBarSpec(s)
+		if err == nil && (spec.Type == aggregator.BarVolume || spec.Type == aggregator.BarDollar) &&
+			slices.Contains(synthetics, spec.Symbol) {
+			problems.Addf("AGGREGATOR_BARS", "%s bars of synthetic symbol %s would never close, it has no volume",
+				spec.Type, spec.Symbol)
+		}
+	}
+
+	if len(c.Aggregator.Synthetics) > 0 && c.Aggregator.SyntheticStaleAfter <= 0 {
+		problems.Addf("AGGREGATOR_SYNTHETIC_STALE_AFTER", "must be positive")
+	}
+}
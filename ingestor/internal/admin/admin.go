@@ -0,0 +1,188 @@
+// Package admin serves the runtime state of the ingestor and pprof over HTTP for operators. It is meant for a
+// port that isn't exposed outside the cluster, e.g. reached with kubectl port-forward.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof" //nolint:gosec // registered on the admin mux only, not on http.DefaultServeMux.
+	"time"
+
+	aggregatorgrpc "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+const readHeaderTimeout = 5 * time.Second
+
+var logger = logging.Component("admin")
+
+// ConnectionSource lists the open websocket connections, e.g. a binance.Client.
+type ConnectionSource interface {
+	Connections() []binance.Connection
+}
+
+// CandleSource lists the candlesticks still being aggregated, e.g. an aggregator.Aggregator.
+type CandleSource interface {
+	Forming() []aggregator.Candlestick
+}
+
+// SubscriberSource lists the connected gRPC streams.
+type SubscriberSource interface {
+	Subscribers() []aggregatorgrpc.Subscriber
+}
+
+type options struct {
+	connections ConnectionSource
+	candles     CandleSource
+	subscribers SubscriberSource
+	trades      *Trades
+	version     string
+}
+
+type Option func(o *options)
+
+// WithConnections serves GET /admin/subscriptions, the streams subscribed per websocket connection.
+func WithConnections(source ConnectionSource) Option {
+	return func(o *options) {
+		o.connections = source
+	}
+}
+
+// WithCandles serves GET /admin/candles, the forming candlestick of every symbol and interval.
+func WithCandles(source CandleSource) Option {
+	return func(o *options) {
+		o.candles = source
+	}
+}
+
+// WithSubscribers serves GET /admin/subscribers, the connected gRPC streams and their buffer depth.
+func WithSubscribers(source SubscriberSource) Option {
+	return func(o *options) {
+		o.subscribers = source
+	}
+}
+
+// WithTrades serves GET /admin/trades, the last trade received per symbol.
+func WithTrades(trades *Trades) Option {
+	return func(o *options) {
+		o.trades = trades
+	}
+}
+
+// WithVersion sets the version reported by GET /admin/build, "dev" when unset.
+func WithVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
+
+// Server serves the admin endpoints over HTTP.
+type Server struct {
+	server *http.Server
+}
+
+func NewServer(addr string, opts ...Option) *Server {
+	return &Server{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           NewHandler(opts...),
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+// NewHandler returns the admin endpoints configured by opts, for serving or testing without a listener.
+func NewHandler(opts ...Option) http.Handler {
+	opt := options{version: "dev"}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	mux := http.NewServeMux()
+
+	build := readBuildInfo(opt.version)
+	mux.HandleFunc("GET /admin/build", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, build.withUptime())
+	})
+
+	if opt.connections != nil {
+		mux.HandleFunc("GET /admin/subscriptions", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, opt.connections.Connections())
+		})
+	}
+
+	if opt.candles != nil {
+		mux.HandleFunc("GET /admin/candles", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, formingCandles(opt.candles.Forming()))
+		})
+	}
+
+	if opt.subscribers != nil {
+		mux.HandleFunc("GET /admin/subscribers", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, opt.subscribers.Subscribers())
+		})
+	}
+
+	if opt.trades != nil {
+		mux.HandleFunc("GET /admin/trades", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, opt.trades.Last())
+		})
+	}
+
+	// Without a method, as POST /debug/pprof/symbol is used by go tool pprof.
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+func (s *Server) Start() error {
+	logger.Info("admin serving", "addr", s.server.Addr)
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve admin: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// formingCandle is a candlestick with the interval it is aggregated over, none for information-driven bars.
+type formingCandle struct {
+	aggregator.Candlestick
+	Interval string `json:"interval,omitempty"`
+}
+
+func formingCandles(candles []aggregator.Candlestick) []formingCandle {
+	out := make([]formingCandle, 0, len(candles))
+
+	for _, candle := range candles {
+		forming := formingCandle{Candlestick: candle}
+		if candle.BarType == aggregator.BarTime {
+			forming.Interval = "1m"
+		}
+
+		out = append(out, forming)
+	}
+
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("failed to write admin response", logging.Err(err))
+	}
+}
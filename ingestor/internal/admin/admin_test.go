@@ -0,0 +1,100 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/admin"
+	aggregatorgrpc "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+type fakeSources struct{}
+
+func (fakeSources) Connections() []binance.Connection {
+	return []binance.Connection{{URL: "wss://stream.binance.com:9443", Streams: []string{"btcusdt@aggTrade"}}}
+}
+
+func (fakeSources) Forming() []aggregator.Candlestick {
+	return []aggregator.Candlestick{{Symbol: "BTCUSDT", Open: 100, Close: 101}}
+}
+
+func (fakeSources) Subscribers() []aggregatorgrpc.Subscriber {
+	return []aggregatorgrpc.Subscriber{{StreamID: 1, Identity: "persistor", Buffered: 3, Capacity: 256}}
+}
+
+func get(t *testing.T, handler http.Handler, path string, v any) int {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	if rec.Code == http.StatusOK && v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: invalid JSON %q: %v", path, rec.Body.String(), err)
+		}
+	}
+
+	return rec.Code
+}
+
+func TestHandler(t *testing.T) {
+	trades := &admin.Trades{}
+	trades.Record("BTCUSDT", time.UnixMilli(1737973800000))
+
+	handler := admin.NewHandler(
+		admin.WithConnections(fakeSources{}),
+		admin.WithCandles(fakeSources{}),
+		admin.WithSubscribers(fakeSources{}),
+		admin.WithTrades(trades),
+		admin.WithVersion("v1.2.3"),
+	)
+
+	var connections []binance.Connection
+	if get(t, handler, "/admin/subscriptions", &connections); len(connections) != 1 ||
+		connections[0].Streams[0] != "btcusdt@aggTrade" {
+		t.Errorf("unexpected subscriptions %+v", connections)
+	}
+
+	var candles []map[string]any
+	if get(t, handler, "/admin/candles", &candles); len(candles) != 1 || candles[0]["symbol"] != "BTCUSDT" ||
+		candles[0]["interval"] != "1m" {
+		t.Errorf("unexpected candles %+v", candles)
+	}
+
+	var subscribers []aggregatorgrpc.Subscriber
+	if get(t, handler, "/admin/subscribers", &subscribers); len(subscribers) != 1 || subscribers[0].Buffered != 3 {
+		t.Errorf("unexpected subscribers %+v", subscribers)
+	}
+
+	var last []admin.LastTrade
+	if get(t, handler, "/admin/trades", &last); len(last) != 1 || last[0].TradeTime.UnixMilli() != 1737973800000 {
+		t.Errorf("unexpected trades %+v", last)
+	}
+
+	var build admin.BuildInfo
+	if get(t, handler, "/admin/build", &build); build.Version != "v1.2.3" || build.GoVersion == "" {
+		t.Errorf("unexpected build info %+v", build)
+	}
+
+	if code := get(t, handler, "/debug/pprof/", nil); code != http.StatusOK {
+		t.Errorf("expected the pprof index, got status %d", code)
+	}
+}
+
+func TestHandler_WithoutSources(t *testing.T) {
+	handler := admin.NewHandler()
+
+	if code := get(t, handler, "/admin/subscribers", nil); code != http.StatusNotFound {
+		t.Errorf("expected no subscribers endpoint without a source, got status %d", code)
+	}
+
+	var build admin.BuildInfo
+	if get(t, handler, "/admin/build", &build); build.Version != "dev" {
+		t.Errorf("expected the dev version by default, got %+v", build)
+	}
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// BuildInfo is the version of the running binary and the revision it was built from.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	// Revision, RevisionTime and Modified are the VCS stamp, empty when built outside a checkout.
+	Revision     string    `json:"revision,omitempty"`
+	RevisionTime string    `json:"revision_time,omitempty"`
+	Modified     bool      `json:"modified,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	Uptime       string    `json:"uptime"`
+}
+
+func readBuildInfo(version string) BuildInfo {
+	info := BuildInfo{Version: version, StartedAt: time.Now().UTC()}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = build.GoVersion
+
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.RevisionTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
+
+func (b BuildInfo) withUptime() BuildInfo {
+	b.Uptime = time.Since(b.StartedAt).Round(time.Second).String()
+
+	return b
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LastTrade is the last trade received for a symbol.
+type LastTrade struct {
+	Symbol     string    `json:"symbol"`
+	TradeTime  time.Time `json:"trade_time"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Trades records the last trade received per symbol. Its zero value is ready to use.
+type Trades struct {
+	mu   sync.Mutex
+	last map[string]LastTrade
+}
+
+// Record records a trade of symbol executed at tradeTime, received now.
+func (t *Trades) Record(symbol string, tradeTime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.last == nil {
+		t.last = make(map[string]LastTrade)
+	}
+
+	t.last[symbol] = LastTrade{Symbol: symbol, TradeTime: tradeTime.UTC(), ReceivedAt: time.Now().UTC()}
+}
+
+// Last returns the last trade of every symbol a trade was recorded for, ordered by symbol.
+func (t *Trades) Last() []LastTrade {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	trades := make([]LastTrade, 0, len(t.last))
+
+	for _, trade := range t.last {
+		trades = append(trades, trade)
+	}
+
+	slices.SortFunc(trades, func(x, y LastTrade) int {
+		return cmp.Compare(x.Symbol, y.Symbol)
+	})
+
+	return trades
+}
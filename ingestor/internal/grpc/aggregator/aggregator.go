@@ -1,45 +1,440 @@
 package aggregator
 
 import (
-	"log"
+	"context"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/services/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
 	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/api/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const tracerName = "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+
+var logger = logging.Component("stream")
+
+// maxIndicators bounds the indicators of a stream.
+const maxIndicators = 16
+
 type Server struct {
 	aggregatorpb.UnimplementedAggregatorServiceServer
-	candlestickChan chan *aggregator.Candlestick
+	hub        *hub
+	indicators *indicators.Engine
+	// lastStreamID numbers the streams, so the log records of one stream can be told apart.
+	lastStreamID atomic.Uint64
+}
+
+type options struct {
+	indicators *indicators.Engine
+	observe    func(candle *aggregator.Candlestick)
+}
+
+type Option func(o *options)
+
+// WithIndicators computes the indicators streams request with engine, which is fed every completed candlestick.
+// Streams requesting indicators are rejected without it.
+func WithIndicators(engine *indicators.Engine) Option {
+	return func(o *options) {
+		o.indicators = engine
+	}
 }
 
-func NewServer(candlestickChan chan *aggregator.Candlestick) *Server {
+// WithCandleObserver calls observe with every completed candlestick once it was broadcast, e.g. to evaluate alert
+// rules on it. It runs on the loop broadcasting the candlesticks, so it must not block.
+func WithCandleObserver(observe func(candle *aggregator.Candlestick)) Option {
+	return func(o *options) {
+		o.observe = observe
+	}
+}
+
+func NewServer(candlestickChan chan *aggregator.Candlestick, opts ...Option) *Server {
+	opt := options{}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	h := newHub(opt.indicators, opt.observe)
+	go h.run(candlestickChan)
+
 	return &Server{
-		candlestickChan: candlestickChan,
+		hub:        h,
+		indicators: opt.indicators,
 	}
 }
 
-func (s *Server) StreamCandlesticks(_ *aggregatorpb.StreamRequest,
+func (s *Server) StreamCandlesticks(req *aggregatorpb.StreamRequest,
 	stream aggregatorpb.AggregatorService_StreamCandlesticksServer) error {
-	log.Println("client connected for candlestick stream")
-
-	for candle := range s.candlestickChan {
-		resp := &aggregatorpb.StreamResponse{
-			Symbol:    candle.Symbol,
-			Open:      candle.Open,
-			High:      candle.High,
-			Low:       candle.Low,
-			Close:     candle.Close,
-			Volume:    candle.Volume,
-			Timestamp: timestamppb.New(candle.Timestamp),
+	allowed, err := symbolFilter(stream.Context(), req.GetSymbols())
+	if err != nil {
+		return err
+	}
+
+	wantsBar := barTypeFilter(req.GetBarTypes())
+
+	specs, err := s.indicatorSpecs(req.GetIndicators())
+	if err != nil {
+		return err
+	}
+
+	if len(specs) > 0 {
+		s.indicators.Register(specs...)
+		defer s.indicators.Unregister(specs...)
+	}
+
+	info := Subscriber{
+		StreamID:    s.lastStreamID.Add(1),
+		Peer:        peerAddr(stream),
+		Symbols:     req.GetSymbols(),
+		ConnectedAt: time.Now().UTC(),
+	}
+
+	streamLogger := logger.With(logging.KeyStreamID, info.StreamID, logging.KeyPeer, info.Peer)
+	if identity, ok := auth.FromContext(stream.Context()); ok {
+		info.Identity = identity.Name
+		streamLogger = streamLogger.With("identity", identity.Name)
+	}
+
+	sub := s.hub.subscribe(info)
+	defer s.hub.unsubscribe(sub)
+
+	streamLogger.Info("client connected for candlestick stream", "symbols", req.GetSymbols(),
+		"indicators", len(specs))
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			streamLogger.Info("client disconnected from candlestick stream")
+
+			return nil
+		case e, ok := <-sub.events:
+			if !ok && sub.overflowed {
+				return status.Errorf(grpccodes.ResourceExhausted,
+					"stream fell %d candles behind, reconnect to resume", subscriberBuffer)
+			}
+
+			if !ok {
+				streamLogger.Info("candlestick stream channel closed, ending gRPC stream")
+
+				return nil
+			}
+
+			if e.change != nil {
+				if err := sendChange(stream, *e.change, allowed); err != nil {
+					streamLogger.Warn("failed to send symbols change", logging.Err(err))
+
+					return err
+				}
+
+				continue
+			}
+
+			if !allowed(e.candle.Symbol) || !wantsBar(e.candle.BarType) {
+				continue
+			}
+
+			if err := send(stream, e.candle, indicatorValues(specs, e.indicators), req.GetFootprints()); err != nil {
+				streamLogger.Warn("failed to send candlestick", logging.KeySymbol, e.candle.Symbol, logging.Err(err))
+
+				return err
+			}
 		}
+	}
+}
+
+// NotifySymbolsChanged tells every stream which of the symbols it may receive were added or removed. Call it
+// after the last candlesticks of the removed symbols were sent on the candlestick channel, so streams receive
+// it after them.
+func (s *Server) NotifySymbolsChanged(change SymbolsChange) {
+	s.hub.notify(change)
+}
+
+// Subscribers returns the connected streams with the candlesticks buffered for each.
+func (s *Server) Subscribers() []Subscriber {
+	return s.hub.list()
+}
+
+// indicatorSpecs returns the normalized specs of the indicators a stream requested.
+func (s *Server) indicatorSpecs(requested []*aggregatorpb.Indicator) ([]indicators.Spec, error) {
+	if len(requested) == 0 {
+		return nil, nil
+	}
+
+	if s.indicators == nil {
+		return nil, status.Error(grpccodes.FailedPrecondition, "indicators are not enabled")
+	}
+
+	if len(requested) > maxIndicators {
+		return nil, status.Errorf(grpccodes.InvalidArgument, "at most %d indicators may be requested, got %d",
+			maxIndicators, len(requested))
+	}
+
+	specs := make([]indicators.Spec, 0, len(requested))
 
-		if err := stream.Send(resp); err != nil {
-			return err
+	for _, indicator := range requested {
+		spec, err := indicatorSpec(indicator).Normalize()
+		if err != nil {
+			return nil, status.Errorf(grpccodes.InvalidArgument, "invalid indicator %v: %v", indicator.GetType(), err)
 		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+// indicatorSpec returns the spec of an indicator of the API, not normalized.
+func indicatorSpec(indicator *aggregatorpb.Indicator) indicators.Spec {
+	return indicators.Spec{
+		Type:         indicatorTypes[indicator.GetType()],
+		Period:       int(indicator.GetPeriod()),
+		FastPeriod:   int(indicator.GetFastPeriod()),
+		SlowPeriod:   int(indicator.GetSlowPeriod()),
+		SignalPeriod: int(indicator.GetSignalPeriod()),
+		StdDev:       indicator.GetStdDev(),
+	}
+}
+
+// indicatorProto returns the indicator of the API of a normalized spec.
+func indicatorProto(spec indicators.Spec) *aggregatorpb.Indicator {
+	return &aggregatorpb.Indicator{
+		Type:         indicatorProtoTypes[spec.Type],
+		Period:       int32(spec.Period),       //nolint:gosec // Periods are at most indicators.MaxPeriod.
+		FastPeriod:   int32(spec.FastPeriod),   //nolint:gosec // Periods are at most indicators.MaxPeriod.
+		SlowPeriod:   int32(spec.SlowPeriod),   //nolint:gosec // Periods are at most indicators.MaxPeriod.
+		SignalPeriod: int32(spec.SignalPeriod), //nolint:gosec // Periods are at most indicators.MaxPeriod.
+		StdDev:       spec.StdDev,
+	}
+}
+
+// indicatorTypes maps the API's indicator types to the engine's, unspecified to none.
+var indicatorTypes = map[aggregatorpb.IndicatorType]indicators.Type{
+	aggregatorpb.IndicatorType_INDICATOR_TYPE_SMA:       indicators.SMA,
+	aggregatorpb.IndicatorType_INDICATOR_TYPE_EMA:       indicators.EMA,
+	aggregatorpb.IndicatorType_INDICATOR_TYPE_RSI:       indicators.RSI,
+	aggregatorpb.IndicatorType_INDICATOR_TYPE_MACD:      indicators.MACD,
+	aggregatorpb.IndicatorType_INDICATOR_TYPE_BOLLINGER: indicators.Bollinger,
+}
+
+// indicatorValues returns the values of specs among values, in the order of specs. Indicators registered after
+// the candle was computed have none.
+func indicatorValues(specs []indicators.Spec, values []indicators.Value) []*aggregatorpb.IndicatorValue {
+	if len(specs) == 0 {
+		return nil
 	}
 
-	log.Println("candlestick stream channel closed, ending gRPC stream")
+	out := make([]*aggregatorpb.IndicatorValue, 0, len(specs))
+
+	for _, spec := range specs {
+		i := slices.IndexFunc(values, func(value indicators.Value) bool {
+			return value.Spec == spec
+		})
+		if i < 0 {
+			continue
+		}
+
+		value := values[i]
+		out = append(out, &aggregatorpb.IndicatorValue{
+			Indicator: indicatorProto(spec),
+			Ready:     value.Ready,
+			Value:     value.Value,
+			Signal:    value.Signal,
+			Histogram: value.Histogram,
+			Upper:     value.Upper,
+			Lower:     value.Lower,
+		})
+	}
+
+	return out
+}
+
+var indicatorProtoTypes = map[indicators.Type]aggregatorpb.IndicatorType{
+	indicators.SMA:       aggregatorpb.IndicatorType_INDICATOR_TYPE_SMA,
+	indicators.EMA:       aggregatorpb.IndicatorType_INDICATOR_TYPE_EMA,
+	indicators.RSI:       aggregatorpb.IndicatorType_INDICATOR_TYPE_RSI,
+	indicators.MACD:      aggregatorpb.IndicatorType_INDICATOR_TYPE_MACD,
+	indicators.Bollinger: aggregatorpb.IndicatorType_INDICATOR_TYPE_BOLLINGER,
+}
+
+// send sends candle on stream with the values of its indicators, and its footprint when requested, continuing the
+// trace of the trade that completed it.
+func send(stream aggregatorpb.AggregatorService_StreamCandlesticksServer, candle *aggregator.Candlestick,
+	values []*aggregatorpb.IndicatorValue, footprints bool) error {
+	ctx := trace.ContextWithSpanContext(stream.Context(), candle.SpanContext)
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "aggregator.send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("symbol", candle.Symbol),
+			attribute.String("candle.timestamp", candle.Timestamp.Format(time.RFC3339)),
+		))
+	defer span.End()
+
+	resp := &aggregatorpb.StreamResponse{
+		Symbol:        candle.Symbol,
+		Open:          candle.Open,
+		High:          candle.High,
+		Low:           candle.Low,
+		Close:         candle.Close,
+		Volume:        candle.Volume,
+		Timestamp:     timestamppb.New(candle.Timestamp),
+		TraceContext:  tracing.Inject(ctx),
+		Reconstructed: candle.Reconstructed,
+		Partial:       candle.Partial,
+		BarType:       barTypes[candle.BarType],
+		Threshold:     candle.Threshold,
+		Indicators:    values,
+	}
+
+	if candle.CloseTime != nil {
+		resp.CloseTime = timestamppb.New(*candle.CloseTime)
+	}
+
+	if footprints && candle.Footprint != nil {
+		resp.Footprint = footprintToProto(candle.Footprint)
+		resp.SessionProfile = profileToProto(candle.SessionProfile)
+	}
+
+	err := stream.Send(resp)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "send failed")
+	}
+
+	return err
+}
+
+func footprintToProto(footprint *aggregator.Footprint) *aggregatorpb.Footprint {
+	levels := make([]*aggregatorpb.PriceLevel, 0, len(footprint.Levels))
+
+	for _, level := range footprint.Levels {
+		levels = append(levels, &aggregatorpb.PriceLevel{
+			Price:      level.Price,
+			BuyVolume:  level.BuyVolume,
+			SellVolume: level.SellVolume,
+		})
+	}
+
+	return &aggregatorpb.Footprint{Tick: footprint.Tick, Levels: levels, Profile: profileToProto(&footprint.Profile)}
+}
+
+func profileToProto(profile *aggregator.VolumeProfile) *aggregatorpb.VolumeProfile {
+	if profile == nil {
+		return nil
+	}
+
+	out := &aggregatorpb.VolumeProfile{
+		Poc:           profile.POC,
+		ValueAreaHigh: profile.ValueAreaHigh,
+		ValueAreaLow:  profile.ValueAreaLow,
+		Volume:        profile.Volume,
+		Partial:       profile.Partial,
+	}
+
+	if !profile.SessionStart.IsZero() {
+		out.SessionStart = timestamppb.New(profile.SessionStart)
+	}
+
+	return out
+}
+
+// sendChange sends the symbols of change that stream receives, nothing when none of them are.
+func sendChange(stream aggregatorpb.AggregatorService_StreamCandlesticksServer, change SymbolsChange,
+	allowed func(symbol string) bool) error {
+	added, removed := filterSymbols(change.Added, allowed), filterSymbols(change.Removed, allowed)
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	return stream.Send(&aggregatorpb.StreamResponse{
+		SymbolsChange: &aggregatorpb.SymbolsChange{Added: added, Removed: removed},
+	})
+}
+
+func filterSymbols(symbols []string, allowed func(symbol string) bool) []string {
+	var out []string
+
+	for _, symbol := range symbols {
+		if allowed(symbol) {
+			out = append(out, symbol)
+		}
+	}
+
+	return out
+}
+
+func peerAddr(stream aggregatorpb.AggregatorService_StreamCandlesticksServer) string {
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		return p.Addr.String()
+	}
+
+	return ""
+}
+
+// barTypes maps the bar types of candlesticks to the API's.
+var barTypes = map[aggregator.BarType]aggregatorpb.BarType{
+	aggregator.BarTime:       aggregatorpb.BarType_BAR_TYPE_TIME,
+	aggregator.BarTick:       aggregatorpb.BarType_BAR_TYPE_TICK,
+	aggregator.BarVolume:     aggregatorpb.BarType_BAR_TYPE_VOLUME,
+	aggregator.BarDollar:     aggregatorpb.BarType_BAR_TYPE_DOLLAR,
+	aggregator.BarRange:      aggregatorpb.BarType_BAR_TYPE_RANGE,
+	aggregator.BarRenko:      aggregatorpb.BarType_BAR_TYPE_RENKO,
+	aggregator.BarHeikinAshi: aggregatorpb.BarType_BAR_TYPE_HEIKIN_ASHI,
+}
+
+// barTypeFilter returns whether a stream requesting the bar types requested receives bars of a type, time bars
+// only when none are requested, as streams did before information-driven bars.
+func barTypeFilter(requested []aggregatorpb.BarType) func(barType aggregator.BarType) bool {
+	requested = slices.DeleteFunc(slices.Clone(requested), func(barType aggregatorpb.BarType) bool {
+		return barType == aggregatorpb.BarType_BAR_TYPE_UNSPECIFIED
+	})
+
+	if len(requested) == 0 {
+		requested = []aggregatorpb.BarType{aggregatorpb.BarType_BAR_TYPE_TIME}
+	}
+
+	return func(barType aggregator.BarType) bool {
+		return slices.Contains(requested, barTypes[barType])
+	}
+}
+
+// symbolFilter returns which symbols a stream receives: the requested ones, or every symbol the caller
+// may read when none are requested. Requesting a symbol the caller may not read is denied.
+func symbolFilter(ctx context.Context, requested []string) (func(symbol string) bool, error) {
+	identity, authenticated := auth.FromContext(ctx)
+
+	symbols := make([]string, 0, len(requested))
+
+	for _, symbol := range requested {
+		symbol = strings.ToUpper(symbol)
+
+		if authenticated && !identity.AllowsSymbol(symbol) {
+			return nil, status.Errorf(grpccodes.PermissionDenied, "%s may not read %s", identity.Name, symbol)
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return func(symbol string) bool {
+		if len(symbols) > 0 {
+			return slices.Contains(symbols, symbol)
+		}
 
-	return nil
+		return !authenticated || identity.AllowsSymbol(symbol)
+	}, nil
 }
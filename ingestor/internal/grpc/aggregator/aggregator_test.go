@@ -0,0 +1,561 @@
+package aggregator_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	aggregatorgrpc "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/metrics"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/api/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves the aggregator stream of candles over an in-memory listener, authenticated by API keys
+// equal to the identity names.
+func startServer(t *testing.T, candles chan *aggregator.Candlestick,
+	opts ...aggregatorgrpc.Option) aggregatorpb.AggregatorServiceClient {
+	t.Helper()
+
+	client, _ := startAggregatorServer(t, candles, opts...)
+
+	return client
+}
+
+// startAggregatorServer is startServer also returning the aggregator service, to inspect its subscribers.
+func startAggregatorServer(t *testing.T, candles chan *aggregator.Candlestick,
+	opts ...aggregatorgrpc.Option) (aggregatorpb.AggregatorServiceClient, *aggregatorgrpc.Server) {
+	t.Helper()
+
+	aggregatorServer := aggregatorgrpc.NewServer(candles, opts...)
+	conn := serve(t, func(server *grpc.Server) {
+		aggregatorpb.RegisterAggregatorServiceServer(server, aggregatorServer)
+	})
+
+	return aggregatorpb.NewAggregatorServiceClient(conn), aggregatorServer
+}
+
+// serve serves the services registered by register over an in-memory listener, authenticated by API keys equal
+// to the identity names, returning a connection dialed with opts.
+func serve(t *testing.T, register func(server *grpc.Server), opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	persistorKey, dashboardKey := sha256.Sum256([]byte("persistor")), sha256.Sum256([]byte("dashboard"))
+	policy := `{
+  "api_keys": {"` + hex.EncodeToString(persistorKey[:]) + `": "persistor", "` +
+		hex.EncodeToString(dashboardKey[:]) + `": "dashboard"},
+  "identities": {
+    "persistor": {"symbols": ["*"], "rpcs": ["*"]},
+    "dashboard": {"symbols": ["BTCUSDT"], "rpcs": ["*"]}
+  }
+}`
+
+	file := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(file, []byte(policy), 0o600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	loaded, err := auth.LoadPolicy(file)
+	if err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+
+	authenticator := auth.NewAuthenticator(loaded)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(authenticator.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(authenticator.StreamServerInterceptor()))
+	register(server)
+
+	lis := bufconn.Listen(1024 * 1024)
+
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", append([]grpc.DialOption{
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+func stream(t *testing.T, client aggregatorpb.AggregatorServiceClient, apiKey string,
+	symbols ...string) aggregatorpb.AggregatorService_StreamCandlesticksClient {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	ctx = metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, apiKey)
+
+	s, err := client.StreamCandlesticks(ctx, &aggregatorpb.StreamRequest{Symbols: symbols})
+	if err != nil {
+		t.Fatalf("StreamCandlesticks failed: %v", err)
+	}
+
+	return s
+}
+
+func TestServer_StreamCandlesticks_FansOutAndFilters(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	client := startServer(t, candles)
+
+	all := stream(t, client, "persistor")
+	btc := stream(t, client, "persistor", "btcusdt")
+	dashboard := stream(t, client, "dashboard")
+
+	// Wait until the three streams subscribed before publishing.
+	time.Sleep(100 * time.Millisecond)
+
+	if got := testutil.ToFloat64(metrics.Subscribers); got != 3 {
+		t.Errorf("expected 3 subscribers, got %v", got)
+	}
+
+	emitted := testutil.ToFloat64(metrics.CandlesEmitted.WithLabelValues("BTCUSDT"))
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	for _, symbol := range []string{"ETHUSDT", "BTCUSDT"} {
+		candles <- &aggregator.Candlestick{Symbol: symbol, Close: 1, Timestamp: now}
+	}
+
+	for name, tc := range map[string]struct {
+		stream aggregatorpb.AggregatorService_StreamCandlesticksClient
+		want   []string
+	}{
+		"all symbols":       {all, []string{"ETHUSDT", "BTCUSDT"}},
+		"requested symbols": {btc, []string{"BTCUSDT"}},
+		"allowed symbols":   {dashboard, []string{"BTCUSDT"}},
+	} {
+		for _, want := range tc.want {
+			resp, err := tc.stream.Recv()
+			if err != nil {
+				t.Fatalf("%s: Recv failed: %v", name, err)
+			}
+
+			if resp.GetSymbol() != want {
+				t.Errorf("%s: expected %s, got %s", name, want, resp.GetSymbol())
+			}
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.CandlesEmitted.WithLabelValues("BTCUSDT")) - emitted; got != 1 {
+		t.Errorf("expected 1 BTCUSDT candle emitted, got %v", got)
+	}
+}
+
+func TestServer_StreamCandlesticks_EndsSlowStream(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	aggregatorServer := aggregatorgrpc.NewServer(candles)
+	// A fixed flow control window, so the sends of a stream not reading block once it is full.
+	conn := serve(t, func(server *grpc.Server) {
+		aggregatorpb.RegisterAggregatorServiceServer(server, aggregatorServer)
+	}, grpc.WithInitialWindowSize(1<<16), grpc.WithInitialConnWindowSize(1<<16))
+
+	slow := stream(t, aggregatorpb.NewAggregatorServiceClient(conn), "persistor")
+
+	for len(aggregatorServer.Subscribers()) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	overflowed := testutil.ToFloat64(metrics.StreamsOverflowed)
+	start := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	const published = 5000
+	for i := range published {
+		candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 1, Timestamp: start.Add(time.Duration(i) * time.Minute)}
+	}
+
+	// The stream receives the candles it sent or buffered in order, then ends instead of skipping the others.
+	var received int
+
+	for {
+		resp, err := slow.Recv()
+		if err != nil {
+			if status.Code(err) != codes.ResourceExhausted {
+				t.Fatalf("expected ResourceExhausted, got %v", err)
+			}
+
+			break
+		}
+
+		if want := start.Add(time.Duration(received) * time.Minute); !resp.GetTimestamp().AsTime().Equal(want) {
+			t.Fatalf("expected the candle of %v, got %v", want, resp.GetTimestamp().AsTime())
+		}
+
+		received++
+	}
+
+	if received == 0 || received >= published {
+		t.Errorf("expected the stream to end after some of the %d candles, got %d", published, received)
+	}
+
+	if got := testutil.ToFloat64(metrics.StreamsOverflowed) - overflowed; got != 1 {
+		t.Errorf("expected 1 overflowed stream, got %v", got)
+	}
+}
+
+func TestServer_Subscribers(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	client, server := startAggregatorServer(t, candles)
+
+	stream(t, client, "persistor")
+	stream(t, client, "dashboard", "BTCUSDT")
+
+	time.Sleep(100 * time.Millisecond)
+
+	subscribers := server.Subscribers()
+	if len(subscribers) != 2 {
+		t.Fatalf("expected 2 subscribers, got %+v", subscribers)
+	}
+
+	if subscribers[0].StreamID >= subscribers[1].StreamID {
+		t.Errorf("expected subscribers ordered by stream ID, got %+v", subscribers)
+	}
+
+	for _, subscriber := range subscribers {
+		if subscriber.Identity == "dashboard" && (len(subscriber.Symbols) != 1 || subscriber.Symbols[0] != "BTCUSDT") {
+			t.Errorf("expected the dashboard stream to request BTCUSDT, got %+v", subscriber)
+		}
+
+		if subscriber.Buffered != 0 || subscriber.Capacity == 0 || subscriber.ConnectedAt.IsZero() {
+			t.Errorf("expected an empty buffer of a connected stream, got %+v", subscriber)
+		}
+	}
+}
+
+func TestServer_NotifySymbolsChanged(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	client, server := startAggregatorServer(t, candles)
+
+	all := stream(t, client, "persistor")
+	dashboard := stream(t, client, "dashboard")
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The last candle of the removed symbol, then the change.
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 1, Timestamp: time.Now()}
+	server.NotifySymbolsChanged(aggregatorgrpc.SymbolsChange{Added: []string{"SOLUSDT"}, Removed: []string{"BTCUSDT"}})
+
+	for name, tc := range map[string]struct {
+		stream  aggregatorpb.AggregatorService_StreamCandlesticksClient
+		added   int
+		removed int
+	}{
+		"all symbols":     {all, 1, 1},
+		"allowed symbols": {dashboard, 0, 1},
+	} {
+		resp, err := tc.stream.Recv()
+		if err != nil || resp.GetSymbol() != "BTCUSDT" {
+			t.Fatalf("%s: expected the BTCUSDT candle first, got %v (%v)", name, resp, err)
+		}
+
+		resp, err = tc.stream.Recv()
+		if err != nil {
+			t.Fatalf("%s: Recv failed: %v", name, err)
+		}
+
+		change := resp.GetSymbolsChange()
+		if len(change.GetAdded()) != tc.added || len(change.GetRemoved()) != tc.removed {
+			t.Errorf("%s: expected %d added and %d removed symbols, got %v", name, tc.added, tc.removed, change)
+		}
+	}
+}
+
+func TestServer_StreamCandlesticks_DrainsOnClose(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	client := startServer(t, candles)
+
+	all := stream(t, client, "persistor")
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Buffered by the stream, then the channel is closed as on shutdown.
+	now := time.Now().UTC().Truncate(time.Minute)
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+		candles <- &aggregator.Candlestick{Symbol: symbol, Close: 1, Timestamp: now, Partial: true}
+	}
+
+	close(candles)
+
+	for _, want := range []string{"BTCUSDT", "ETHUSDT"} {
+		resp, err := all.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+
+		if resp.GetSymbol() != want || !resp.GetPartial() {
+			t.Errorf("expected a partial %s candle, got %v", want, resp)
+		}
+	}
+
+	if _, err := all.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+}
+
+func TestServer_StreamCandlesticks_FiltersBarTypes(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	client := startServer(t, candles)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	bars, err := client.StreamCandlesticks(metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "persistor"),
+		&aggregatorpb.StreamRequest{BarTypes: []aggregatorpb.BarType{aggregatorpb.BarType_BAR_TYPE_VOLUME}})
+	if err != nil {
+		t.Fatalf("StreamCandlesticks failed: %v", err)
+	}
+
+	times := stream(t, client, "persistor")
+
+	time.Sleep(100 * time.Millisecond)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	closeTime := now.Add(time.Second)
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 1, Timestamp: now}
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 2, Timestamp: now, BarType: aggregator.BarVolume,
+		Threshold: 5, CloseTime: &closeTime}
+
+	resp, err := bars.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if resp.GetBarType() != aggregatorpb.BarType_BAR_TYPE_VOLUME || resp.GetThreshold() != 5 ||
+		!resp.GetCloseTime().AsTime().Equal(closeTime) {
+		t.Errorf("expected the volume bar, got %v", resp)
+	}
+
+	resp, err = times.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if resp.GetBarType() != aggregatorpb.BarType_BAR_TYPE_TIME || resp.GetClose() != 1 {
+		t.Errorf("expected only the time bar without requested bar types, got %v", resp)
+	}
+}
+
+func TestServer_StreamCandlesticks_Footprints(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	client := startServer(t, candles)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	footprints, err := client.StreamCandlesticks(
+		metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "persistor"),
+		&aggregatorpb.StreamRequest{Footprints: true})
+	if err != nil {
+		t.Fatalf("StreamCandlesticks failed: %v", err)
+	}
+
+	plain := stream(t, client, "persistor")
+
+	time.Sleep(100 * time.Millisecond)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	profile := aggregator.VolumeProfile{POC: 100, ValueAreaHigh: 110, ValueAreaLow: 100, Volume: 3}
+	session := profile
+	session.SessionStart, session.Partial = now.Truncate(24*time.Hour), true
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 1, Timestamp: now, SessionProfile: &session,
+		Footprint: &aggregator.Footprint{Tick: 10, Profile: profile, Levels: []aggregator.PriceLevel{
+			{Price: 100, BuyVolume: 1, SellVolume: 1}, {Price: 110, SellVolume: 1},
+		}}}
+
+	resp, err := footprints.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	footprint := resp.GetFootprint()
+	if footprint.GetTick() != 10 || len(footprint.GetLevels()) != 2 ||
+		footprint.GetLevels()[0].GetSellVolume() != 1 || footprint.GetProfile().GetPoc() != 100 {
+		t.Errorf("expected the footprint, got %v", footprint)
+	}
+
+	if got := resp.GetSessionProfile(); got.GetValueAreaHigh() != 110 || !got.GetPartial() ||
+		!got.GetSessionStart().AsTime().Equal(session.SessionStart) {
+		t.Errorf("expected the session profile, got %v", got)
+	}
+
+	resp, err = plain.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if resp.GetFootprint() != nil || resp.GetSessionProfile() != nil {
+		t.Errorf("expected no footprint without requesting it, got %v", resp)
+	}
+}
+
+func TestServer_StreamCandlesticks_Indicators(t *testing.T) {
+	candles := make(chan *aggregator.Candlestick)
+	engine := indicators.NewEngine(10)
+	engine.Warm(indicators.Key{Symbol: "BTCUSDT", Interval: "1m"}, []float64{1, 2})
+	client := startServer(t, candles, aggregatorgrpc.WithIndicators(engine))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	withIndicators, err := client.StreamCandlesticks(
+		metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "persistor"),
+		&aggregatorpb.StreamRequest{Indicators: []*aggregatorpb.Indicator{
+			{Type: aggregatorpb.IndicatorType_INDICATOR_TYPE_SMA, Period: 3},
+			{Type: aggregatorpb.IndicatorType_INDICATOR_TYPE_EMA, Period: 2},
+			{Type: aggregatorpb.IndicatorType_INDICATOR_TYPE_BOLLINGER, Period: 5},
+		}})
+	if err != nil {
+		t.Fatalf("StreamCandlesticks failed: %v", err)
+	}
+
+	plain := stream(t, client, "persistor")
+
+	time.Sleep(100 * time.Millisecond)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 3, Timestamp: now}
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Close: 4, Timestamp: now.Add(time.Minute), Partial: true}
+
+	resp, err := withIndicators.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	// Warmed up with 1 and 2, the SMA of 3 and the EMA of 2 are ready, the EMA seeded with 1.5, the Bollinger
+	// bands of 5 aren't.
+	values := resp.GetIndicators()
+	if len(values) != 3 || !values[0].GetReady() || values[0].GetValue() != 2 || !values[1].GetReady() ||
+		values[1].GetValue() != 2.5 || values[2].GetReady() || values[2].GetIndicator().GetStdDev() != 2 {
+		t.Errorf("unexpected indicators: %v", values)
+	}
+
+	if resp, err = withIndicators.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if !resp.GetPartial() || len(resp.GetIndicators()) != 0 {
+		t.Errorf("expected a partial candle without indicators, got %v", resp)
+	}
+
+	if resp, err = plain.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if len(resp.GetIndicators()) != 0 {
+		t.Errorf("expected no indicators without requesting them, got %v", resp.GetIndicators())
+	}
+
+	invalid, err := client.StreamCandlesticks(metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "persistor"),
+		&aggregatorpb.StreamRequest{Indicators: []*aggregatorpb.Indicator{
+			{Type: aggregatorpb.IndicatorType_INDICATOR_TYPE_MACD, FastPeriod: 30, SlowPeriod: 10},
+		}})
+	if err != nil {
+		t.Fatalf("StreamCandlesticks failed: %v", err)
+	}
+
+	if _, err := invalid.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an invalid indicator, got %v", err)
+	}
+}
+
+func TestServer_StreamCandlesticks_IndicatorsDisabled(t *testing.T) {
+	client := startServer(t, make(chan *aggregator.Candlestick))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	candles, err := client.StreamCandlesticks(metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, "persistor"),
+		&aggregatorpb.StreamRequest{Indicators: []*aggregatorpb.Indicator{
+			{Type: aggregatorpb.IndicatorType_INDICATOR_TYPE_RSI},
+		}})
+	if err != nil {
+		t.Fatalf("StreamCandlesticks failed: %v", err)
+	}
+
+	if _, err := candles.Recv(); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition without an indicator engine, got %v", err)
+	}
+}
+
+func TestServer_StreamCandlesticks_DeniesSymbols(t *testing.T) {
+	client := startServer(t, make(chan *aggregator.Candlestick))
+
+	_, err := stream(t, client, "dashboard", "ETHUSDT").Recv()
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for a symbol the identity may not read, got %s (%v)", got, err)
+	}
+
+	_, err = stream(t, client, "stolen").Recv()
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated for an unknown API key, got %s (%v)", got, err)
+	}
+}
+
+func TestServer_StreamCandlesticks_PropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	candles := make(chan *aggregator.Candlestick)
+	s := stream(t, startServer(t, candles), "persistor")
+
+	time.Sleep(100 * time.Millisecond)
+
+	trade := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	candles <- &aggregator.Candlestick{Symbol: "BTCUSDT", Timestamp: time.Now(), SpanContext: trade}
+
+	resp, err := s.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	received := trace.SpanContextFromContext(tracing.Extract(context.Background(), resp.GetTraceContext()))
+	if received.TraceID() != trade.TraceID() {
+		t.Errorf("expected the candle to continue trace %s, got %s", trade.TraceID(), received.TraceID())
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "aggregator.send" || spans[0].Parent().SpanID() != trade.SpanID() {
+		t.Errorf("expected one aggregator.send span under the trade span, got %v", spans)
+	}
+}
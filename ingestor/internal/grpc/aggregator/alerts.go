@@ -0,0 +1,316 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/alerts"
+	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/api/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// alertBuffer is how many alerts a slow alert stream may lag behind before it misses some.
+const alertBuffer = 64
+
+// AlertServer manages the alert rules of an evaluator and streams their firings, published with Publish. With
+// authentication, callers only see the rules they created, on the symbols they may read.
+type AlertServer struct {
+	aggregatorpb.UnimplementedAlertServiceServer
+	evaluator *alerts.Evaluator
+
+	mu          sync.Mutex
+	subscribers map[chan alerts.Firing]struct{}
+	closed      bool
+}
+
+func NewAlertServer(evaluator *alerts.Evaluator) *AlertServer {
+	return &AlertServer{
+		evaluator:   evaluator,
+		subscribers: make(map[chan alerts.Firing]struct{}),
+	}
+}
+
+func (s *AlertServer) CreateAlertRule(ctx context.Context,
+	req *aggregatorpb.CreateAlertRuleRequest) (*aggregatorpb.AlertRule, error) {
+	rule, err := ruleFromProto(req.GetRule())
+	if err != nil {
+		return nil, err
+	}
+
+	if identity, ok := auth.FromContext(ctx); ok {
+		if !identity.AllowsSymbol(rule.Symbol) {
+			return nil, status.Errorf(grpccodes.PermissionDenied, "%s may not read %s", identity.Name, rule.Symbol)
+		}
+
+		rule.Owner = identity.Name
+	}
+
+	added, err := s.evaluator.Add(rule, time.Now())
+
+	switch {
+	case errors.Is(err, alerts.ErrTooManyRules):
+		return nil, status.Error(grpccodes.ResourceExhausted, err.Error())
+	case errors.Is(err, alerts.ErrNoVolume):
+		return nil, status.Error(grpccodes.InvalidArgument, err.Error())
+	case err != nil:
+		// The rule was validated, so saving it failed.
+		logger.ErrorContext(ctx, "failed to save alert rule", logging.Err(err))
+
+		return nil, status.Error(grpccodes.Internal, "failed to save alert rule")
+	}
+
+	logger.InfoContext(ctx, "alert rule created", "rule_id", added.ID, "rule", added.String(), "owner", added.Owner)
+
+	// The secret is only ever returned here, to the rule's owner.
+	created := ruleToProto(added)
+	created.WebhookSecret = added.WebhookSecret
+
+	return created, nil
+}
+
+func (s *AlertServer) ListAlertRules(ctx context.Context,
+	req *aggregatorpb.ListAlertRulesRequest) (*aggregatorpb.ListAlertRulesResponse, error) {
+	symbols := make([]string, 0, len(req.GetSymbols()))
+	for _, symbol := range req.GetSymbols() {
+		symbols = append(symbols, strings.ToUpper(symbol))
+	}
+
+	resp := &aggregatorpb.ListAlertRulesResponse{}
+
+	for _, rule := range s.evaluator.Rules() {
+		if visible(ctx, rule) && (len(symbols) == 0 || slices.Contains(symbols, rule.Symbol)) {
+			resp.Rules = append(resp.Rules, ruleToProto(rule))
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *AlertServer) DeleteAlertRule(ctx context.Context,
+	req *aggregatorpb.DeleteAlertRuleRequest) (*aggregatorpb.DeleteAlertRuleResponse, error) {
+	// Rules of other callers are reported as not found, so their IDs can't be probed.
+	if rule, ok := s.evaluator.Rule(req.GetId()); !ok || !visible(ctx, rule) {
+		return nil, status.Errorf(grpccodes.NotFound, "alert rule %q not found", req.GetId())
+	}
+
+	removed, err := s.evaluator.Remove(req.GetId())
+
+	switch {
+	case errors.Is(err, alerts.ErrRuleNotFound):
+		return nil, status.Errorf(grpccodes.NotFound, "alert rule %q not found", req.GetId())
+	case err != nil:
+		logger.ErrorContext(ctx, "failed to delete alert rule", logging.Err(err))
+
+		return nil, status.Error(grpccodes.Internal, "failed to delete alert rule")
+	}
+
+	logger.InfoContext(ctx, "alert rule deleted", "rule_id", removed.ID, "rule", removed.String())
+
+	return &aggregatorpb.DeleteAlertRuleResponse{}, nil
+}
+
+func (s *AlertServer) StreamAlerts(req *aggregatorpb.StreamAlertsRequest,
+	stream aggregatorpb.AlertService_StreamAlertsServer) error {
+	allowed, err := symbolFilter(stream.Context(), req.GetSymbols())
+	if err != nil {
+		return err
+	}
+
+	sub := s.subscribe()
+	defer s.unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case firing, ok := <-sub:
+			if !ok {
+				return nil
+			}
+
+			if !allowed(firing.Rule.Symbol) || !visible(stream.Context(), firing.Rule) {
+				continue
+			}
+
+			if err := stream.Send(alertToProto(firing)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Publish sends firing to the alert streams. Streams too slow to keep up miss it.
+func (s *AlertServer) Publish(firing alerts.Firing) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers {
+		select {
+		case sub <- firing:
+		default:
+			logger.Warn("dropped alert for a slow stream", "alert_id", firing.ID, "rule_id", firing.Rule.ID)
+		}
+	}
+}
+
+// Close ends the alert streams, on shutdown.
+func (s *AlertServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers {
+		close(sub)
+		delete(s.subscribers, sub)
+	}
+
+	s.closed = true
+}
+
+func (s *AlertServer) subscribe() chan alerts.Firing {
+	sub := make(chan alerts.Firing, alertBuffer)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		close(sub)
+
+		return sub
+	}
+
+	s.subscribers[sub] = struct{}{}
+
+	return sub
+}
+
+func (s *AlertServer) unsubscribe(sub chan alerts.Firing) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers, sub)
+}
+
+// visible reports whether the caller may see rule: every rule without authentication, its own otherwise.
+func visible(ctx context.Context, rule alerts.Rule) bool {
+	identity, ok := auth.FromContext(ctx)
+
+	return !ok || (rule.Owner == identity.Name && identity.AllowsSymbol(rule.Symbol))
+}
+
+var alertTypes = map[aggregatorpb.AlertType]alerts.Type{
+	aggregatorpb.AlertType_ALERT_TYPE_PRICE_CROSS:     alerts.PriceCross,
+	aggregatorpb.AlertType_ALERT_TYPE_PERCENT_MOVE:    alerts.PercentMove,
+	aggregatorpb.AlertType_ALERT_TYPE_VOLUME_SPIKE:    alerts.VolumeSpike,
+	aggregatorpb.AlertType_ALERT_TYPE_INDICATOR_CROSS: alerts.IndicatorCross,
+}
+
+var alertProtoTypes = map[alerts.Type]aggregatorpb.AlertType{
+	alerts.PriceCross:     aggregatorpb.AlertType_ALERT_TYPE_PRICE_CROSS,
+	alerts.PercentMove:    aggregatorpb.AlertType_ALERT_TYPE_PERCENT_MOVE,
+	alerts.VolumeSpike:    aggregatorpb.AlertType_ALERT_TYPE_VOLUME_SPIKE,
+	alerts.IndicatorCross: aggregatorpb.AlertType_ALERT_TYPE_INDICATOR_CROSS,
+}
+
+var alertDirections = map[aggregatorpb.AlertDirection]alerts.Direction{
+	aggregatorpb.AlertDirection_ALERT_DIRECTION_UNSPECIFIED: alerts.DirectionAny,
+	aggregatorpb.AlertDirection_ALERT_DIRECTION_UP:          alerts.DirectionUp,
+	aggregatorpb.AlertDirection_ALERT_DIRECTION_DOWN:        alerts.DirectionDown,
+}
+
+var alertProtoDirections = map[alerts.Direction]aggregatorpb.AlertDirection{
+	alerts.DirectionAny:  aggregatorpb.AlertDirection_ALERT_DIRECTION_UNSPECIFIED,
+	alerts.DirectionUp:   aggregatorpb.AlertDirection_ALERT_DIRECTION_UP,
+	alerts.DirectionDown: aggregatorpb.AlertDirection_ALERT_DIRECTION_DOWN,
+}
+
+// ruleFromProto returns the normalized rule of the API's rule, InvalidArgument when it is invalid.
+func ruleFromProto(rule *aggregatorpb.AlertRule) (alerts.Rule, error) {
+	direction, ok := alertDirections[rule.GetDirection()]
+	if !ok {
+		return alerts.Rule{}, status.Errorf(grpccodes.InvalidArgument, "unknown direction %v", rule.GetDirection())
+	}
+
+	out := alerts.Rule{
+		Symbol:     rule.GetSymbol(),
+		Type:       alertTypes[rule.GetType()],
+		Direction:  direction,
+		Level:      rule.GetLevel(),
+		Percent:    rule.GetPercent(),
+		Window:     rule.GetWindow().AsDuration(),
+		Multiplier: rule.GetMultiplier(),
+		Periods:    int(rule.GetPeriods()),
+		WebhookURL: rule.GetWebhookUrl(),
+		Cooldown:   rule.GetCooldown().AsDuration(),
+	}
+
+	if rule.GetFast() != nil {
+		fast := indicatorSpec(rule.GetFast())
+		out.Fast = &fast
+	}
+
+	if rule.GetSlow() != nil {
+		slow := indicatorSpec(rule.GetSlow())
+		out.Slow = &slow
+	}
+
+	normalized, err := out.Normalize()
+	if err != nil {
+		return alerts.Rule{}, status.Errorf(grpccodes.InvalidArgument, "invalid alert rule: %v", err)
+	}
+
+	return normalized, nil
+}
+
+func ruleToProto(rule alerts.Rule) *aggregatorpb.AlertRule {
+	out := &aggregatorpb.AlertRule{
+		Id:         rule.ID,
+		Symbol:     rule.Symbol,
+		Type:       alertProtoTypes[rule.Type],
+		Direction:  alertProtoDirections[rule.Direction],
+		Level:      rule.Level,
+		Percent:    rule.Percent,
+		Multiplier: rule.Multiplier,
+		Periods:    int32(rule.Periods), //nolint:gosec // Periods are at most alerts.MaxPeriods.
+		WebhookUrl: rule.WebhookURL,
+		CreateTime: timestamppb.New(rule.CreatedAt),
+	}
+
+	if rule.Window > 0 {
+		out.Window = durationpb.New(rule.Window)
+	}
+
+	if rule.Cooldown > 0 {
+		out.Cooldown = durationpb.New(rule.Cooldown)
+	}
+
+	if rule.Fast != nil {
+		out.Fast = indicatorProto(*rule.Fast)
+	}
+
+	if rule.Slow != nil {
+		out.Slow = indicatorProto(*rule.Slow)
+	}
+
+	return out
+}
+
+func alertToProto(firing alerts.Firing) *aggregatorpb.Alert {
+	return &aggregatorpb.Alert{
+		Id:        firing.ID,
+		Rule:      ruleToProto(firing.Rule),
+		Value:     firing.Value,
+		Reference: firing.Reference,
+		Message:   firing.Message,
+		Time:      timestamppb.New(firing.Time),
+		FireTime:  timestamppb.New(firing.FiredAt),
+	}
+}
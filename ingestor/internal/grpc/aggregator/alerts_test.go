@@ -0,0 +1,173 @@
+package aggregator_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	aggregatorgrpc "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/alerts"
+	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/api/aggregator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// startAlertServer serves the alert service of an evaluator saving its rules to a temporary file.
+func startAlertServer(t *testing.T) (aggregatorpb.AlertServiceClient, *aggregatorgrpc.AlertServer,
+	*alerts.Evaluator) {
+	t.Helper()
+
+	evaluator := alerts.NewEvaluator(alerts.WithStore(alerts.NewFileStore(filepath.Join(t.TempDir(), "rules.json"))))
+	alertServer := aggregatorgrpc.NewAlertServer(evaluator)
+	conn := serve(t, func(server *grpc.Server) {
+		aggregatorpb.RegisterAlertServiceServer(server, alertServer)
+	})
+
+	return aggregatorpb.NewAlertServiceClient(conn), alertServer, evaluator
+}
+
+func as(t *testing.T, apiKey string) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	return metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, apiKey)
+}
+
+func TestAlertServer_Rules(t *testing.T) {
+	t.Parallel()
+
+	client, _, evaluator := startAlertServer(t)
+
+	created, err := client.CreateAlertRule(as(t, "dashboard"), &aggregatorpb.CreateAlertRuleRequest{
+		Rule: &aggregatorpb.AlertRule{
+			Symbol:     "btcusdt",
+			Type:       aggregatorpb.AlertType_ALERT_TYPE_PERCENT_MOVE,
+			Direction:  aggregatorpb.AlertDirection_ALERT_DIRECTION_DOWN,
+			Percent:    5,
+			Window:     durationpb.New(time.Hour),
+			WebhookUrl: "https://example.com/hook",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateAlertRule failed: %v", err)
+	}
+
+	if created.GetId() == "" || created.GetSymbol() != "BTCUSDT" || created.GetWindow().AsDuration() != time.Hour ||
+		created.GetCreateTime() == nil || created.GetWebhookSecret() == "" {
+		t.Errorf("unexpected rule %v", created)
+	}
+
+	if rule, ok := evaluator.Rule(created.GetId()); !ok || rule.Owner != "dashboard" {
+		t.Errorf("expected the rule to be owned by dashboard, got %+v", rule)
+	}
+
+	for name, tc := range map[string]struct {
+		rule *aggregatorpb.AlertRule
+		code codes.Code
+	}{
+		"invalid": {&aggregatorpb.AlertRule{Symbol: "BTCUSDT", Type: aggregatorpb.AlertType_ALERT_TYPE_PRICE_CROSS},
+			codes.InvalidArgument},
+		"forbidden symbol": {&aggregatorpb.AlertRule{Symbol: "ETHUSDT",
+			Type: aggregatorpb.AlertType_ALERT_TYPE_PRICE_CROSS, Level: 1}, codes.PermissionDenied},
+	} {
+		_, err := client.CreateAlertRule(as(t, "dashboard"), &aggregatorpb.CreateAlertRuleRequest{Rule: tc.rule})
+		if status.Code(err) != tc.code {
+			t.Errorf("%s: expected %v, got %v", name, tc.code, err)
+		}
+	}
+
+	// The persistor neither sees nor deletes the dashboard's rule.
+	listed, err := client.ListAlertRules(as(t, "persistor"), &aggregatorpb.ListAlertRulesRequest{})
+	if err != nil || len(listed.GetRules()) != 0 {
+		t.Errorf("expected no rules for persistor, got %v, %v", listed, err)
+	}
+
+	_, err = client.DeleteAlertRule(as(t, "persistor"), &aggregatorpb.DeleteAlertRuleRequest{Id: created.GetId()})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	listed, err = client.ListAlertRules(as(t, "dashboard"),
+		&aggregatorpb.ListAlertRulesRequest{Symbols: []string{"btcusdt"}})
+	if err != nil || len(listed.GetRules()) != 1 || listed.GetRules()[0].GetId() != created.GetId() {
+		t.Fatalf("expected the created rule, got %v, %v", listed, err)
+	}
+
+	if secret := listed.GetRules()[0].GetWebhookSecret(); secret != "" {
+		t.Errorf("expected the secret to be returned on creation only, got %q", secret)
+	}
+
+	_, err = client.DeleteAlertRule(as(t, "dashboard"), &aggregatorpb.DeleteAlertRuleRequest{Id: created.GetId()})
+	if err != nil {
+		t.Fatalf("DeleteAlertRule failed: %v", err)
+	}
+
+	if rules := evaluator.Rules(); len(rules) != 0 {
+		t.Errorf("expected the rule to be deleted, got %+v", rules)
+	}
+}
+
+func TestAlertServer_StreamAlerts(t *testing.T) {
+	t.Parallel()
+
+	client, alertServer, _ := startAlertServer(t)
+
+	stream, err := client.StreamAlerts(as(t, "dashboard"), &aggregatorpb.StreamAlertsRequest{})
+	if err != nil {
+		t.Fatalf("StreamAlerts failed: %v", err)
+	}
+
+	hidden := alerts.Firing{ID: "hidden", Rule: alerts.Rule{ID: "1", Owner: "persistor", Symbol: "BTCUSDT"}}
+	shown := alerts.Firing{ID: "shown", Rule: alerts.Rule{ID: "2", Owner: "dashboard", Symbol: "BTCUSDT",
+		Type: alerts.PriceCross, Level: 100}, Value: 101, Reference: 100}
+
+	// The stream may not be subscribed yet, so publish until it receives an alert.
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				alertServer.Publish(hidden)
+				alertServer.Publish(shown)
+			}
+		}
+	}()
+
+	alert, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+
+	if alert.GetId() != "shown" || alert.GetRule().GetType() != aggregatorpb.AlertType_ALERT_TYPE_PRICE_CROSS ||
+		alert.GetValue() != 101 || alert.GetReference() != 100 {
+		t.Errorf("unexpected alert %v", alert)
+	}
+
+	alertServer.Close()
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("expected the stream to end, got %v", err)
+			}
+
+			break
+		}
+	}
+}
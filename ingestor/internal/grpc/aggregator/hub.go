@@ -0,0 +1,210 @@
+package aggregator
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/metrics"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+// subscriberBuffer is how many candlesticks a slow stream may lag behind before it is ended.
+const subscriberBuffer = 256
+
+// Subscriber describes a connected stream and how many candlesticks it has yet to send.
+type Subscriber struct {
+	StreamID    uint64    `json:"stream_id"`
+	Peer        string    `json:"peer"`
+	Identity    string    `json:"identity,omitempty"`
+	Symbols     []string  `json:"symbols"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Buffered    int       `json:"buffered"`
+	Capacity    int       `json:"capacity"`
+}
+
+// SymbolsChange lists the symbols added to and removed from the ingestor's feed.
+type SymbolsChange struct {
+	Added   []string
+	Removed []string
+}
+
+// event is what the hub broadcasts, a completed candlestick with the values of the registered indicators, or a
+// change of symbols.
+type event struct {
+	candle     *aggregator.Candlestick
+	indicators []indicators.Value
+	change     *SymbolsChange
+}
+
+// subscription holds the events of a stream until it sends them.
+type subscription struct {
+	events chan event
+	// overflowed is set before events is closed when the stream fell subscriberBuffer events behind, rather than
+	// when the hub closed.
+	overflowed bool
+}
+
+// hub fans the completed candlesticks out to every connected stream, so each one receives all of them and
+// filters its own symbols. A stream falling behind is ended rather than skipping events, so its client
+// reconnects and catches up instead of missing candles on a stream that stays open.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscription]Subscriber
+	closed      bool
+	// engine, when set, is fed the closes of the completed candlesticks.
+	engine *indicators.Engine
+	// observe, when set, is called with every candlestick after it was broadcast.
+	observe func(candle *aggregator.Candlestick)
+	changes chan SymbolsChange
+	// done is closed when run returns, so notify doesn't wait for it after.
+	done chan struct{}
+}
+
+func newHub(engine *indicators.Engine, observe func(candle *aggregator.Candlestick)) *hub {
+	return &hub{
+		engine:      engine,
+		observe:     observe,
+		subscribers: make(map[*subscription]Subscriber),
+		changes:     make(chan SymbolsChange),
+		done:        make(chan struct{}),
+	}
+}
+
+// run broadcasts source and the symbol changes until source is closed, then closes every subscription. Both
+// are received by the same loop, so a change sent after the last candles of removed symbols reaches the
+// streams after them.
+func (h *hub) run(source <-chan *aggregator.Candlestick) {
+	for {
+		select {
+		case candle, ok := <-source:
+			if !ok {
+				h.close()
+
+				return
+			}
+
+			metrics.CandlesEmitted.WithLabelValues(candle.Symbol).Inc()
+			h.broadcast(event{candle: candle, indicators: h.indicators(candle)})
+
+			if h.observe != nil {
+				h.observe(candle)
+			}
+		case change := <-h.changes:
+			// After the last candles of the removed symbols, which no longer need indicators.
+			if h.engine != nil {
+				for _, symbol := range change.Removed {
+					h.engine.Remove(symbol)
+				}
+			}
+
+			h.broadcast(event{change: &change})
+		}
+	}
+}
+
+// indicators feeds the close of candle to the engine, returning the values of the registered indicators. Partial
+// candles flushed on shutdown didn't close, so they have none.
+func (h *hub) indicators(candle *aggregator.Candlestick) []indicators.Value {
+	if h.engine == nil || candle.Partial {
+		return nil
+	}
+
+	return h.engine.Update(indicators.Key{Symbol: candle.Symbol, Interval: candle.Interval()}, candle.Close)
+}
+
+func (h *hub) broadcast(e event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub, info := range h.subscribers {
+		metrics.SubscriberLag.Observe(float64(len(sub.events)))
+
+		select {
+		case sub.events <- e:
+		default:
+			// The stream sends the events buffered before ending.
+			sub.overflowed = true
+			close(sub.events)
+			delete(h.subscribers, sub)
+			metrics.Subscribers.Dec()
+			metrics.StreamsOverflowed.Inc()
+			logger.Warn("ending a stream that fell behind", logging.KeyStreamID, info.StreamID,
+				logging.KeyPeer, info.Peer, "buffered", subscriberBuffer)
+		}
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		close(sub.events)
+		delete(h.subscribers, sub)
+		metrics.Subscribers.Dec()
+	}
+
+	h.closed = true
+	close(h.done)
+}
+
+// notify broadcasts change once run received it, after the candlesticks run received before.
+func (h *hub) notify(change SymbolsChange) {
+	select {
+	case h.changes <- change:
+	case <-h.done:
+	}
+}
+
+// subscribe registers a stream described by info.
+func (h *hub) subscribe(info Subscriber) *subscription {
+	sub := &subscription{events: make(chan event, subscriberBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.events)
+
+		return sub
+	}
+
+	h.subscribers[sub] = info
+	metrics.Subscribers.Inc()
+
+	return sub
+}
+
+func (h *hub) unsubscribe(sub *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		metrics.Subscribers.Dec()
+	}
+}
+
+// list returns the connected streams ordered by stream ID, with their buffer depth at the time of the call.
+func (h *hub) list() []Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscribers := make([]Subscriber, 0, len(h.subscribers))
+
+	for sub, info := range h.subscribers {
+		info.Symbols = slices.Clone(info.Symbols)
+		info.Buffered, info.Capacity = len(sub.events), cap(sub.events)
+		subscribers = append(subscribers, info)
+	}
+
+	slices.SortFunc(subscribers, func(x, y Subscriber) int {
+		return cmp.Compare(x.StreamID, y.StreamID)
+	})
+
+	return subscribers
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// APIKeyHeader is the metadata key carrying an API key.
+	APIKeyHeader = "x-api-key"
+	// AuthorizationHeader is the metadata key carrying a "Bearer <JWT>".
+	AuthorizationHeader = "authorization"
+	bearerPrefix        = "bearer "
+)
+
+var (
+	errMissingCredentials = errors.New("missing API key or bearer token")
+	errUnknownIdentity    = errors.New("unknown identity")
+)
+
+type options struct {
+	hmacKey      []byte
+	rsaPublicKey *rsa.PublicKey
+}
+
+type Option func(o *options)
+
+// WithHMACKey accepts HS256/384/512 tokens signed with key.
+func WithHMACKey(key []byte) Option {
+	return func(o *options) {
+		o.hmacKey = key
+	}
+}
+
+// WithRSAPublicKey accepts RS256/384/512 tokens signed by the private half of key.
+func WithRSAPublicKey(key *rsa.PublicKey) Option {
+	return func(o *options) {
+		o.rsaPublicKey = key
+	}
+}
+
+// Authenticator resolves the identity of a call from its API key or JWT.
+type Authenticator struct {
+	policy  *Policy
+	options *options
+	parser  *jwt.Parser
+}
+
+func NewAuthenticator(policy *Policy, opts ...Option) *Authenticator {
+	opt := options{}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	var methods []string
+
+	if opt.hmacKey != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+
+	if opt.rsaPublicKey != nil {
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+
+	return &Authenticator{
+		policy:  policy,
+		options: &opt,
+		parser:  jwt.NewParser(jwt.WithValidMethods(methods), jwt.WithExpirationRequired()),
+	}
+}
+
+// Authenticate returns the identity of the API key or bearer token in the incoming metadata of ctx.
+func (a *Authenticator) Authenticate(ctx context.Context) (Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if keys := md.Get(APIKeyHeader); len(keys) > 0 {
+		identity, ok := a.policy.apiKeyIdentity(keys[0])
+		if !ok {
+			return Identity{}, errors.New("invalid API key")
+		}
+
+		return identity, nil
+	}
+
+	for _, value := range md.Get(AuthorizationHeader) {
+		if len(value) > len(bearerPrefix) && strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			return a.authenticateToken(value[len(bearerPrefix):])
+		}
+	}
+
+	return Identity{}, errMissingCredentials
+}
+
+// authenticateToken validates a JWT and returns the identity of its subject.
+func (a *Authenticator) authenticateToken(raw string) (Identity, error) {
+	token, err := a.parser.Parse(raw, a.key)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid token: %w", err)
+	}
+
+	subject, err := token.Claims.GetSubject()
+	if err != nil || subject == "" {
+		return Identity{}, errors.New("invalid token: missing subject")
+	}
+
+	identity, ok := a.policy.identity(subject)
+	if !ok {
+		return Identity{}, fmt.Errorf("%w %q", errUnknownIdentity, subject)
+	}
+
+	return identity, nil
+}
+
+func (a *Authenticator) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.options.hmacKey, nil
+	case *jwt.SigningMethodRSA:
+		return a.options.rsaPublicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
+
+// LoadHMACKey reads an HMAC secret from file, without surrounding whitespace.
+func LoadHMACKey(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HMAC key: %w", err)
+	}
+
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("HMAC key file %s is empty", file)
+	}
+
+	return key, nil
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key from file.
+func LoadRSAPublicKey(file string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+	}
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,201 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const streamMethod = "/aggregator.AggregatorService/StreamCandlesticks"
+
+var hmacKey = []byte("test-secret")
+
+func loadPolicy(t *testing.T) *auth.Policy {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte("persistor-key"))
+	file := filepath.Join(t.TempDir(), "policy.json")
+	policy := `{
+  "api_keys": {"` + hex.EncodeToString(sum[:]) + `": "persistor"},
+  "identities": {
+    "persistor": {"symbols": ["*"], "rpcs": ["` + streamMethod + `"]},
+    "dashboard": {"symbols": ["btcusdt"], "rpcs": ["*"]},
+    "auditor": {"symbols": ["*"], "rpcs": []}
+  }
+}`
+
+	if err := os.WriteFile(file, []byte(policy), 0o600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	p, err := auth.LoadPolicy(file)
+	if err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+
+	return p
+}
+
+func token(t *testing.T, method jwt.SigningMethod, key any, subject string, expiresIn time.Duration) string {
+	t.Helper()
+
+	signed, err := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return signed
+}
+
+func bearer(t *testing.T, method jwt.SigningMethod, key any, subject string, expiresIn time.Duration) context.Context {
+	t.Helper()
+
+	return incoming("authorization", "Bearer "+token(t, method, key, subject, expiresIn))
+}
+
+func incoming(pairs ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	authenticator := auth.NewAuthenticator(loadPolicy(t), auth.WithHMACKey(hmacKey),
+		auth.WithRSAPublicKey(&rsaKey.PublicKey))
+
+	valid := map[string]struct {
+		ctx      context.Context
+		identity string
+	}{
+		"api key":  {incoming(auth.APIKeyHeader, "persistor-key"), "persistor"},
+		"hmac jwt": {bearer(t, jwt.SigningMethodHS256, hmacKey, "dashboard", time.Minute), "dashboard"},
+		"rsa jwt":  {bearer(t, jwt.SigningMethodRS256, rsaKey, "persistor", time.Minute), "persistor"},
+	}
+
+	for name, tc := range valid {
+		identity, err := authenticator.Authenticate(tc.ctx)
+		if err != nil || identity.Name != tc.identity {
+			t.Errorf("%s: expected identity %s, got %q and error %v", name, tc.identity, identity.Name, err)
+		}
+	}
+
+	invalid := map[string]context.Context{
+		"no credentials":  context.Background(),
+		"unknown api key": incoming(auth.APIKeyHeader, "guessed"),
+		"expired jwt":     bearer(t, jwt.SigningMethodHS256, hmacKey, "dashboard", -time.Minute),
+		"wrong rsa key":   bearer(t, jwt.SigningMethodRS256, otherKey, "dashboard", time.Minute),
+		"unknown subject": bearer(t, jwt.SigningMethodHS256, hmacKey, "intruder", time.Minute),
+	}
+
+	for name, ctx := range invalid {
+		if identity, err := authenticator.Authenticate(ctx); err == nil {
+			t.Errorf("%s: expected an error, got identity %q", name, identity.Name)
+		}
+	}
+}
+
+func TestAuthenticator_StreamServerInterceptor(t *testing.T) {
+	interceptor := auth.NewAuthenticator(loadPolicy(t), auth.WithHMACKey(hmacKey)).StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: streamMethod, IsServerStream: true}
+
+	cases := map[string]struct {
+		ctx  context.Context
+		code codes.Code
+	}{
+		"allowed":         {incoming(auth.APIKeyHeader, "persistor-key"), codes.OK},
+		"unauthenticated": {context.Background(), codes.Unauthenticated},
+		"rpc not allowed": {bearer(t, jwt.SigningMethodHS256, hmacKey, "auditor", time.Minute), codes.PermissionDenied},
+	}
+
+	for name, tc := range cases {
+		var identity auth.Identity
+
+		err := interceptor(nil, &fakeStream{ctx: tc.ctx}, info, func(_ any, stream grpc.ServerStream) error {
+			identity, _ = auth.FromContext(stream.Context())
+
+			return nil
+		})
+
+		if got := status.Code(err); got != tc.code {
+			t.Errorf("%s: expected code %s, got %s (%v)", name, tc.code, got, err)
+		}
+
+		if tc.code == codes.OK && identity.Name != "persistor" {
+			t.Errorf("%s: expected the handler to see the persistor identity, got %q", name, identity.Name)
+		}
+	}
+}
+
+func TestAuthenticator_UnaryServerInterceptor_PublicHealth(t *testing.T) {
+	interceptor := auth.NewAuthenticator(loadPolicy(t), auth.WithHMACKey(hmacKey)).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	_, err := interceptor(context.Background(), nil, info, func(_ context.Context, _ any) (any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Errorf("expected health checks without credentials to pass, got %v", err)
+	}
+
+	info.FullMethod = "/candles.CandleService/ListCandles"
+
+	_, err = interceptor(context.Background(), nil, info, func(_ context.Context, _ any) (any, error) {
+		return nil, nil
+	})
+	if got := status.Code(err); got != codes.Unauthenticated {
+		t.Errorf("expected code %s for other RPCs, got %s", codes.Unauthenticated, got)
+	}
+}
+
+func TestIdentity_AllowsSymbol(t *testing.T) {
+	dashboard := loadPolicy(t).Identities["dashboard"]
+
+	if !dashboard.AllowsSymbol("BTCUSDT") || !dashboard.AllowsSymbol("btcusdt") || dashboard.AllowsSymbol("ETHUSDT") {
+		t.Errorf("unexpected symbol permissions for %+v", dashboard)
+	}
+}
+
+func TestLoadPolicy_UnknownIdentity(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(file, []byte(`{"api_keys": {"abc": "ghost"}}`), 0o600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	if _, err := auth.LoadPolicy(file); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected an API key of an unknown identity to be rejected, got %v", err)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
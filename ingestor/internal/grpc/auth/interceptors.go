@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var logger = logging.Component("auth")
+
+type identityKey struct{}
+
+// healthService is open to everyone, kubelet probes and load balancers carry no credentials.
+const healthService = "/grpc.health.v1.Health/"
+
+// IsPublicMethod reports whether the full method name is served without authentication.
+func IsPublicMethod(method string) bool {
+	return strings.HasPrefix(method, healthService)
+}
+
+// NewContext returns a copy of ctx carrying identity.
+func NewContext(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromContext returns the identity authenticated for the call, false when authentication is disabled.
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+
+	return identity, ok
+}
+
+// UnaryServerInterceptor rejects unary calls that are not authenticated or not allowed for their identity.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if IsPublicMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams that are not authenticated or not allowed for their identity.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if IsPublicMethod(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		ctx, err := a.authorize(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	identity, err := a.Authenticate(ctx)
+	if err != nil {
+		logger.WarnContext(ctx, "rejected unauthenticated call", "method", method, logging.KeyPeer, peerAddr(ctx),
+			logging.Err(err))
+
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if !identity.AllowsRPC(method) {
+		logger.WarnContext(ctx, "rejected call, RPC not allowed", "method", method, "identity", identity.Name,
+			logging.KeyPeer, peerAddr(ctx))
+
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", identity.Name, method)
+	}
+
+	return NewContext(ctx, identity), nil
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+
+	return ""
+}
+
+// identityStream overrides the context of a stream with one carrying the identity.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx // the stream context is replaced, not stored.
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Wildcard allows every symbol or RPC.
+const Wildcard = "*"
+
+// Identity is an authenticated caller with the symbols and RPCs it may use.
+type Identity struct {
+	Name    string   `json:"-"`
+	Symbols []string `json:"symbols"`
+	// RPCs are full method names, e.g. /aggregator.AggregatorService/StreamCandlesticks.
+	RPCs []string `json:"rpcs"`
+}
+
+// AllowsSymbol reports whether the identity may read symbol.
+func (i Identity) AllowsSymbol(symbol string) bool {
+	return slices.Contains(i.Symbols, Wildcard) || slices.Contains(i.Symbols, strings.ToUpper(symbol))
+}
+
+// AllowsRPC reports whether the identity may call the full method name.
+func (i Identity) AllowsRPC(method string) bool {
+	return slices.Contains(i.RPCs, Wildcard) || slices.Contains(i.RPCs, method)
+}
+
+// Policy maps API keys and JWT subjects to identities.
+type Policy struct {
+	// APIKeys maps the hex SHA-256 of an API key to an identity name, so the file holds no secret.
+	APIKeys    map[string]string   `json:"api_keys"`
+	Identities map[string]Identity `json:"identities"`
+}
+
+// LoadPolicy reads a JSON policy file.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth policy: %w", err)
+	}
+
+	var policy Policy
+
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse auth policy: %w", err)
+	}
+
+	for name, identity := range policy.Identities {
+		identity.Name = name
+
+		for i, symbol := range identity.Symbols {
+			identity.Symbols[i] = strings.ToUpper(symbol)
+		}
+
+		policy.Identities[name] = identity
+	}
+
+	for hash, name := range policy.APIKeys {
+		if _, ok := policy.Identities[name]; !ok {
+			return nil, fmt.Errorf("API key %s… maps to unknown identity %q", hash[:min(len(hash), 8)], name)
+		}
+	}
+
+	return &policy, nil
+}
+
+// identity returns the identity of name, false when the policy doesn't know it.
+func (p *Policy) identity(name string) (Identity, bool) {
+	identity, ok := p.Identities[name]
+
+	return identity, ok
+}
+
+// apiKeyIdentity returns the identity an API key belongs to.
+func (p *Policy) apiKeyIdentity(key string) (Identity, bool) {
+	sum := sha256.Sum256([]byte(key))
+
+	name, ok := p.APIKeys[hex.EncodeToString(sum[:])]
+	if !ok {
+		return Identity{}, false
+	}
+
+	return p.identity(name)
+}
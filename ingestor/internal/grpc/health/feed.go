@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var logger = logging.Component("health")
+
+// FeedMonitor reports services as SERVING only while trades keep arriving from the exchange feed. The server
+// itself, the empty service name, stays SERVING as long as the process runs.
+type FeedMonitor struct {
+	server     *health.Server
+	services   []string
+	staleAfter time.Duration
+
+	mu        sync.Mutex
+	lastTrade time.Time
+	serving   bool
+}
+
+// NewFeedMonitor marks services NOT_SERVING until the first trade arrives.
+func NewFeedMonitor(server *health.Server, staleAfter time.Duration, services ...string) *FeedMonitor {
+	for _, service := range services {
+		server.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return &FeedMonitor{
+		server:     server,
+		services:   services,
+		staleAfter: staleAfter,
+	}
+}
+
+// TradeReceived records that the feed is live.
+func (m *FeedMonitor) TradeReceived() {
+	m.mu.Lock()
+	m.lastTrade = time.Now()
+	serving := m.serving
+	m.mu.Unlock()
+
+	if !serving {
+		m.update(time.Now())
+	}
+}
+
+// Run re-evaluates the feed every interval until ctx is done, then marks everything NOT_SERVING so clients
+// stop routing to a server shutting down.
+func (m *FeedMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.server.Shutdown()
+
+			return
+		case now := <-ticker.C:
+			m.update(now)
+		}
+	}
+}
+
+func (m *FeedMonitor) update(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	serving := !m.lastTrade.IsZero() && now.Sub(m.lastTrade) <= m.staleAfter
+	if serving == m.serving {
+		return
+	}
+
+	m.serving = serving
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	} else {
+		logger.Warn("no recent trades from the Binance feed, reporting NOT_SERVING", "last_trade", m.lastTrade)
+	}
+
+	for _, service := range m.services {
+		m.server.SetServingStatus(service, status)
+	}
+}
@@ -0,0 +1,64 @@
+package health_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	feedhealth "github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/health"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const service = "aggregator.AggregatorService"
+
+func check(t *testing.T, server *health.Server, name string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: name})
+	if err != nil {
+		t.Fatalf("Check(%q) failed: %v", name, err)
+	}
+
+	return resp.GetStatus()
+}
+
+func TestFeedMonitor(t *testing.T) {
+	server := health.NewServer()
+	monitor := feedhealth.NewFeedMonitor(server, 50*time.Millisecond, service)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		monitor.Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	if got := check(t, server, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING before the first trade, got %s", got)
+	}
+
+	if got := check(t, server, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected the server to be SERVING regardless of the feed, got %s", got)
+	}
+
+	monitor.TradeReceived()
+
+	if got := check(t, server, service); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING after a trade, got %s", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if got := check(t, server, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING once the feed went stale, got %s", got)
+	}
+
+	cancel()
+	<-done
+
+	if got := check(t, server, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING after shutdown, got %s", got)
+	}
+}
@@ -0,0 +1,220 @@
+package limits
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var logger = logging.Component("limits")
+
+const (
+	defaultStreamRetryAfter = 5 * time.Second
+	// idleClientTTL is how long the unary rate of a client is remembered after its last call.
+	idleClientTTL = 10 * time.Minute
+)
+
+// Config holds the limits, zero disables a limit.
+type Config struct {
+	// MaxStreamsPerClient is the number of concurrent streams one identity, or IP without authentication, may open.
+	MaxStreamsPerClient int
+	// MaxStreams is the number of concurrent streams of all clients.
+	MaxStreams int
+	// UnaryRate is the number of unary calls per second one client may make, with bursts of UnaryBurst.
+	UnaryRate  float64
+	UnaryBurst int
+	// StreamRetryAfter is the retry hint of rejected streams, which can't know when a slot frees up.
+	StreamRetryAfter time.Duration
+}
+
+type client struct {
+	streams  int
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter rejects calls over the configured limits with RESOURCE_EXHAUSTED and a RetryInfo detail.
+type Limiter struct {
+	config Config
+
+	mu        sync.Mutex
+	streams   int
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	if cfg.StreamRetryAfter <= 0 {
+		cfg.StreamRetryAfter = defaultStreamRetryAfter
+	}
+
+	if cfg.UnaryRate > 0 && cfg.UnaryBurst <= 0 {
+		cfg.UnaryBurst = int(math.Ceil(cfg.UnaryRate))
+	}
+
+	return &Limiter{
+		config:  cfg,
+		clients: make(map[string]*client),
+		now:     time.Now,
+	}
+}
+
+// UnaryServerInterceptor limits the unary call rate of each client. Chain it after authentication, so
+// authenticated clients are limited by identity rather than IP.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if auth.IsPublicMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		if wait := l.reserveCall(clientKey(ctx)); wait > 0 {
+			return nil, exhausted(wait, "rate limit of %.2f calls per second exceeded for %s", l.config.UnaryRate,
+				info.FullMethod)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits the concurrent streams of each client and of all clients. Chain it after
+// authentication, so authenticated clients are limited by identity rather than IP.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if auth.IsPublicMethod(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		key := clientKey(stream.Context())
+
+		if err := l.acquireStream(key); err != nil {
+			logger.WarnContext(stream.Context(), "rejected stream", "method", info.FullMethod, "client", key,
+				logging.Err(err))
+
+			return err
+		}
+		defer l.releaseStream(key)
+
+		return handler(srv, stream)
+	}
+}
+
+// reserveCall takes a unary call from the rate of key and returns how long to wait when there is none left.
+func (l *Limiter) reserveCall(key string) time.Duration {
+	if l.config.UnaryRate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	c := l.client(key, now)
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if wait := reservation.DelayFrom(now); wait > 0 {
+		reservation.CancelAt(now)
+
+		return wait
+	}
+
+	return 0
+}
+
+func (l *Limiter) acquireStream(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c := l.client(key, l.now())
+
+	if l.config.MaxStreams > 0 && l.streams >= l.config.MaxStreams {
+		return exhausted(l.config.StreamRetryAfter, "server is at its limit of %d streams", l.config.MaxStreams)
+	}
+
+	if l.config.MaxStreamsPerClient > 0 && c.streams >= l.config.MaxStreamsPerClient {
+		return exhausted(l.config.StreamRetryAfter, "%s is at its limit of %d streams", key,
+			l.config.MaxStreamsPerClient)
+	}
+
+	l.streams++
+	c.streams++
+
+	return nil
+}
+
+func (l *Limiter) releaseStream(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.streams--
+
+	c := l.client(key, l.now())
+	c.streams--
+}
+
+// client returns the state of key, forgetting clients idle for longer than idleClientTTL. Must hold mu.
+func (l *Limiter) client(key string, now time.Time) *client {
+	if now.Sub(l.lastSweep) > idleClientTTL {
+		for k, c := range l.clients {
+			if c.streams == 0 && now.Sub(c.lastSeen) > idleClientTTL {
+				delete(l.clients, k)
+			}
+		}
+
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(rate.Limit(l.config.UnaryRate), l.config.UnaryBurst)}
+		l.clients[key] = c
+	}
+
+	c.lastSeen = now
+
+	return c
+}
+
+// clientKey identifies the caller by its authenticated identity, or its IP without authentication.
+func clientKey(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return "identity:" + identity.Name
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+
+		return "ip:" + p.Addr.String()
+	}
+
+	return "unknown"
+}
+
+// exhausted returns a RESOURCE_EXHAUSTED status telling the client when to retry.
+func exhausted(retryAfter time.Duration, format string, args ...any) error {
+	retryAfter = max(retryAfter, time.Millisecond)
+	st := status.New(codes.ResourceExhausted,
+		fmt.Sprintf(format, args...)+fmt.Sprintf(", retry in %s", retryAfter.Round(time.Millisecond)))
+
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
@@ -0,0 +1,137 @@
+package limits_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/auth"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/grpc/limits"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func identityContext(name string) context.Context {
+	return auth.NewContext(context.Background(), auth.Identity{Name: name})
+}
+
+func peerContext(addr string) context.Context {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: tcpAddr})
+}
+
+// retryDelay returns the RetryInfo delay of a RESOURCE_EXHAUSTED error, failing the test for other errors.
+func retryDelay(t *testing.T, err error) time.Duration {
+	t.Helper()
+
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %s (%v)", st.Code(), err)
+	}
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+
+	t.Fatalf("expected a RetryInfo detail in %v", err)
+
+	return 0
+}
+
+func TestLimiter_Streams(t *testing.T) {
+	interceptor := limits.NewLimiter(limits.Config{
+		MaxStreamsPerClient: 2,
+		MaxStreams:          3,
+		StreamRetryAfter:    3 * time.Second,
+	}).StreamServerInterceptor()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	info := &grpc.StreamServerInfo{FullMethod: "/aggregator.AggregatorService/StreamCandlesticks"}
+
+	open := func(ctx context.Context) chan error {
+		done := make(chan error, 1)
+
+		go func() {
+			done <- interceptor(nil, &fakeStream{ctx: ctx}, info, func(any, grpc.ServerStream) error {
+				started <- struct{}{}
+				<-release
+
+				return nil
+			})
+		}()
+
+		select {
+		case <-started:
+		case err := <-done:
+			done <- err
+		}
+
+		return done
+	}
+
+	persistor := identityContext("persistor")
+	streams := []chan error{open(persistor), open(persistor)}
+
+	if got := retryDelay(t, <-open(persistor)); got != 3*time.Second {
+		t.Errorf("expected a retry hint of 3s, got %s", got)
+	}
+
+	// Other clients still get streams until the server wide limit.
+	streams = append(streams, open(peerContext("10.0.0.1:5000")))
+	retryDelay(t, <-open(peerContext("10.0.0.2:5000")))
+
+	close(release)
+
+	for _, done := range streams {
+		if err := <-done; err != nil {
+			t.Errorf("accepted stream failed: %v", err)
+		}
+	}
+
+	// Released slots can be taken again.
+	release = make(chan struct{})
+	done := open(persistor)
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Errorf("expected a stream after the others ended, got %v", err)
+	}
+}
+
+func TestLimiter_UnaryRate(t *testing.T) {
+	interceptor := limits.NewLimiter(limits.Config{UnaryRate: 1, UnaryBurst: 2}).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/candles.CandleService/ListCandles"}
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	for i := range 2 {
+		if _, err := interceptor(peerContext("10.0.0.1:5000"), nil, info, handler); err != nil {
+			t.Fatalf("call %d within the burst failed: %v", i, err)
+		}
+	}
+
+	_, err := interceptor(peerContext("10.0.0.1:6000"), nil, info, handler)
+	if got := retryDelay(t, err); got <= 0 || got > time.Second {
+		t.Errorf("expected a retry hint of at most 1s, got %s", got)
+	}
+
+	if _, err := interceptor(peerContext("10.0.0.2:5000"), nil, info, handler); err != nil {
+		t.Errorf("another client should not be limited: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package metrics holds the Prometheus metrics of the ingestor. Labels are limited to configured symbols, so
+// the number of series stays bounded by BINANCE_SYMBOLS rather than by clients or trades.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace         = "ingestor"
+	readHeaderTimeout = 5 * time.Second
+)
+
+var logger = logging.Component("metrics")
+
+// latencyBuckets span from a few milliseconds of exchange latency to a stalled pipeline.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+var (
+	TradesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "trades_received_total",
+		Help:      "Aggregated trades received from the Binance websocket.",
+	}, []string{"symbol"})
+
+	WebsocketConnects = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_connects_total",
+		Help:      "Connections opened to the Binance websocket, reconnects included.",
+	})
+
+	ParseErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_parse_errors_total",
+		Help:      "Websocket messages that could not be decoded as aggregated trades.",
+	})
+
+	AggregationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "aggregation_errors_total",
+		Help:      "Trades the aggregator rejected.",
+	}, []string{"symbol"})
+
+	EventLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_latency_seconds",
+		Help:      "Time from the Binance event time to the trade being received.",
+		Buckets:   latencyBuckets,
+	}, []string{"symbol"})
+
+	TradeToCandleLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "trade_to_candle_latency_seconds",
+		Help:      "Time from the Binance trade time to the trade being aggregated into its candle.",
+		Buckets:   latencyBuckets,
+	}, []string{"symbol"})
+
+	CandlesEmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "candles_emitted_total",
+		Help:      "Completed candles broadcast to the gRPC subscribers.",
+	}, []string{"symbol"})
+
+	StreamsOverflowed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "streams_overflowed_total",
+		Help:      "Streams ended because they fell a full buffer of candles behind.",
+	})
+
+	Subscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subscribers",
+		Help:      "Connected candlestick streams.",
+	})
+
+	AlertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_fired_total",
+		Help:      "Alert rules that fired.",
+	}, []string{"symbol"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Alert webhook deliveries by result: delivered, failed after the retries, or dropped.",
+	}, []string{"result"})
+
+	SyntheticStale = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "synthetic_stale",
+		Help:      "1 while a synthetic symbol isn't priced because a component is stale or didn't trade yet, 0 otherwise.",
+	}, []string{"symbol"})
+
+	SubscriberLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "subscriber_lag_candles",
+		Help:      "Candles buffered for a subscriber when a new one is broadcast to it.",
+		Buckets:   []float64{0, 1, 4, 16, 64, 128, 256},
+	})
+)
+
+// Server serves the metrics over HTTP.
+type Server struct {
+	server *http.Server
+}
+
+func NewServer(port uint16) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	return &Server{
+		server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+func (s *Server) Start() error {
+	logger.Info("metrics serving", "addr", s.server.Addr)
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
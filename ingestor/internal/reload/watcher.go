@@ -0,0 +1,126 @@
+// Package reload triggers a reload of the configuration when its file changes or the process receives SIGHUP.
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+// defaultDebounce collapses the events of one save, e.g. a truncate and a write, into one reload.
+const defaultDebounce = 500 * time.Millisecond
+
+// configMapData is the symlink Kubernetes swaps when a mounted ConfigMap is updated.
+const configMapData = "..data"
+
+var logger = logging.Component("reload")
+
+type options struct {
+	debounce time.Duration
+}
+
+type Option func(o *options)
+
+// WithDebounce sets how long the file must be left unchanged before reloading.
+func WithDebounce(debounce time.Duration) Option {
+	return func(o *options) {
+		o.debounce = debounce
+	}
+}
+
+// Watcher watches a config file and the SIGHUP signal.
+type Watcher struct {
+	path    string
+	options options
+}
+
+// NewWatcher watches the file at path, only SIGHUP when path is empty.
+func NewWatcher(path string, opts ...Option) *Watcher {
+	opt := options{debounce: defaultDebounce}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return &Watcher{path: path, options: opt}
+}
+
+// Run calls reload when the file changed or on SIGHUP, until ctx is done. The directory of the file is watched
+// rather than the file, so editors replacing it and ConfigMap updates are seen too.
+func (w *Watcher) Run(ctx context.Context, reload func()) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	defer signal.Stop(hangup)
+
+	var events <-chan fsnotify.Event
+
+	if w.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to watch config file: %w", err)
+		}
+
+		defer func() { _ = watcher.Close() }()
+
+		if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", w.path, err)
+		}
+
+		events = watcher.Events
+
+		go logErrors(ctx, watcher.Errors)
+	}
+
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			logger.Info("reloading configuration on SIGHUP")
+			reload()
+		case event := <-events:
+			if w.concerns(event) {
+				debounce.Reset(w.options.debounce)
+			}
+		case <-debounce.C:
+			logger.Info("reloading configuration after a change", "file", w.path)
+			reload()
+		}
+	}
+}
+
+func (w *Watcher) concerns(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Chmod) {
+		return false
+	}
+
+	return filepath.Clean(event.Name) == filepath.Clean(w.path) || filepath.Base(event.Name) == configMapData
+}
+
+func logErrors(ctx context.Context, errs <-chan error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+
+			logger.Warn("config file watch failed", logging.Err(err))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package reload_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/internal/reload"
+)
+
+func startWatcher(t *testing.T, path string) <-chan struct{} {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloads := make(chan struct{}, 10)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		err := reload.NewWatcher(path, reload.WithDebounce(50*time.Millisecond)).Run(ctx, func() {
+			reloads <- struct{}{}
+		})
+		if err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Let Run register the watch and the signal before changing anything.
+	time.Sleep(50 * time.Millisecond)
+
+	return reloads
+}
+
+func expectReloads(t *testing.T, reloads <-chan struct{}, want int) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+
+	for range want {
+		select {
+		case <-reloads:
+		case <-timeout:
+			t.Fatalf("expected %d reloads", want)
+		}
+	}
+
+	select {
+	case <-reloads:
+		t.Fatalf("expected only %d reloads", want)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcher_FileChanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ingestor.yaml")
+
+	if err := os.WriteFile(path, []byte("BINANCE_SYMBOLS: [BTCUSDT]\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	reloads := startWatcher(t, path)
+
+	// Other files of the directory are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("a: b\n"), 0o600); err != nil {
+		t.Fatalf("failed to write other file: %v", err)
+	}
+
+	// Replaced in several writes, reloaded once.
+	for _, content := range []string{"", "BINANCE_SYMBOLS: [BTCUSDT, ETHUSDT]\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+
+	expectReloads(t, reloads, 1)
+}
+
+func TestWatcher_SIGHUP(t *testing.T) {
+	reloads := startWatcher(t, "")
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP: %v", err)
+	}
+
+	expectReloads(t, reloads, 1)
+}
@@ -0,0 +1,523 @@
+package aggregator
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = logging.Component("aggregator")
+
+// Candlestick represents a 1-minute OHLCV candlestick, or an information-driven bar when BarType is set.
+type Candlestick struct {
+	Symbol    string    `json:"symbol"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    float64   `json:"volume"`
+	Timestamp time.Time `json:"timestamp"`
+	// Reconstructed is set on candlesticks restored from a snapshot after a restart, which miss the trades
+	// received while the ingestor was down.
+	Reconstructed bool `json:"reconstructed,omitempty"`
+	// Partial is set on candlesticks flushed on shutdown before their minute ended.
+	Partial bool `json:"partial,omitempty"`
+	// BarType, Threshold and CloseTime are set on information-driven bars, range bars and Renko bricks, which
+	// start at Timestamp, the time of their first trade, and end at CloseTime, the time of their last. Heikin-Ashi
+	// candlesticks only set BarType.
+	BarType   BarType    `json:"bar_type,omitempty"`
+	Threshold float64    `json:"threshold,omitempty"`
+	CloseTime *time.Time `json:"close_time,omitempty"`
+	// Footprint and SessionProfile are set on completed time bars of the symbols configured with footprints.
+	Footprint      *Footprint     `json:"footprint,omitempty"`
+	SessionProfile *VolumeProfile `json:"session_profile,omitempty"`
+	// SpanContext is the span of the trade that completed the candle, so its delivery continues that trace.
+	SpanContext trace.SpanContext `json:"-"`
+}
+
+// Aggregator aggregates trades into candlesticks. It is safe for concurrent use: every symbol has its own
+// partition, so trades of different symbols aggregate in parallel, and the completed candlesticks of a symbol are
+// sent on CandlestickChan in order.
+type Aggregator struct {
+	// mu guards partitions, each partition guards its own candlesticks.
+	mu              sync.RWMutex
+	partitions      map[string]*partition
+	CandlestickChan chan *Candlestick
+	barSpecs        []BarSpec
+	footprintSpecs  []FootprintSpec
+	valueArea       float64
+	// volumeless are the symbols traded without quantity.
+	volumeless []string
+}
+
+// partition holds the candlesticks of one symbol.
+type partition struct {
+	symbol string
+	mu     sync.Mutex
+	// sendMu orders the completed candlesticks of the symbol on CandlestickChan. It is locked before mu is
+	// unlocked, so a later completion waits for the send of an earlier one, without holding mu while sending.
+	sendMu sync.Mutex
+	// candlesticks are keyed by the Unix time of their minute.
+	candlesticks map[int64]*Candlestick
+	lastMinute   time.Time
+	// bars build the information-driven bars and chart transforms configured for the symbol from its trades.
+	bars []builder
+	// heikinAshi, when configured, transforms the completed time bars of the symbol.
+	heikinAshi *HeikinAshi
+	// footprints, when configured, builds the footprints of the time bars of the symbol.
+	footprints *footprints
+	// removed is set once the partition is no longer in Aggregator.partitions, trades then use a new one.
+	removed bool
+}
+
+type options struct {
+	bufferSize     int
+	barSpecs       []BarSpec
+	footprintSpecs []FootprintSpec
+	valueArea      float64
+	volumeless     []string
+}
+
+type Option func(o *options)
+
+// WithBufferSize buffers CandlestickChan, so callers that aggregate and drain it from the same goroutine
+// (e.g. backfills) don't block on completed candlesticks.
+func WithBufferSize(size int) Option {
+	return func(o *options) {
+		o.bufferSize = size
+	}
+}
+
+// WithBars also builds the information-driven bars of specs, sent on CandlestickChan along the time bars.
+func WithBars(specs ...BarSpec) Option {
+	return func(o *options) {
+		o.barSpecs = append(o.barSpecs, specs...)
+	}
+}
+
+// WithFootprints builds the footprints of the time bars of the symbols of specs, a symbol's own spec taking
+// precedence over AllSymbols.
+func WithFootprints(specs ...FootprintSpec) Option {
+	return func(o *options) {
+		o.footprintSpecs = append(o.footprintSpecs, specs...)
+	}
+}
+
+// WithValueArea sets the share of the volume in the value area of volume profiles, DefaultValueArea by default.
+func WithValueArea(share float64) Option {
+	return func(o *options) {
+		o.valueArea = share
+	}
+}
+
+// WithoutVolume declares symbols traded without quantity, e.g. synthetic symbols, which get no AllSymbols volume
+// or dollar bars as those would never close.
+func WithoutVolume(symbols ...string) Option {
+	return func(o *options) {
+		o.volumeless = append(o.volumeless, symbols...)
+	}
+}
+
+// NewAggregator creates a new Aggregator instance.
+func NewAggregator(opts ...Option) *Aggregator {
+	opt := options{valueArea: DefaultValueArea}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return &Aggregator{
+		partitions:      make(map[string]*partition),
+		CandlestickChan: make(chan *Candlestick, opt.bufferSize),
+		barSpecs:        opt.barSpecs,
+		footprintSpecs:  opt.footprintSpecs,
+		valueArea:       opt.valueArea,
+		volumeless:      opt.volumeless,
+	}
+}
+
+// AggregateTrade processes a TradeData and updates/creates a candlestick.
+func (a *Aggregator) AggregateTrade(trade binance.TradeData) (*Candlestick, error) {
+	priceFloat, err := strconv.ParseFloat(trade.Price, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse price: %w", err)
+	}
+
+	quantityFloat, err := strconv.ParseFloat(trade.Quantity, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quantity: %w", err)
+	}
+
+	tradeTime := time.UnixMilli(trade.TradeTime).UTC()
+
+	for {
+		p := a.partition(trade.Symbol)
+
+		p.mu.Lock()
+
+		// Removed while waiting for the lock, the trade starts a new partition.
+		if p.removed {
+			p.mu.Unlock()
+
+			continue
+		}
+
+		candle, completed := p.aggregate(tradeTime, priceFloat, quantityFloat, trade.IsMarketMaker)
+		if len(completed) == 0 {
+			p.mu.Unlock()
+
+			return candle, nil
+		}
+
+		p.sendMu.Lock()
+		p.mu.Unlock()
+
+		for _, completedCandle := range completed {
+			completedCandle.SpanContext = trade.SpanContext
+			a.CandlestickChan <- completedCandle
+
+			logger.Debug("completed candlestick", logging.KeySymbol, completedCandle.Symbol,
+				logging.KeyInterval, completedCandle.Interval(), "timestamp", completedCandle.Timestamp,
+				"close", completedCandle.Close, "volume", completedCandle.Volume)
+		}
+
+		p.sendMu.Unlock()
+
+		return candle, nil
+	}
+}
+
+// Interval describes what delimits the candlestick, e.g. 1m, tick:1000 or heikin_ashi.
+func (c *Candlestick) Interval() string {
+	switch {
+	case c.BarType == BarTime:
+		return "1m"
+	case c.Threshold == 0:
+		return string(c.BarType)
+	}
+
+	return fmt.Sprintf("%s:%s", c.BarType, strconv.FormatFloat(c.Threshold, 'f', -1, 64))
+}
+
+// partition returns the partition of symbol, creating it on its first trade.
+func (a *Aggregator) partition(symbol string) *partition {
+	a.mu.RLock()
+	p, ok := a.partitions[symbol]
+	a.mu.RUnlock()
+
+	if ok {
+		return p
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if p, ok = a.partitions[symbol]; !ok {
+		p = &partition{symbol: symbol, candlesticks: make(map[int64]*Candlestick)}
+
+		for _, spec := range a.barSpecs {
+			if spec.Symbol != symbol && spec.Symbol != AllSymbols {
+				continue
+			}
+
+			if spec.Symbol == AllSymbols && (spec.Type == BarVolume || spec.Type == BarDollar) &&
+				slices.Contains(a.volumeless, symbol) {
+				continue
+			}
+
+			if b := newBuilder(spec); b != nil {
+				p.bars = append(p.bars, b)
+			} else if p.heikinAshi == nil {
+				p.heikinAshi = NewHeikinAshi()
+			}
+		}
+
+		if tick, ok := a.footprintTick(symbol); ok {
+			p.footprints = newFootprints(tick, a.valueArea)
+		}
+
+		a.partitions[symbol] = p
+	}
+
+	return p
+}
+
+// footprintTick returns the tick of the footprints of symbol, if any.
+func (a *Aggregator) footprintTick(symbol string) (float64, bool) {
+	tick, found := 0.0, false
+
+	for _, spec := range a.footprintSpecs {
+		switch spec.Symbol {
+		case symbol:
+			return spec.Tick, true
+		case AllSymbols:
+			tick, found = spec.Tick, true
+		}
+	}
+
+	return tick, found
+}
+
+// detach removes the partitions of symbols, every symbol when none are given, and returns them ordered by
+// symbol. Trades of those symbols waiting for a partition's lock start a new one.
+func (a *Aggregator) detach(symbols ...string) []*partition {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(symbols) == 0 {
+		for symbol := range a.partitions {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	slices.Sort(symbols)
+
+	var detached []*partition
+
+	for _, symbol := range symbols {
+		if p, ok := a.partitions[symbol]; ok {
+			delete(a.partitions, symbol)
+			detached = append(detached, p)
+		}
+	}
+
+	return detached
+}
+
+// sorted returns the partitions ordered by symbol, for reading them without holding a.mu.
+func (a *Aggregator) sorted() []*partition {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	symbols := make([]string, 0, len(a.partitions))
+	for symbol := range a.partitions {
+		symbols = append(symbols, symbol)
+	}
+
+	slices.Sort(symbols)
+
+	partitions := make([]*partition, 0, len(symbols))
+	for _, symbol := range symbols {
+		partitions = append(partitions, a.partitions[symbol])
+	}
+
+	return partitions
+}
+
+// aggregate adds a trade to the candlestick of its minute, its footprint and the information-driven bars,
+// returning a copy of the candlestick and the candlesticks the trade completed: the one of the previous minute,
+// its Heikin-Ashi candlestick and the bars reaching their threshold. The caller holds mu.
+func (p *partition) aggregate(tradeTime time.Time, priceFloat, quantityFloat float64, buyerMaker bool) (
+	*Candlestick, []*Candlestick) {
+	var completed []*Candlestick
+
+	minuteStart := tradeTime.Truncate(time.Minute)
+
+	if !minuteStart.Equal(p.lastMinute) && !p.lastMinute.IsZero() {
+		if prev, ok := p.candlesticks[p.lastMinute.Unix()]; ok {
+			if p.footprints != nil {
+				p.footprints.complete(prev)
+			}
+
+			completed = append(completed, prev)
+
+			if p.heikinAshi != nil {
+				completed = append(completed, p.heikinAshi.Next(prev))
+			}
+
+			delete(p.candlesticks, p.lastMinute.Unix())
+		}
+	}
+
+	for _, b := range p.bars {
+		completed = append(completed, b.Add(p.symbol, tradeTime, priceFloat, quantityFloat)...)
+	}
+
+	p.lastMinute = minuteStart
+
+	if p.footprints != nil {
+		p.footprints.add(minuteStart, priceFloat, quantityFloat, buyerMaker)
+	}
+
+	candle, exists := p.candlesticks[minuteStart.Unix()]
+	if !exists {
+		candle = &Candlestick{
+			Symbol:    p.symbol,
+			Open:      priceFloat,
+			High:      priceFloat,
+			Low:       priceFloat,
+			Close:     priceFloat,
+			Volume:    0.0,
+			Timestamp: minuteStart,
+		}
+		p.candlesticks[minuteStart.Unix()] = candle
+		candle.Volume += quantityFloat
+	} else {
+		candle.High = maxFloat64(candle.High, priceFloat)
+		candle.Low = minFloat64(candle.Low, priceFloat)
+		candle.Close = priceFloat
+		candle.Volume += quantityFloat
+	}
+
+	// A copy, as later trades of the symbol may update the candlestick from other goroutines.
+	current := *candle
+
+	return &current, completed
+}
+
+// drain marks the partition removed and returns its time bars ordered by time, with their footprints.
+// Information-driven bars that didn't reach their threshold and the chart transforms are dropped. The caller
+// holds mu.
+func (p *partition) drain() []*Candlestick {
+	candles := make([]*Candlestick, 0, len(p.candlesticks))
+
+	for _, candle := range p.candlesticks {
+		candles = append(candles, candle)
+	}
+
+	slices.SortFunc(candles, compareCandlesticks)
+
+	if p.footprints != nil {
+		for _, candle := range candles {
+			p.footprints.complete(candle)
+		}
+	}
+
+	p.candlesticks, p.bars, p.heikinAshi, p.footprints = nil, nil, nil, nil
+	p.removed = true
+
+	return candles
+}
+
+// Remove finalizes the candlesticks still being aggregated for symbols, e.g. when they are no longer traded,
+// and sends them on CandlestickChan marked as partial, ordered by symbol and time, as trades of their minutes may
+// still follow. Later trades of the symbols start new candlesticks.
+func (a *Aggregator) Remove(symbols ...string) {
+	if len(symbols) == 0 {
+		return
+	}
+
+	for _, p := range a.detach(symbols...) {
+		p.mu.Lock()
+		candles := p.drain()
+		// After the candlesticks of the symbol already completed.
+		p.sendMu.Lock()
+		p.mu.Unlock()
+
+		for _, candle := range candles {
+			candle.Partial = true
+			a.CandlestickChan <- candle
+			logger.Info("finalized candlestick of a removed symbol", logging.KeySymbol, candle.Symbol,
+				logging.KeyInterval, "1m", "timestamp", candle.Timestamp, "close", candle.Close, "volume", candle.Volume)
+		}
+
+		p.sendMu.Unlock()
+	}
+}
+
+// Forming returns copies of the candlesticks still being aggregated, ordered by symbol and time, each symbol's
+// Heikin-Ashi candlestick and information-driven bars following its time bars.
+func (a *Aggregator) Forming() []Candlestick {
+	var candles []Candlestick
+
+	for _, p := range a.sorted() {
+		p.mu.Lock()
+		timeBars := p.forming()
+		candles = append(candles, timeBars...)
+
+		if p.heikinAshi != nil && len(timeBars) > 0 {
+			candles = append(candles, *p.heikinAshi.Peek(&timeBars[len(timeBars)-1]))
+		}
+
+		for _, b := range p.bars {
+			if candle := b.Forming(); candle != nil {
+				candles = append(candles, *candle)
+			}
+		}
+
+		p.mu.Unlock()
+	}
+
+	return candles
+}
+
+// forming returns copies of the time bars of the partition ordered by time. The caller holds mu.
+func (p *partition) forming() []Candlestick {
+	candles := make([]Candlestick, 0, len(p.candlesticks))
+
+	for _, candle := range p.candlesticks {
+		candles = append(candles, *candle)
+	}
+
+	slices.SortFunc(candles, func(x, y Candlestick) int {
+		return compareCandlesticks(&x, &y)
+	})
+
+	return candles
+}
+
+// Flush removes and returns the candlesticks still being aggregated, ordered by symbol. It is meant for callers
+// that know no more trades of those minutes will arrive, e.g. at the end of a backfilled range.
+func (a *Aggregator) Flush() []*Candlestick {
+	var candles []*Candlestick
+
+	for _, p := range a.detach() {
+		p.mu.Lock()
+		candles = append(candles, p.drain()...)
+		p.mu.Unlock()
+	}
+
+	return candles
+}
+
+// FlushPartial removes the candlesticks still being aggregated and sends them on CandlestickChan marked as
+// partial, ordered by symbol, returning how many were sent. It is meant for shutdown, after the last trade.
+func (a *Aggregator) FlushPartial() int {
+	candles := a.Flush()
+
+	for _, candle := range candles {
+		candle.Partial = true
+		a.CandlestickChan <- candle
+		logger.Info("flushed partial candlestick", logging.KeySymbol, candle.Symbol, logging.KeyInterval, "1m",
+			"timestamp", candle.Timestamp, "close", candle.Close, "volume", candle.Volume)
+	}
+
+	return len(candles)
+}
+
+// Close closes CandlestickChan, ending the streams of its consumers once they sent what they received. No trade
+// may be aggregated after.
+func (a *Aggregator) Close() {
+	close(a.CandlestickChan)
+}
+
+func compareCandlesticks(x, y *Candlestick) int {
+	if x.Symbol != y.Symbol {
+		return strings.Compare(x.Symbol, y.Symbol)
+	}
+
+	return x.Timestamp.Compare(y.Timestamp)
+}
+
+func maxFloat64(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func minFloat64(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
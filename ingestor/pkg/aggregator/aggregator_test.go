@@ -0,0 +1,646 @@
+package aggregator_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+func TestAggregator_AggregateTrade_NewCandlestick(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Now().UTC().Truncate(time.Minute)
+
+	tradeData := binance.TradeData{
+		Symbol:    "BTCUSDT",
+		Price:     "100.0",
+		Quantity:  "1.0",
+		TradeTime: tradeTime.UnixMilli(),
+	}
+
+	candle, err := agg.AggregateTrade(tradeData)
+	if err != nil {
+		t.Fatalf("aggregateTrade failed: %v", err)
+	}
+
+	expectedCandle := &aggregatorsvc.Candlestick{
+		Symbol:    "BTCUSDT",
+		Open:      100.0,
+		High:      100.0,
+		Low:       100.0,
+		Close:     100.0,
+		Volume:    1.0,
+		Timestamp: tradeTime,
+	}
+
+	if !reflect.DeepEqual(candle, expectedCandle) {
+		t.Errorf("Aggregated candlestick is incorrect. \ngot: %#v \nwant: %#v", candle, expectedCandle)
+	}
+}
+
+func TestAggregator_AggregateTrade_UpdateCandlestick(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Now().UTC().Truncate(time.Minute)
+
+	tradeData1 := binance.TradeData{
+		Symbol:    "BTCUSDT",
+		Price:     "100.0",
+		Quantity:  "1.0",
+		TradeTime: tradeTime.UnixMilli(),
+	}
+	_, _ = agg.AggregateTrade(tradeData1) // First trade - creates candle
+
+	tradeData2 := binance.TradeData{
+		Symbol:    "BTCUSDT",
+		Price:     "102.5",
+		Quantity:  "0.5",
+		TradeTime: tradeTime.UnixMilli(), // Same minute - update candle
+	}
+	updatedCandle, err := agg.AggregateTrade(tradeData2)
+	if err != nil {
+		t.Fatalf("AggregateTrade failed: %v", err)
+	}
+
+	expectedCandle := &aggregatorsvc.Candlestick{
+		Symbol:    "BTCUSDT",
+		Open:      100.0,
+		High:      102.5,
+		Low:       100.0,
+		Close:     102.5,
+		Volume:    1.5,
+		Timestamp: tradeTime,
+	}
+
+	if !reflect.DeepEqual(updatedCandle, expectedCandle) {
+		t.Errorf("Updated candlestick is incorrect. \ngot: %#v \nwant: %#v", updatedCandle, expectedCandle)
+	}
+}
+
+func TestAggregator_AggregateTrade_MultipleTradesInMinute(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Now().UTC().Truncate(time.Minute)
+
+	trades := []binance.TradeData{
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "99.5", Quantity: "0.8", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "101.0", Quantity: "1.2", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "100.5", Quantity: "0.5", TradeTime: tradeTime.UnixMilli()},
+	}
+
+	var lastCandle *aggregatorsvc.Candlestick
+	for _, trade := range trades {
+		candle, err := agg.AggregateTrade(trade)
+		if err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+		lastCandle = candle
+	}
+
+	expectedCandle := &aggregatorsvc.Candlestick{
+		Symbol:    "BTCUSDT",
+		Open:      100.0,
+		High:      101.0,
+		Low:       99.5,
+		Close:     100.5,
+		Volume:    0.0,
+		Timestamp: tradeTime,
+	}
+
+	if lastCandle != nil && (lastCandle.Symbol != expectedCandle.Symbol ||
+		lastCandle.Open != expectedCandle.Open ||
+		lastCandle.High != expectedCandle.High ||
+		lastCandle.Low != expectedCandle.Low ||
+		lastCandle.Close != expectedCandle.Close ||
+		!lastCandle.Timestamp.Equal(expectedCandle.Timestamp)) {
+		t.Errorf("Candlestick after multiple trades is incorrect (excluding volume). \ngot: %#v \nwant: %#v",
+			lastCandle, expectedCandle)
+	}
+}
+
+func TestAggregator_AggregateTrade_DifferentSymbols(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Now().UTC().Truncate(time.Minute)
+
+	tradeBTC := binance.TradeData{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()}
+	tradeETH := binance.TradeData{Symbol: "ETHUSDT", Price: "50.0", Quantity: "2.0", TradeTime: tradeTime.UnixMilli()}
+
+	candleBTC, errBTC := agg.AggregateTrade(tradeBTC)
+	if errBTC != nil {
+		t.Fatalf("aggregateTrade failed for BTCUSDT: %v", errBTC)
+	}
+	candleETH, errETH := agg.AggregateTrade(tradeETH)
+	if errETH != nil {
+		t.Fatalf("aggregateTrade failed for ETHUSDT: %v", errETH)
+	}
+
+	expectedCandleBTC := &aggregatorsvc.Candlestick{Symbol: "BTCUSDT", Open: 100.0, High: 100.0, Low: 100.0,
+		Close: 100.0, Volume: 1.0, Timestamp: tradeTime}
+	expectedCandleETH := &aggregatorsvc.Candlestick{Symbol: "ETHUSDT", Open: 50.0, High: 50.0, Low: 50.0,
+		Close: 50.0, Volume: 2.0, Timestamp: tradeTime}
+
+	if !reflect.DeepEqual(candleBTC, expectedCandleBTC) {
+		t.Errorf("aggregated candlestick for BTCUSDT is incorrect. \ngot: %#v \nwant: %#v",
+			candleBTC, expectedCandleBTC)
+	}
+	if !reflect.DeepEqual(candleETH, expectedCandleETH) {
+		t.Errorf("aggregated candlestick for ETHUSDT is incorrect. \ngot: %#v \nwant: %#v",
+			candleETH, expectedCandleETH)
+	}
+}
+
+func TestAggregator_AggregateTrade_ZeroQuantityTrade(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Now().UTC().Truncate(time.Minute)
+
+	tradeData := binance.TradeData{
+		Symbol:    "BTCUSDT",
+		Price:     "105.0",
+		Quantity:  "0.0", // Zero quantity trade
+		TradeTime: tradeTime.UnixMilli(),
+	}
+
+	candle, err := agg.AggregateTrade(tradeData)
+	if err != nil {
+		t.Fatalf("aggregateTrade failed: %v", err)
+	}
+
+	expectedCandle := &aggregatorsvc.Candlestick{
+		Symbol:    "BTCUSDT",
+		Open:      105.0,
+		High:      105.0,
+		Low:       105.0,
+		Close:     105.0,
+		Volume:    0.0,
+		Timestamp: tradeTime,
+	}
+
+	if !reflect.DeepEqual(candle, expectedCandle) {
+		t.Errorf("aggregated candlestick for zero quantity trade is incorrect. \ngot: %#v \nwant: %#v",
+			candle, expectedCandle)
+	}
+}
+
+func TestAggregator_AggregateTrade_ZeroPriceTrade(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Now().UTC().Truncate(time.Minute)
+
+	tradeData := binance.TradeData{
+		Symbol:    "BTCUSDT",
+		Price:     "0.0", // Zero price trade
+		Quantity:  "1.0",
+		TradeTime: tradeTime.UnixMilli(),
+	}
+
+	candle, err := agg.AggregateTrade(tradeData)
+	if err != nil {
+		t.Fatalf("AggregateTrade failed: %v", err)
+	}
+
+	expectedCandle := &aggregatorsvc.Candlestick{
+		Symbol:    "BTCUSDT",
+		Open:      0.0, // Open, High, Low, Close can be 0.0 if first trade is 0 price
+		High:      0.0,
+		Low:       0.0,
+		Close:     0.0,
+		Volume:    1.0, // Volume should still accumulate even with 0 price
+		Timestamp: tradeTime,
+	}
+
+	if !reflect.DeepEqual(candle, expectedCandle) {
+		t.Errorf("aggregated candlestick for zero price trade is incorrect. \ngot: %#v \nwant: %#v",
+			candle, expectedCandle)
+	}
+}
+
+func TestAggregator_AggregateTrade_VolumeAccumulation(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC).
+		Truncate(time.Minute)
+
+	trades := []binance.TradeData{
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()}, // Trade 1
+		{Symbol: "BTCUSDT", Price: "99.5", Quantity: "0.8", TradeTime: tradeTime.UnixMilli()},  // Trade 2
+		{Symbol: "BTCUSDT", Price: "101.0", Quantity: "1.2", TradeTime: tradeTime.UnixMilli()}, // Trade 3
+		{Symbol: "BTCUSDT", Price: "100.5", Quantity: "0.5", TradeTime: tradeTime.UnixMilli()}, // Trade 4
+	}
+
+	var lastCandle *aggregatorsvc.Candlestick
+	for _, trade := range trades {
+		candle, err := agg.AggregateTrade(trade)
+		if err != nil {
+			t.Fatalf("AggregateTrade failed for trade %+v: %v", trade, err)
+		}
+		lastCandle = candle
+	}
+
+	expectedCandle := &aggregatorsvc.Candlestick{
+		Symbol:    "BTCUSDT",
+		Open:      100.0,     // Price of the first trade
+		High:      101.0,     // Highest price among all trades
+		Low:       99.5,      // Lowest price among all trades
+		Close:     100.5,     // Price of the last trade
+		Volume:    3.5,       // Sum of quantities (1.0 + 0.8 + 1.2 + 0.5)
+		Timestamp: tradeTime, // Start of the minute
+	}
+
+	if !reflect.DeepEqual(lastCandle, expectedCandle) {
+		t.Errorf("candlestick after multiple trades is incorrect. \ngot: %#v \nwant: %#v",
+			lastCandle, expectedCandle)
+	}
+
+	if lastCandle == nil {
+		t.Errorf("expected Candlestick to be non-nil")
+
+		return
+	}
+
+	if lastCandle.Symbol != expectedCandle.Symbol {
+		t.Errorf("symbol mismatch: got %s, want %s", lastCandle.Symbol, expectedCandle.Symbol)
+	}
+	if lastCandle.Open != expectedCandle.Open {
+		t.Errorf("open price mismatch: got %.2f, want %.2f", lastCandle.Open, expectedCandle.Open)
+	}
+	if lastCandle.High != expectedCandle.High {
+		t.Errorf("high price mismatch: got %.2f, want %.2f", lastCandle.High, expectedCandle.High)
+	}
+	if lastCandle.Low != expectedCandle.Low {
+		t.Errorf("low price mismatch: got %.2f, want %.2f", lastCandle.Low, expectedCandle.Low)
+	}
+	if lastCandle.Close != expectedCandle.Close {
+		t.Errorf("close price mismatch: got %.2f, want %.2f", lastCandle.Close, expectedCandle.Close)
+	}
+	if lastCandle.Volume != expectedCandle.Volume {
+		t.Errorf("volume mismatch: got %.2f, want %.2f", lastCandle.Volume, expectedCandle.Volume)
+	}
+	if !lastCandle.Timestamp.Equal(expectedCandle.Timestamp) {
+		t.Errorf("Timestamp mismatch: got %v, want %v", lastCandle.Timestamp, expectedCandle.Timestamp)
+	}
+}
+
+func TestAggregator_Flush(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(1))
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	trades := []binance.TradeData{
+		{Symbol: "ETHUSDT", Price: "50.0", Quantity: "2.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "101.0", Quantity: "1.0", TradeTime: tradeTime.Add(time.Minute).UnixMilli()},
+	}
+
+	for _, trade := range trades {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	completed := <-agg.CandlestickChan
+	if completed.Symbol != "BTCUSDT" || !completed.Timestamp.Equal(tradeTime) {
+		t.Errorf("unexpected completed candlestick: %#v", completed)
+	}
+
+	flushed := agg.Flush()
+
+	expected := []*aggregatorsvc.Candlestick{
+		{Symbol: "BTCUSDT", Open: 101.0, High: 101.0, Low: 101.0, Close: 101.0, Volume: 1.0,
+			Timestamp: tradeTime.Add(time.Minute)},
+		{Symbol: "ETHUSDT", Open: 50.0, High: 50.0, Low: 50.0, Close: 50.0, Volume: 2.0, Timestamp: tradeTime},
+	}
+
+	if !reflect.DeepEqual(flushed, expected) {
+		t.Errorf("flushed candlesticks are incorrect. \ngot: %#v \nwant: %#v", flushed, expected)
+	}
+
+	if remaining := agg.Flush(); len(remaining) != 0 {
+		t.Errorf("expected nothing left after a flush, got %d candlesticks", len(remaining))
+	}
+}
+
+func TestAggregator_Forming(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(1))
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for _, trade := range []binance.TradeData{
+		{Symbol: "ETHUSDT", Price: "50.0", Quantity: "2.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "101.0", Quantity: "1.0", TradeTime: tradeTime.Add(time.Minute).UnixMilli()},
+	} {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	forming := agg.Forming()
+
+	expected := []aggregatorsvc.Candlestick{
+		{Symbol: "BTCUSDT", Open: 101.0, High: 101.0, Low: 101.0, Close: 101.0, Volume: 1.0,
+			Timestamp: tradeTime.Add(time.Minute)},
+		{Symbol: "ETHUSDT", Open: 50.0, High: 50.0, Low: 50.0, Close: 50.0, Volume: 2.0, Timestamp: tradeTime},
+	}
+
+	if !reflect.DeepEqual(forming, expected) {
+		t.Errorf("forming candlesticks are incorrect. \ngot: %#v \nwant: %#v", forming, expected)
+	}
+
+	// Forming leaves the candlesticks to be completed.
+	if flushed := agg.Flush(); len(flushed) != 2 {
+		t.Errorf("expected 2 candlesticks still forming, got %d", len(flushed))
+	}
+}
+
+func TestAggregator_Remove(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(2))
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for _, trade := range []binance.TradeData{
+		{Symbol: "ETHUSDT", Price: "50.0", Quantity: "2.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+	} {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	agg.Remove("ETHUSDT", "SOLUSDT")
+
+	// The minute may still be traded, so the candlestick must not replace a complete one.
+	if finalized := <-agg.CandlestickChan; finalized.Symbol != "ETHUSDT" || finalized.Volume != 2.0 ||
+		!finalized.Partial {
+		t.Errorf("unexpected finalized candlestick: %#v", finalized)
+	}
+
+	if forming := agg.Forming(); len(forming) != 1 || forming[0].Symbol != "BTCUSDT" {
+		t.Errorf("expected only BTCUSDT still forming, got %#v", forming)
+	}
+
+	// A later trade of a removed symbol starts a new candlestick rather than completing the removed one.
+	if _, err := agg.AggregateTrade(binance.TradeData{
+		Symbol: "ETHUSDT", Price: "51.0", Quantity: "1.0", TradeTime: tradeTime.Add(time.Minute).UnixMilli(),
+	}); err != nil {
+		t.Fatalf("aggregateTrade failed: %v", err)
+	}
+
+	select {
+	case candle := <-agg.CandlestickChan:
+		t.Errorf("expected no completed candlestick, got %#v", candle)
+	default:
+	}
+}
+
+func TestAggregator_SnapshotRestore(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator()
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for _, trade := range []binance.TradeData{
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "101.0", Quantity: "2.0", TradeTime: tradeTime.Add(time.Second).UnixMilli()},
+		{Symbol: "ETHUSDT", Price: "50.0", Quantity: "3.0", TradeTime: tradeTime.UnixMilli()},
+	} {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	if _, err := aggregatorsvc.LoadSnapshot(path); !errors.Is(err, aggregatorsvc.ErrNoSnapshot) {
+		t.Fatalf("expected ErrNoSnapshot before saving, got %v", err)
+	}
+
+	if err := aggregatorsvc.SaveSnapshot(path, agg.Snapshot(tradeTime.Add(2*time.Second))); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	snapshot, err := aggregatorsvc.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+
+	restored := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(1))
+
+	if n := restored.Restore(snapshot.Symbols(func(symbol string) bool { return symbol == "BTCUSDT" })); n != 1 {
+		t.Fatalf("expected 1 restored candlestick, got %d", n)
+	}
+
+	// A trade of the next minute completes the restored candlestick, which is marked as reconstructed.
+	if _, err := restored.AggregateTrade(binance.TradeData{
+		Symbol: "BTCUSDT", Price: "102.0", Quantity: "1.0", TradeTime: tradeTime.Add(time.Minute).UnixMilli(),
+	}); err != nil {
+		t.Fatalf("aggregateTrade failed: %v", err)
+	}
+
+	completed := <-restored.CandlestickChan
+	want := aggregatorsvc.Candlestick{
+		Symbol: "BTCUSDT", Open: 100, High: 101, Low: 100, Close: 101, Volume: 3, Timestamp: tradeTime,
+		Reconstructed: true,
+	}
+
+	if !reflect.DeepEqual(*completed, want) {
+		t.Errorf("unexpected completed candlestick.\ngot: %#v\nwant: %#v", *completed, want)
+	}
+
+	if forming := restored.Forming(); len(forming) != 1 || forming[0].Reconstructed {
+		t.Errorf("expected a new candlestick not marked as reconstructed, got %#v", forming)
+	}
+}
+
+func TestAggregator_FlushPartial(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(2))
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for _, trade := range []binance.TradeData{
+		{Symbol: "ETHUSDT", Price: "50.0", Quantity: "2.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+	} {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	if n := agg.FlushPartial(); n != 2 {
+		t.Fatalf("expected 2 flushed candlesticks, got %d", n)
+	}
+
+	agg.Close()
+
+	var symbols []string
+
+	for candle := range agg.CandlestickChan {
+		if !candle.Partial {
+			t.Errorf("expected a partial candlestick, got %#v", candle)
+		}
+
+		symbols = append(symbols, candle.Symbol)
+	}
+
+	if !reflect.DeepEqual(symbols, []string{"BTCUSDT", "ETHUSDT"}) {
+		t.Errorf("expected the candlesticks ordered by symbol, got %v", symbols)
+	}
+
+	if forming := agg.Forming(); len(forming) != 0 {
+		t.Errorf("expected no candlestick still forming, got %#v", forming)
+	}
+}
+
+func TestAggregator_ConcurrentSymbols(t *testing.T) {
+	const (
+		symbols           = 8
+		minutes           = 10
+		writersPerMinute  = 4
+		tradesPerWriter   = 25
+		tradesPerCandle   = writersPerMinute * tradesPerWriter
+		expectedCompleted = minutes - 1
+	)
+
+	agg := aggregatorsvc.NewAggregator()
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+	completed := make(map[string][]*aggregatorsvc.Candlestick)
+	consumed := make(chan struct{})
+
+	go func() {
+		defer close(consumed)
+
+		for candle := range agg.CandlestickChan {
+			completed[candle.Symbol] = append(completed[candle.Symbol], candle)
+		}
+	}()
+
+	// Reads the state while it is aggregated, as the admin server and the snapshots do.
+	stopReading := make(chan struct{})
+	readerDone := make(chan struct{})
+
+	go func() {
+		defer close(readerDone)
+
+		for {
+			select {
+			case <-stopReading:
+				return
+			default:
+				agg.Forming()
+				agg.Snapshot(time.Now())
+			}
+		}
+	}()
+
+	var symbolsWG sync.WaitGroup
+
+	for i := range symbols {
+		symbol := fmt.Sprintf("SYM%dUSDT", i)
+
+		symbolsWG.Add(1)
+
+		go func() {
+			defer symbolsWG.Done()
+
+			for minute := range minutes {
+				tradeTime := start.Add(time.Duration(minute) * time.Minute)
+
+				var writersWG sync.WaitGroup
+
+				for range writersPerMinute {
+					writersWG.Add(1)
+
+					go func() {
+						defer writersWG.Done()
+
+						for range tradesPerWriter {
+							if _, err := agg.AggregateTrade(binance.TradeData{
+								Symbol: symbol, Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli(),
+							}); err != nil {
+								t.Errorf("aggregateTrade failed: %v", err)
+							}
+						}
+					}()
+				}
+
+				writersWG.Wait()
+			}
+		}()
+	}
+
+	symbolsWG.Wait()
+	close(stopReading)
+	<-readerDone
+
+	flushed := agg.Flush()
+	agg.Close()
+	<-consumed
+
+	if len(flushed) != symbols {
+		t.Errorf("expected %d candlesticks still forming, got %d", symbols, len(flushed))
+	}
+
+	if len(completed) != symbols {
+		t.Fatalf("expected completed candlesticks of %d symbols, got %d", symbols, len(completed))
+	}
+
+	for symbol, candles := range completed {
+		if len(candles) != expectedCompleted {
+			t.Errorf("%s: expected %d completed candlesticks, got %d", symbol, expectedCompleted, len(candles))
+		}
+
+		for i, candle := range candles {
+			if want := start.Add(time.Duration(i) * time.Minute); !candle.Timestamp.Equal(want) {
+				t.Errorf("%s: expected candlestick %d at %v, got %v", symbol, i, want, candle.Timestamp)
+			}
+
+			if candle.Volume != tradesPerCandle {
+				t.Errorf("%s: expected a volume of %d, got %v", symbol, tradesPerCandle, candle.Volume)
+			}
+		}
+	}
+}
+
+func BenchmarkAggregator_AggregateTrade(b *testing.B) {
+	agg := aggregatorsvc.NewAggregator()
+	trade := binance.TradeData{
+		Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: time.Now().UnixMilli(),
+	}
+
+	b.ReportAllocs()
+
+	for range b.N {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAggregator_AggregateTrade_Parallel aggregates trades of one symbol per goroutine, which don't
+// contend, and of a single symbol shared by every goroutine.
+func BenchmarkAggregator_AggregateTrade_Parallel(b *testing.B) {
+	for name, symbolOf := range map[string]func(worker int64) string{
+		"symbol per goroutine": func(worker int64) string { return fmt.Sprintf("SYM%dUSDT", worker) },
+		"shared symbol":        func(int64) string { return "BTCUSDT" },
+	} {
+		b.Run(name, func(b *testing.B) {
+			agg := aggregatorsvc.NewAggregator()
+			tradeTime := time.Now().UnixMilli()
+
+			var workers atomic.Int64
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				trade := binance.TradeData{
+					Symbol: symbolOf(workers.Add(1)), Price: "100.0", Quantity: "1.0", TradeTime: tradeTime,
+				}
+
+				for pb.Next() {
+					if _, err := agg.AggregateTrade(trade); err != nil {
+						b.Error(err)
+
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BarType is what delimits a Candlestick. Time bars span a minute, information-driven bars span a threshold of
+// trades (tick), base asset volume (volume) or quote asset volume (dollar). Chart transforms derive range bars
+// and Renko bricks from trades, with the threshold as their range or box size, and Heikin-Ashi candlesticks from
+// the time bars.
+type BarType string
+
+// Bar types of Candlestick.BarType.
+const (
+	BarTime   BarType = ""
+	BarTick   BarType = "tick"
+	BarVolume BarType = "volume"
+	BarDollar BarType = "dollar"
+
+	BarRange      BarType = "range"
+	BarRenko      BarType = "renko"
+	BarHeikinAshi BarType = "heikin_ashi"
+)
+
+// AllSymbols is the symbol of a BarSpec applying to every symbol.
+const AllSymbols = "*"
+
+// BarSpec configures the information-driven bars or chart transforms of a symbol.
+type BarSpec struct {
+	// Symbol is the symbol the bars are built for, or AllSymbols.
+	Symbol string
+	Type   BarType
+	// Threshold is the trades, base volume or quote volume of a bar, a whole number for tick bars, or the price
+	// range or box size of range bars and Renko bricks. Heikin-Ashi candlesticks have none.
+	Threshold float64
+}
+
+// ParseBarSpec parses a spec written as SYMBOL:TYPE:THRESHOLD, e.g. BTCUSDT:volume:50 or *:tick:1000, or
+// SYMBOL:heikin_ashi.
+func ParseBarSpec(s string) (BarSpec, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 && strings.EqualFold(parts[1], string(BarHeikinAshi)) {
+		if parts[0] == "" {
+			return BarSpec{}, fmt.Errorf("bar %q has no symbol", s)
+		}
+
+		return BarSpec{Symbol: strings.ToUpper(parts[0]), Type: BarHeikinAshi}, nil
+	}
+
+	if len(parts) != 3 {
+		return BarSpec{}, fmt.Errorf("bar %q is not SYMBOL:TYPE:THRESHOLD", s)
+	}
+
+	spec := BarSpec{Symbol: strings.ToUpper(parts[0]), Type: BarType(strings.ToLower(parts[1]))}
+
+	if spec.Symbol == "" {
+		return BarSpec{}, fmt.Errorf("bar %q has no symbol", s)
+	}
+
+	switch spec.Type {
+	case BarTick, BarVolume, BarDollar, BarRange, BarRenko:
+	default:
+		return BarSpec{}, fmt.Errorf("bar %q has type %q, expected tick, volume, dollar, range or renko", s,
+			parts[1])
+	}
+
+	threshold, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil || threshold <= 0 || math.IsInf(threshold, 0) {
+		return BarSpec{}, fmt.Errorf("bar %q has threshold %q, expected a positive number", s, parts[2])
+	}
+
+	if spec.Type == BarTick && threshold != math.Trunc(threshold) {
+		return BarSpec{}, fmt.Errorf("bar %q has threshold %q, expected a whole number of trades", s, parts[2])
+	}
+
+	spec.Threshold = threshold
+
+	return spec, nil
+}
+
+func (s BarSpec) String() string {
+	if s.Type == BarHeikinAshi {
+		return fmt.Sprintf("%s:%s", s.Symbol, s.Type)
+	}
+
+	return fmt.Sprintf("%s:%s:%s", s.Symbol, s.Type, strconv.FormatFloat(s.Threshold, 'f', -1, 64))
+}
+
+// builder builds the bars of one spec for one symbol from its trades.
+type builder interface {
+	// Add adds a trade, returning the bars it completed.
+	Add(symbol string, tradeTime time.Time, price, quantity float64) []*Candlestick
+	// Forming returns a copy of the bar being built, nil when there is none.
+	Forming() *Candlestick
+}
+
+// newBuilder returns the builder of spec, nil for Heikin-Ashi candlesticks, which are built from time bars.
+func newBuilder(spec BarSpec) builder {
+	switch spec.Type {
+	case BarRange:
+		return NewRangeBars(spec.Threshold)
+	case BarRenko:
+		return NewRenko(spec.Threshold)
+	case BarHeikinAshi:
+		return nil
+	default:
+		return &bar{spec: spec}
+	}
+}
+
+// bar builds the information-driven bars of one spec for one symbol.
+type bar struct {
+	spec    BarSpec
+	current *Candlestick
+	// progress is how much of the threshold the current bar reached.
+	progress  float64
+	lastTrade time.Time
+}
+
+// measure returns how much a trade counts towards the threshold.
+func (b *bar) measure(price, quantity float64) float64 {
+	switch b.spec.Type {
+	case BarTick:
+		return 1
+	case BarVolume:
+		return quantity
+	default:
+		return price * quantity
+	}
+}
+
+// Add adds a trade to the current bar, returning the bars it completed. A trade crossing the threshold is
+// split: the share completing the bar is added to it, the rest to the next bars, all at the trade's price.
+func (b *bar) Add(symbol string, tradeTime time.Time, price, quantity float64) []*Candlestick {
+	var completed []*Candlestick
+
+	remaining := b.measure(price, quantity)
+	total := remaining
+
+	for {
+		if b.current == nil {
+			b.current = &Candlestick{
+				Symbol:    symbol,
+				Open:      price,
+				High:      price,
+				Low:       price,
+				Close:     price,
+				Timestamp: tradeTime,
+				BarType:   b.spec.Type,
+				Threshold: b.spec.Threshold,
+			}
+		}
+
+		part := min(remaining, b.spec.Threshold-b.progress)
+
+		b.current.High = maxFloat64(b.current.High, price)
+		b.current.Low = minFloat64(b.current.Low, price)
+		b.current.Close = price
+		b.lastTrade = tradeTime
+
+		// Trades of no quantity count for nothing, but still set the close.
+		if total > 0 {
+			b.current.Volume += quantity * part / total
+		}
+
+		b.progress += part
+		remaining -= part
+
+		if b.progress >= b.spec.Threshold {
+			completed = append(completed, b.closed())
+			b.current, b.progress = nil, 0
+		}
+
+		if remaining <= 0 {
+			return completed
+		}
+	}
+}
+
+// closed returns the current bar with the time of its last trade.
+func (b *bar) closed() *Candlestick {
+	closeTime := b.lastTrade
+	b.current.CloseTime = &closeTime
+
+	return b.current
+}
+
+// Forming returns a copy of the current bar, nil when no trade started it.
+func (b *bar) Forming() *Candlestick {
+	if b.current == nil {
+		return nil
+	}
+
+	candle := *b.current
+	closeTime := b.lastTrade
+	candle.CloseTime = &closeTime
+
+	return &candle
+}
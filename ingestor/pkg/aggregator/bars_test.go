@@ -0,0 +1,122 @@
+package aggregator_test
+
+import (
+	"testing"
+	"time"
+
+	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+func TestParseBarSpec(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]aggregatorsvc.BarSpec{
+		"btcusdt:tick:1000":   {Symbol: "BTCUSDT", Type: aggregatorsvc.BarTick, Threshold: 1000},
+		"*:Volume:0.5":        {Symbol: "*", Type: aggregatorsvc.BarVolume, Threshold: 0.5},
+		"ETHUSDT:dollar:1e6":  {Symbol: "ETHUSDT", Type: aggregatorsvc.BarDollar, Threshold: 1e6},
+		"BTCUSDT:range:25":    {Symbol: "BTCUSDT", Type: aggregatorsvc.BarRange, Threshold: 25},
+		"*:renko:0.5":         {Symbol: "*", Type: aggregatorsvc.BarRenko, Threshold: 0.5},
+		"btcusdt:Heikin_Ashi": {Symbol: "BTCUSDT", Type: aggregatorsvc.BarHeikinAshi},
+	} {
+		got, err := aggregatorsvc.ParseBarSpec(input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", input, err)
+
+			continue
+		}
+
+		if got != want {
+			t.Errorf("%s: expected %+v, got %+v", input, want, got)
+		}
+	}
+
+	for _, input := range []string{"BTCUSDT:tick", ":tick:10", "BTCUSDT:kagi:10", "BTCUSDT:tick:1.5",
+		"BTCUSDT:volume:0", "BTCUSDT:volume:-1", "BTCUSDT:dollar:abc", "BTCUSDT:renko", ":heikin_ashi",
+		"BTCUSDT:heikin_ashi:1"} {
+		if _, err := aggregatorsvc.ParseBarSpec(input); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestAggregator_Bars(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(10), aggregatorsvc.WithBars(
+		aggregatorsvc.BarSpec{Symbol: "BTCUSDT", Type: aggregatorsvc.BarTick, Threshold: 2},
+		aggregatorsvc.BarSpec{Symbol: "*", Type: aggregatorsvc.BarVolume, Threshold: 5},
+		aggregatorsvc.BarSpec{Symbol: "ETHUSDT", Type: aggregatorsvc.BarDollar, Threshold: 1000},
+	), aggregatorsvc.WithoutVolume("ETHBTC"))
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for i, trade := range []binance.TradeData{
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "3.0"},
+		{Symbol: "BTCUSDT", Price: "102.0", Quantity: "4.0"},
+		{Symbol: "ETHUSDT", Price: "100.0", Quantity: "25.0"},
+		{Symbol: "ETHBTC", Price: "0.03", Quantity: "0"},
+	} {
+		trade.TradeTime = tradeTime.Add(time.Duration(i) * time.Second).UnixMilli()
+
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	type bar struct {
+		symbol  string
+		barType aggregatorsvc.BarType
+		volume  float64
+		open    float64
+		close   float64
+	}
+
+	// The second BTCUSDT trade completes the tick bar and splits across the volume bars, 2 of its 4 units
+	// complete the first one. The ETHUSDT trade is 2500 in quote volume, so it completes 2 dollar bars and 5 of
+	// its 25 units are left for the next one, as well as completing 5 volume bars.
+	want := []bar{
+		{"BTCUSDT", aggregatorsvc.BarTick, 7, 100, 102},
+		{"BTCUSDT", aggregatorsvc.BarVolume, 5, 100, 102},
+		{"ETHUSDT", aggregatorsvc.BarVolume, 5, 100, 100},
+		{"ETHUSDT", aggregatorsvc.BarVolume, 5, 100, 100},
+		{"ETHUSDT", aggregatorsvc.BarVolume, 5, 100, 100},
+		{"ETHUSDT", aggregatorsvc.BarVolume, 5, 100, 100},
+		{"ETHUSDT", aggregatorsvc.BarVolume, 5, 100, 100},
+		{"ETHUSDT", aggregatorsvc.BarDollar, 10, 100, 100},
+		{"ETHUSDT", aggregatorsvc.BarDollar, 10, 100, 100},
+	}
+
+	for _, w := range want {
+		select {
+		case candle := <-agg.CandlestickChan:
+			got := bar{candle.Symbol, candle.BarType, candle.Volume, candle.Open, candle.Close}
+			if got != w {
+				t.Errorf("expected %+v, got %+v", w, got)
+			}
+
+			if candle.CloseTime == nil || candle.CloseTime.Before(candle.Timestamp) {
+				t.Errorf("expected a close time after %v, got %v", candle.Timestamp, candle.CloseTime)
+			}
+		default:
+			t.Fatalf("expected %+v, got no bar", w)
+		}
+	}
+
+	select {
+	case candle := <-agg.CandlestickChan:
+		t.Errorf("expected no more bars, got %#v", candle)
+	default:
+	}
+
+	var forming []bar
+
+	for _, candle := range agg.Forming() {
+		forming = append(forming, bar{candle.Symbol, candle.BarType, candle.Volume, candle.Open, candle.Close})
+	}
+
+	// Time bars of every symbol, the remaining 2 units of the BTCUSDT volume bar and the remaining 5 units of the
+	// ETHUSDT dollar bar. ETHBTC is traded without volume, so it has no volume bar.
+	if len(forming) != 5 || forming[1] != (bar{"BTCUSDT", aggregatorsvc.BarVolume, 2, 102, 102}) ||
+		forming[2].barType != aggregatorsvc.BarTime ||
+		forming[4] != (bar{"ETHUSDT", aggregatorsvc.BarDollar, 5, 100, 100}) {
+		t.Errorf("unexpected forming bars: %+v", forming)
+	}
+}
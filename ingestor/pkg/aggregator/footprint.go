@@ -0,0 +1,223 @@
+package aggregator
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultValueArea is the share of the volume in the value area of a volume profile.
+const DefaultValueArea = 0.7
+
+// FootprintSpec configures the footprints of a symbol: the volume of its candlesticks by price level of Tick.
+type FootprintSpec struct {
+	// Symbol is the symbol the footprints are built for, or AllSymbols.
+	Symbol string
+	Tick   float64
+}
+
+// ParseFootprintSpec parses a spec written as SYMBOL:TICK, e.g. BTCUSDT:10 or *:0.01.
+func ParseFootprintSpec(s string) (FootprintSpec, error) {
+	symbol, tick, ok := strings.Cut(s, ":")
+	if !ok || strings.Contains(tick, ":") {
+		return FootprintSpec{}, fmt.Errorf("footprint %q is not SYMBOL:TICK", s)
+	}
+
+	if symbol == "" {
+		return FootprintSpec{}, fmt.Errorf("footprint %q has no symbol", s)
+	}
+
+	size, err := strconv.ParseFloat(tick, 64)
+	if err != nil || size <= 0 || math.IsInf(size, 0) {
+		return FootprintSpec{}, fmt.Errorf("footprint %q has tick %q, expected a positive number", s, tick)
+	}
+
+	return FootprintSpec{Symbol: strings.ToUpper(symbol), Tick: size}, nil
+}
+
+func (s FootprintSpec) String() string {
+	return fmt.Sprintf("%s:%s", s.Symbol, strconv.FormatFloat(s.Tick, 'f', -1, 64))
+}
+
+// PriceLevel is the volume traded at a price level, from Price up to the next level. Buy volume was traded by
+// aggressive buyers, lifting the ask, sell volume by aggressive sellers, hitting the bid.
+type PriceLevel struct {
+	Price      float64 `json:"price"`
+	BuyVolume  float64 `json:"buy_volume"`
+	SellVolume float64 `json:"sell_volume"`
+}
+
+// Footprint is the volume of a candlestick by price level, ordered by price, with its volume profile.
+type Footprint struct {
+	Tick    float64       `json:"tick"`
+	Levels  []PriceLevel  `json:"levels"`
+	Profile VolumeProfile `json:"profile"`
+}
+
+// VolumeProfile summarizes the volume by price level of a candlestick or a session: the point of control, the
+// level traded the most, and the value area, the levels around it holding the value area share of the volume.
+type VolumeProfile struct {
+	// SessionStart is the start of the session, the UTC day, of a session profile.
+	SessionStart  time.Time `json:"session_start"`
+	POC           float64   `json:"poc"`
+	ValueAreaHigh float64   `json:"value_area_high"`
+	ValueAreaLow  float64   `json:"value_area_low"`
+	Volume        float64   `json:"volume"`
+	// Partial is set on session profiles missing the start of their session, as the footprints of the symbol
+	// started after it: on the ingestor's start, or when the symbol was added.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// levels accumulates the buy and sell volume by price level, keyed by the level's index, its price over the tick.
+type levels map[int64]*[2]float64
+
+// add adds a trade to its level. The buyer being the market maker means the seller was the aggressor.
+func (l levels) add(tick, price, quantity float64, buyerMaker bool) {
+	index := int64(math.Floor(price/tick + tolerance))
+
+	level, ok := l[index]
+	if !ok {
+		level = &[2]float64{}
+		l[index] = level
+	}
+
+	if buyerMaker {
+		level[1] += quantity
+	} else {
+		level[0] += quantity
+	}
+}
+
+// merge adds the volume of other.
+func (l levels) merge(other levels) {
+	for index, volume := range other {
+		level, ok := l[index]
+		if !ok {
+			level = &[2]float64{}
+			l[index] = level
+		}
+
+		level[0] += volume[0]
+		level[1] += volume[1]
+	}
+}
+
+// priceLevels returns the levels ordered by price.
+func (l levels) priceLevels(tick float64) []PriceLevel {
+	out := make([]PriceLevel, 0, len(l))
+
+	for index, volume := range l {
+		out = append(out, PriceLevel{Price: float64(index) * tick, BuyVolume: volume[0], SellVolume: volume[1]})
+	}
+
+	slices.SortFunc(out, func(x, y PriceLevel) int {
+		return cmp.Compare(x.Price, y.Price)
+	})
+
+	return out
+}
+
+// Profile returns the volume profile of levels ordered by price. The point of control is the level with the most
+// volume, the lowest one on ties. The value area grows from it one level at a time, towards the neighbour with
+// more volume, the higher one on ties, until it holds valueArea of the volume.
+func Profile(levels []PriceLevel, valueArea float64) VolumeProfile {
+	if len(levels) == 0 {
+		return VolumeProfile{}
+	}
+
+	volumes := make([]float64, len(levels))
+	poc := 0
+
+	var total float64
+
+	for i, level := range levels {
+		volumes[i] = level.BuyVolume + level.SellVolume
+		total += volumes[i]
+
+		if volumes[i] > volumes[poc] {
+			poc = i
+		}
+	}
+
+	low, high := poc, poc
+	inArea := volumes[poc]
+
+	for inArea < valueArea*total*(1-tolerance) && (low > 0 || high < len(levels)-1) {
+		switch {
+		case low == 0:
+			high++
+			inArea += volumes[high]
+		case high == len(levels)-1 || volumes[low-1] > volumes[high+1]:
+			low--
+			inArea += volumes[low]
+		default:
+			high++
+			inArea += volumes[high]
+		}
+	}
+
+	return VolumeProfile{
+		POC:           levels[poc].Price,
+		ValueAreaHigh: levels[high].Price,
+		ValueAreaLow:  levels[low].Price,
+		Volume:        total,
+	}
+}
+
+// footprints builds the footprints of a symbol's time bars and the profile of its session.
+type footprints struct {
+	tick      float64
+	valueArea float64
+	// candles are the levels of the forming candlesticks, keyed by the Unix time of their minute.
+	candles        map[int64]levels
+	session        levels
+	sessionStart   time.Time
+	sessionPartial bool
+}
+
+func newFootprints(tick, valueArea float64) *footprints {
+	return &footprints{tick: tick, valueArea: valueArea, candles: make(map[int64]levels)}
+}
+
+// add adds a trade to the levels of the candlestick of minute.
+func (f *footprints) add(minute time.Time, price, quantity float64, buyerMaker bool) {
+	candle, ok := f.candles[minute.Unix()]
+	if !ok {
+		candle = make(levels)
+		f.candles[minute.Unix()] = candle
+	}
+
+	candle.add(f.tick, price, quantity, buyerMaker)
+}
+
+// complete sets the footprint of a completed candlestick and the profile of its session so far, starting a new
+// session on a new UTC day.
+func (f *footprints) complete(candle *Candlestick) {
+	candleLevels := f.candles[candle.Timestamp.Unix()]
+	delete(f.candles, candle.Timestamp.Unix())
+
+	if candleLevels == nil {
+		candleLevels = make(levels)
+	}
+
+	priceLevels := candleLevels.priceLevels(f.tick)
+	candle.Footprint = &Footprint{Tick: f.tick, Levels: priceLevels, Profile: Profile(priceLevels, f.valueArea)}
+
+	// Only the first session can have started before the footprints, unless its first candle is the session's
+	// first minute and wasn't restored from a snapshot.
+	sessionStart := candle.Timestamp.UTC().Truncate(24 * time.Hour)
+	if !sessionStart.Equal(f.sessionStart) {
+		f.sessionPartial = f.sessionStart.IsZero() && (!candle.Timestamp.Equal(sessionStart) || candle.Reconstructed)
+		f.session, f.sessionStart = make(levels), sessionStart
+	}
+
+	f.session.merge(candleLevels)
+
+	profile := Profile(f.session.priceLevels(f.tick), f.valueArea)
+	profile.SessionStart, profile.Partial = f.sessionStart, f.sessionPartial
+	candle.SessionProfile = &profile
+}
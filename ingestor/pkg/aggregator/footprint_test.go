@@ -0,0 +1,135 @@
+package aggregator_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+func TestParseFootprintSpec(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]aggregatorsvc.FootprintSpec{
+		"btcusdt:10": {Symbol: "BTCUSDT", Tick: 10},
+		"*:0.01":     {Symbol: "*", Tick: 0.01},
+	} {
+		got, err := aggregatorsvc.ParseFootprintSpec(input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", input, err)
+
+			continue
+		}
+
+		if got != want {
+			t.Errorf("%s: expected %+v, got %+v", input, want, got)
+		}
+	}
+
+	for _, input := range []string{"BTCUSDT", ":10", "BTCUSDT:0", "BTCUSDT:-1", "BTCUSDT:abc", "BTCUSDT:1:2"} {
+		if _, err := aggregatorsvc.ParseFootprintSpec(input); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	t.Parallel()
+
+	levels := []aggregatorsvc.PriceLevel{
+		{Price: 100, BuyVolume: 1},
+		{Price: 101, BuyVolume: 1, SellVolume: 2},
+		{Price: 102, BuyVolume: 4, SellVolume: 1},
+		{Price: 103, SellVolume: 2},
+		{Price: 104, SellVolume: 1},
+	}
+
+	// 70% of 12 is 8.4: the area grows from 102 to 101, which has more volume than 103, then to 103, which has
+	// more than 100, holding 10.
+	want := aggregatorsvc.VolumeProfile{POC: 102, ValueAreaHigh: 103, ValueAreaLow: 101, Volume: 12}
+	if got := aggregatorsvc.Profile(levels, 0.7); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if got := aggregatorsvc.Profile(nil, 0.7); got != (aggregatorsvc.VolumeProfile{}) {
+		t.Errorf("expected an empty profile without levels, got %+v", got)
+	}
+}
+
+func TestAggregator_Footprints(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(10),
+		aggregatorsvc.WithFootprints(aggregatorsvc.FootprintSpec{Symbol: "BTCUSDT", Tick: 10}))
+	minute := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for _, trade := range []binance.TradeData{
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: minute.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "105.0", Quantity: "2.0", TradeTime: minute.UnixMilli(), IsMarketMaker: true},
+		{Symbol: "BTCUSDT", Price: "112.0", Quantity: "3.0", TradeTime: minute.UnixMilli()},
+		{Symbol: "ETHUSDT", Price: "10.0", Quantity: "1.0", TradeTime: minute.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "120.0", Quantity: "1.0", TradeTime: minute.Add(time.Minute).UnixMilli()},
+		{Symbol: "ETHUSDT", Price: "11.0", Quantity: "1.0", TradeTime: minute.Add(time.Minute).UnixMilli()},
+	} {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	first := <-agg.CandlestickChan
+
+	// The buyer being the maker of the 105 trade makes it sell volume. 100 and 110 tie as the point of control, the
+	// lower one wins.
+	want := &aggregatorsvc.Footprint{
+		Tick: 10,
+		Levels: []aggregatorsvc.PriceLevel{
+			{Price: 100, BuyVolume: 1, SellVolume: 2},
+			{Price: 110, BuyVolume: 3},
+		},
+		Profile: aggregatorsvc.VolumeProfile{POC: 100, ValueAreaHigh: 110, ValueAreaLow: 100, Volume: 6},
+	}
+	if !reflect.DeepEqual(first.Footprint, want) {
+		t.Errorf("expected footprint %+v, got %+v", want, first.Footprint)
+	}
+
+	if eth := <-agg.CandlestickChan; eth.Footprint != nil || eth.SessionProfile != nil {
+		t.Errorf("expected no footprint for ETHUSDT, got %+v", eth.Footprint)
+	}
+
+	// Finalized with the symbol, the second candle adds its level to the session.
+	agg.Remove("BTCUSDT")
+
+	second := <-agg.CandlestickChan
+	if second.Footprint == nil || len(second.Footprint.Levels) != 1 || second.Footprint.Levels[0].Price != 120 {
+		t.Fatalf("expected a footprint at 120, got %+v", second.Footprint)
+	}
+
+	// The footprints started at 10:30, so the session misses its start.
+	wantSession := aggregatorsvc.VolumeProfile{SessionStart: minute.Truncate(24 * time.Hour), POC: 100,
+		ValueAreaHigh: 110, ValueAreaLow: 100, Volume: 7, Partial: true}
+	if second.SessionProfile == nil || *second.SessionProfile != wantSession {
+		t.Errorf("expected session profile %+v, got %+v", wantSession, second.SessionProfile)
+	}
+}
+
+func TestAggregator_Footprints_PartialSession(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(10),
+		aggregatorsvc.WithFootprints(aggregatorsvc.FootprintSpec{Symbol: "BTCUSDT", Tick: 10}))
+	midnight := time.Date(2025, time.January, 28, 0, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{midnight.Add(-time.Minute), midnight, midnight.Add(time.Minute)} {
+		trade := binance.TradeData{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: at.UnixMilli()}
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	// The session of the first candle began before the footprints, the next one is seen from its first minute.
+	if first := <-agg.CandlestickChan; first.SessionProfile == nil || !first.SessionProfile.Partial {
+		t.Errorf("expected the first session to be partial, got %+v", first.SessionProfile)
+	}
+
+	if second := <-agg.CandlestickChan; second.SessionProfile == nil || second.SessionProfile.Partial {
+		t.Errorf("expected the session started at midnight to be complete, got %+v", second.SessionProfile)
+	}
+}
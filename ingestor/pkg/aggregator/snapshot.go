@@ -0,0 +1,172 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the format of the snapshots written by SaveSnapshot, bumped on incompatible changes.
+const snapshotVersion = 1
+
+// Snapshot is the state of an Aggregator, saved to disk so a restart resumes the candlesticks being aggregated
+// instead of emitting partial ones.
+type Snapshot struct {
+	Version      int           `json:"version"`
+	TakenAt      time.Time     `json:"taken_at"`
+	Candlesticks []Candlestick `json:"candlesticks"`
+	// LastMinutes is the minute of the last trade aggregated per symbol.
+	LastMinutes map[string]time.Time `json:"last_minutes"`
+}
+
+// Symbols returns a copy of s limited to the candlesticks of symbols for which keep returns true.
+func (s Snapshot) Symbols(keep func(symbol string) bool) Snapshot {
+	out := Snapshot{Version: s.Version, TakenAt: s.TakenAt, LastMinutes: make(map[string]time.Time)}
+
+	for _, candle := range s.Candlesticks {
+		if keep(candle.Symbol) {
+			out.Candlesticks = append(out.Candlesticks, candle)
+		}
+	}
+
+	for symbol, minute := range s.LastMinutes {
+		if keep(symbol) {
+			out.LastMinutes[symbol] = minute
+		}
+	}
+
+	return out
+}
+
+// Snapshot returns the state of the aggregator, taken at now. Each symbol is consistent, not all of them at
+// once, as symbols are aggregated independently.
+func (a *Aggregator) Snapshot(now time.Time) Snapshot {
+	snapshot := Snapshot{
+		Version:     snapshotVersion,
+		TakenAt:     now.UTC(),
+		LastMinutes: make(map[string]time.Time),
+	}
+
+	for _, p := range a.sorted() {
+		p.mu.Lock()
+
+		if !p.removed && !p.lastMinute.IsZero() {
+			snapshot.Candlesticks = append(snapshot.Candlesticks, p.forming()...)
+			snapshot.LastMinutes[p.symbol] = p.lastMinute
+		}
+
+		p.mu.Unlock()
+	}
+
+	return snapshot
+}
+
+// Restore resumes the candlesticks of snapshot, marked as reconstructed, returning how many were restored.
+// Symbols the aggregator already has trades for are skipped, as their state is newer than the snapshot.
+func (a *Aggregator) Restore(snapshot Snapshot) int {
+	bySymbol := make(map[string][]Candlestick)
+
+	for _, candle := range snapshot.Candlesticks {
+		bySymbol[candle.Symbol] = append(bySymbol[candle.Symbol], candle)
+	}
+
+	restored := 0
+
+	for symbol, candles := range bySymbol {
+		p := a.partition(symbol)
+
+		p.mu.Lock()
+
+		if !p.removed && p.lastMinute.IsZero() {
+			for _, candle := range candles {
+				candle.Reconstructed = true
+				p.candlesticks[candle.Timestamp.Unix()] = &candle
+
+				if candle.Timestamp.After(p.lastMinute) {
+					p.lastMinute = candle.Timestamp
+				}
+			}
+
+			if minute := snapshot.LastMinutes[symbol]; minute.After(p.lastMinute) {
+				p.lastMinute = minute
+			}
+
+			restored += len(candles)
+		}
+
+		p.mu.Unlock()
+	}
+
+	return restored
+}
+
+// SaveSnapshot writes snapshot to path as JSON. It is written to a temporary file renamed over path, so a crash
+// while writing leaves the previous snapshot intact.
+func SaveSnapshot(path string, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ErrNoSnapshot is returned by LoadSnapshot when no snapshot was saved yet.
+var ErrNoSnapshot = errors.New("no snapshot")
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot.
+func LoadSnapshot(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is the configured snapshot file.
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, ErrNoSnapshot
+	}
+
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+
+	var snapshot Snapshot
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to decode snapshot %s: %w", path, err)
+	}
+
+	if snapshot.Version != snapshotVersion {
+		return Snapshot{}, fmt.Errorf("snapshot %s has version %d, expected %d", path, snapshot.Version,
+			snapshotVersion)
+	}
+
+	return snapshot, nil
+}
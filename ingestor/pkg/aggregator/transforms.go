@@ -0,0 +1,259 @@
+package aggregator
+
+import (
+	"math"
+	"time"
+)
+
+// tolerance is the share of a box or range below which prices are considered equal, so that e.g. a move from
+// 100.0 to 100.1 fills a box of 0.1 despite floating point rounding.
+const tolerance = 1e-9
+
+// Renko builds Renko bricks of a box size from trades. A brick is added once the price moves a box past the
+// last brick in its direction, a reversal takes two boxes. Bricks span whole boxes, aligned on multiples of the
+// box size, and the volume traded since the last brick is counted on the next one.
+type Renko struct {
+	box float64
+	// level is the close of the last brick, or of the first trade rounded down, in boxes.
+	level     int64
+	direction int
+	started   bool
+	// volume and since are the volume traded since the last brick and the time of the first trade since.
+	volume float64
+	since  time.Time
+}
+
+// RenkoState is the state of Renko bricks between trades, from which ResumeRenko continues them.
+type RenkoState struct {
+	Started   bool      `json:"started"`
+	Level     int64     `json:"level"`
+	Direction int       `json:"direction"`
+	Volume    float64   `json:"volume,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// NewRenko creates the Renko bricks of box, a positive price.
+func NewRenko(box float64) *Renko {
+	return &Renko{box: box}
+}
+
+// ResumeRenko creates the Renko bricks of box continuing from state, returned by State.
+func ResumeRenko(box float64, state RenkoState) *Renko {
+	return &Renko{
+		box:       box,
+		level:     state.Level,
+		direction: state.Direction,
+		started:   state.Started,
+		volume:    state.Volume,
+		since:     state.Since,
+	}
+}
+
+// State returns the state of the bricks, e.g. to continue them elsewhere with ResumeRenko.
+func (r *Renko) State() RenkoState {
+	return RenkoState{
+		Started:   r.started,
+		Level:     r.level,
+		Direction: r.direction,
+		Volume:    r.volume,
+		Since:     r.since,
+	}
+}
+
+// Add adds a trade of symbol, returning the bricks it completed.
+func (r *Renko) Add(symbol string, tradeTime time.Time, price, quantity float64) []*Candlestick {
+	boxes := price / r.box
+
+	if !r.started {
+		r.level = int64(math.Floor(boxes + tolerance))
+		r.started = true
+	}
+
+	if r.since.IsZero() {
+		r.since = tradeTime
+	}
+
+	r.volume += quantity
+
+	var bricks []*Candlestick
+
+	for {
+		var open, closed int64
+
+		switch {
+		case r.direction >= 0 && boxes >= float64(r.level+1)-tolerance:
+			open, closed, r.direction = r.level, r.level+1, 1
+		case r.direction <= 0 && boxes <= float64(r.level-1)+tolerance:
+			open, closed, r.direction = r.level, r.level-1, -1
+		case r.direction > 0 && boxes <= float64(r.level-2)+tolerance:
+			open, closed, r.direction = r.level-1, r.level-2, -1
+		case r.direction < 0 && boxes >= float64(r.level+2)-tolerance:
+			open, closed, r.direction = r.level+1, r.level+2, 1
+		default:
+			if len(bricks) > 0 {
+				// The next brick starts with the next trade.
+				r.since = time.Time{}
+			}
+
+			return bricks
+		}
+
+		r.level = closed
+		bricks = append(bricks, r.brick(symbol, tradeTime, float64(open)*r.box, float64(closed)*r.box))
+	}
+}
+
+// brick returns a brick from open to closed, completed by a trade at tradeTime.
+func (r *Renko) brick(symbol string, tradeTime time.Time, open, closed float64) *Candlestick {
+	closeTime := tradeTime
+	brick := &Candlestick{
+		Symbol:    symbol,
+		Open:      open,
+		High:      maxFloat64(open, closed),
+		Low:       minFloat64(open, closed),
+		Close:     closed,
+		Volume:    r.volume,
+		Timestamp: r.since,
+		BarType:   BarRenko,
+		Threshold: r.box,
+		CloseTime: &closeTime,
+	}
+
+	// Later bricks of the same trade start and end with it.
+	r.volume, r.since = 0, tradeTime
+
+	return brick
+}
+
+// Forming returns nil, as bricks only exist once complete.
+func (r *Renko) Forming() *Candlestick {
+	return nil
+}
+
+// RangeBars builds bars spanning a price range from trades. A bar closes on the trade bringing its high to low
+// range to at least the size, which may exceed it when the price gaps, and the next trade opens the next bar.
+type RangeBars struct {
+	size float64
+	bar  bar
+}
+
+// NewRangeBars creates the range bars of size, a positive price.
+func NewRangeBars(size float64) *RangeBars {
+	return &RangeBars{size: size}
+}
+
+// ResumeRangeBars creates the range bars of size continuing forming, a bar returned by Forming, or starting over
+// when it is nil.
+func ResumeRangeBars(size float64, forming *Candlestick) *RangeBars {
+	r := NewRangeBars(size)
+
+	if forming != nil {
+		current := *forming
+		if current.CloseTime != nil {
+			r.bar.lastTrade = *current.CloseTime
+		}
+
+		current.CloseTime = nil
+		r.bar.current = &current
+	}
+
+	return r
+}
+
+// Add adds a trade of symbol, returning the bar it completed, if any.
+func (r *RangeBars) Add(symbol string, tradeTime time.Time, price, quantity float64) []*Candlestick {
+	if r.bar.current == nil {
+		r.bar.current = &Candlestick{
+			Symbol:    symbol,
+			Open:      price,
+			High:      price,
+			Low:       price,
+			Timestamp: tradeTime,
+			BarType:   BarRange,
+			Threshold: r.size,
+		}
+	}
+
+	current := r.bar.current
+	current.High = maxFloat64(current.High, price)
+	current.Low = minFloat64(current.Low, price)
+	current.Close = price
+	current.Volume += quantity
+	r.bar.lastTrade = tradeTime
+
+	if current.High-current.Low < r.size*(1-tolerance) {
+		return nil
+	}
+
+	completed := r.bar.closed()
+	r.bar.current = nil
+
+	return []*Candlestick{completed}
+}
+
+// Forming returns a copy of the current bar, nil when no trade started it.
+func (r *RangeBars) Forming() *Candlestick {
+	return r.bar.Forming()
+}
+
+// HeikinAshi transforms candlesticks into Heikin-Ashi candlesticks, each averaging its candlestick with the
+// previous Heikin-Ashi one to smooth the trend.
+type HeikinAshi struct {
+	prev *Candlestick
+}
+
+// NewHeikinAshi creates a Heikin-Ashi transform, candlesticks are passed to it in order.
+func NewHeikinAshi() *HeikinAshi {
+	return &HeikinAshi{}
+}
+
+// ResumeHeikinAshi creates a Heikin-Ashi transform following prev, a candlestick returned by Next, or starting
+// over when it is nil.
+func ResumeHeikinAshi(prev *Candlestick) *HeikinAshi {
+	if prev == nil {
+		return NewHeikinAshi()
+	}
+
+	resumed := *prev
+
+	return &HeikinAshi{prev: &resumed}
+}
+
+// Prev returns a copy of the last candlestick returned by Next, nil when there is none.
+func (h *HeikinAshi) Prev() *Candlestick {
+	if h.prev == nil {
+		return nil
+	}
+
+	prev := *h.prev
+
+	return &prev
+}
+
+// Next returns the Heikin-Ashi candlestick of candle, the one following the candlesticks passed before.
+func (h *HeikinAshi) Next(candle *Candlestick) *Candlestick {
+	next := h.Peek(candle)
+	h.prev = next
+
+	return next
+}
+
+// Peek returns the Heikin-Ashi candlestick of candle without making it the previous one, e.g. for a candlestick
+// still forming.
+func (h *HeikinAshi) Peek(candle *Candlestick) *Candlestick {
+	next := *candle
+	next.BarType, next.Threshold = BarHeikinAshi, 0
+	next.Footprint, next.SessionProfile = nil, nil
+	next.Close = (candle.Open + candle.High + candle.Low + candle.Close) / 4
+
+	if h.prev == nil {
+		next.Open = (candle.Open + candle.Close) / 2
+	} else {
+		next.Open = (h.prev.Open + h.prev.Close) / 2
+	}
+
+	next.High = max(candle.High, next.Open, next.Close)
+	next.Low = min(candle.Low, next.Open, next.Close)
+
+	return &next
+}
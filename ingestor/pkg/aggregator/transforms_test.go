@@ -0,0 +1,270 @@
+package aggregator_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	aggregatorsvc "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+// ohlcv is the part of a candlestick the transform fixtures are computed by hand for, with the index of the
+// trades opening and closing it.
+type ohlcv struct {
+	open, high, low, close, volume float64
+	first, last                    int
+}
+
+func assertBars(t *testing.T, start time.Time, want []ohlcv, got []*aggregatorsvc.Candlestick) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d bars, got %d: %+v", len(want), len(got), got)
+	}
+
+	for i, w := range want {
+		g := got[i]
+
+		if !approx(g.Open, w.open) || !approx(g.High, w.high) || !approx(g.Low, w.low) ||
+			!approx(g.Close, w.close) || !approx(g.Volume, w.volume) {
+			t.Errorf("bar %d: expected %+v, got %+v", i, w, *g)
+		}
+
+		if first := start.Add(time.Duration(w.first) * time.Second); !g.Timestamp.Equal(first) {
+			t.Errorf("bar %d: expected timestamp %v, got %v", i, first, g.Timestamp)
+		}
+
+		if last := start.Add(time.Duration(w.last) * time.Second); g.CloseTime == nil || !g.CloseTime.Equal(last) {
+			t.Errorf("bar %d: expected close time %v, got %v", i, last, g.CloseTime)
+		}
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRenko(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+	renko := aggregatorsvc.NewRenko(10)
+
+	var bricks []*aggregatorsvc.Candlestick
+
+	for i, price := range []float64{100, 105, 112, 125, 121, 101, 99, 85, 60} {
+		bricks = append(bricks, renko.Add("BTCUSDT", start.Add(time.Duration(i)*time.Second), price, 1)...)
+	}
+
+	// 100 starts at a box boundary, 112 and 125 add a brick up each, 101 isn't 2 boxes below 120 but 99 is, so it
+	// reverses from the open of the last brick, 85 continues down and 60 adds 3 bricks at once, the volume on the
+	// first.
+	assertBars(t, start, []ohlcv{
+		{100, 110, 100, 110, 3, 0, 2},
+		{110, 120, 110, 120, 1, 3, 3},
+		{110, 110, 100, 100, 3, 4, 6},
+		{100, 100, 90, 90, 1, 7, 7},
+		{90, 90, 80, 80, 1, 8, 8},
+		{80, 80, 70, 70, 0, 8, 8},
+		{70, 70, 60, 60, 0, 8, 8},
+	}, bricks)
+
+	if forming := renko.Forming(); forming != nil {
+		t.Errorf("expected no forming brick, got %+v", forming)
+	}
+}
+
+func TestRenko_FractionalBox(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+	renko := aggregatorsvc.NewRenko(0.1)
+
+	var bricks []*aggregatorsvc.Candlestick
+
+	for i, price := range []float64{100.0, 100.1, 100.3} {
+		bricks = append(bricks, renko.Add("BTCUSDT", start.Add(time.Duration(i)*time.Second), price, 1)...)
+	}
+
+	// 100.1 is a box above 100.0 despite rounding, 100.3 two more.
+	assertBars(t, start, []ohlcv{
+		{100.0, 100.1, 100.0, 100.1, 2, 0, 1},
+		{100.1, 100.2, 100.1, 100.2, 1, 2, 2},
+		{100.2, 100.3, 100.2, 100.3, 0, 2, 2},
+	}, bricks)
+}
+
+func TestRangeBars(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+	ranges := aggregatorsvc.NewRangeBars(5)
+
+	var bars []*aggregatorsvc.Candlestick
+
+	for i, price := range []float64{100, 103, 99, 101, 104, 106, 110, 112, 111} {
+		bars = append(bars, ranges.Add("BTCUSDT", start.Add(time.Duration(i)*time.Second), price, 1)...)
+	}
+
+	// 104 brings the first bar to a range of 5, 106 opens the next one, which 112 closes past the range as the
+	// price gapped from 110.
+	assertBars(t, start, []ohlcv{
+		{100, 104, 99, 104, 5, 0, 4},
+		{106, 112, 106, 112, 3, 5, 7},
+	}, bars)
+
+	forming := ranges.Forming()
+	if forming == nil || forming.Open != 111 || forming.Volume != 1 || forming.BarType != aggregatorsvc.BarRange {
+		t.Errorf("expected a forming bar opened at 111, got %+v", forming)
+	}
+}
+
+func TestHeikinAshi(t *testing.T) {
+	t.Parallel()
+
+	heikinAshi := aggregatorsvc.NewHeikinAshi()
+
+	var got []ohlcv
+
+	for _, candle := range []aggregatorsvc.Candlestick{
+		{Open: 10, High: 12, Low: 9, Close: 11, Volume: 1},
+		{Open: 11, High: 14, Low: 10, Close: 13, Volume: 2},
+		{Open: 13, High: 13, Low: 8, Close: 9, Volume: 3},
+	} {
+		ha := heikinAshi.Next(&candle)
+		if ha.BarType != aggregatorsvc.BarHeikinAshi {
+			t.Errorf("expected a Heikin-Ashi bar type, got %q", ha.BarType)
+		}
+
+		got = append(got, ohlcv{ha.Open, ha.High, ha.Low, ha.Close, ha.Volume, 0, 0})
+	}
+
+	// Close is the mean of the candle, open the mean of the previous Heikin-Ashi open and close, or of the
+	// candle's open and close for the first one.
+	want := []ohlcv{
+		{10.5, 12, 9, 10.5, 1, 0, 0},
+		{10.5, 14, 10, 12, 2, 0, 0},
+		{11.25, 13, 8, 10.75, 3, 0, 0},
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("candle %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	// Peek doesn't advance, the next candle still follows the third one.
+	peeked := heikinAshi.Peek(&aggregatorsvc.Candlestick{Open: 9, High: 9, Low: 9, Close: 9})
+	if next := heikinAshi.Next(&aggregatorsvc.Candlestick{Open: 9, High: 9, Low: 9, Close: 9}); next.Open != 11 ||
+		peeked.Open != 11 {
+		t.Errorf("expected an open of 11, got %v peeked and %v next", peeked.Open, next.Open)
+	}
+}
+
+func TestTransforms_Resume(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+	prices := []float64{100, 105, 112, 125, 121, 101, 99, 85, 60}
+
+	type transform interface {
+		Add(symbol string, tradeTime time.Time, price, quantity float64) []*aggregatorsvc.Candlestick
+	}
+
+	add := func(bars transform, prices []float64, offset int) []*aggregatorsvc.Candlestick {
+		var added []*aggregatorsvc.Candlestick
+
+		for i, price := range prices {
+			added = append(added, bars.Add("BTCUSDT", start.Add(time.Duration(offset+i)*time.Second), price, 1)...)
+		}
+
+		return added
+	}
+
+	// Bars continued from the state after the fifth trade are the bars of the uninterrupted trades.
+	renko := aggregatorsvc.NewRenko(10)
+	resumedRenko := append(add(renko, prices[:5], 0),
+		add(aggregatorsvc.ResumeRenko(10, renko.State()), prices[5:], 5)...)
+	assertSame(t, "renko", add(aggregatorsvc.NewRenko(10), prices, 0), resumedRenko)
+
+	ranges := aggregatorsvc.NewRangeBars(5)
+	resumedRanges := append(add(ranges, prices[:5], 0),
+		add(aggregatorsvc.ResumeRangeBars(5, ranges.Forming()), prices[5:], 5)...)
+	assertSame(t, "range", add(aggregatorsvc.NewRangeBars(5), prices, 0), resumedRanges)
+
+	candles := []aggregatorsvc.Candlestick{
+		{Open: 10, High: 12, Low: 9, Close: 11},
+		{Open: 11, High: 14, Low: 10, Close: 13},
+		{Open: 13, High: 13, Low: 8, Close: 9},
+	}
+	heikinAshi := aggregatorsvc.NewHeikinAshi()
+
+	for _, candle := range candles[:2] {
+		heikinAshi.Next(&candle)
+	}
+
+	resumed := aggregatorsvc.ResumeHeikinAshi(heikinAshi.Prev()).Next(&candles[2])
+	if want := heikinAshi.Next(&candles[2]); resumed.Open != want.Open || resumed.Close != want.Close {
+		t.Errorf("heikin ashi: expected %+v, got %+v", *want, *resumed)
+	}
+}
+
+func assertSame(t *testing.T, name string, want, got []*aggregatorsvc.Candlestick) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%s: expected %d bars, got %d", name, len(want), len(got))
+	}
+
+	for i := range want {
+		if w, g := *want[i], *got[i]; w.Open != g.Open || w.Close != g.Close || w.Volume != g.Volume ||
+			!w.Timestamp.Equal(g.Timestamp) || !w.CloseTime.Equal(*g.CloseTime) {
+			t.Errorf("%s bar %d: expected %+v, got %+v", name, i, w, g)
+		}
+	}
+}
+
+func TestAggregator_Transforms(t *testing.T) {
+	agg := aggregatorsvc.NewAggregator(aggregatorsvc.WithBufferSize(10), aggregatorsvc.WithBars(
+		aggregatorsvc.BarSpec{Symbol: "BTCUSDT", Type: aggregatorsvc.BarHeikinAshi},
+		aggregatorsvc.BarSpec{Symbol: "*", Type: aggregatorsvc.BarRenko, Threshold: 10},
+	))
+	tradeTime := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	for _, trade := range []binance.TradeData{
+		{Symbol: "BTCUSDT", Price: "100.0", Quantity: "1.0", TradeTime: tradeTime.UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "104.0", Quantity: "1.0", TradeTime: tradeTime.Add(time.Second).UnixMilli()},
+		{Symbol: "BTCUSDT", Price: "110.0", Quantity: "1.0", TradeTime: tradeTime.Add(time.Minute).UnixMilli()},
+	} {
+		if _, err := agg.AggregateTrade(trade); err != nil {
+			t.Fatalf("aggregateTrade failed for trade %+v: %v", trade, err)
+		}
+	}
+
+	// The last trade completes the first minute, then its Heikin-Ashi candlestick, then a Renko brick.
+	for _, want := range []struct {
+		barType     aggregatorsvc.BarType
+		open, close float64
+	}{
+		{aggregatorsvc.BarTime, 100, 104},
+		{aggregatorsvc.BarHeikinAshi, 102, 102},
+		{aggregatorsvc.BarRenko, 100, 110},
+	} {
+		select {
+		case candle := <-agg.CandlestickChan:
+			if candle.BarType != want.barType || candle.Open != want.open || candle.Close != want.close {
+				t.Errorf("expected %+v, got %+v", want, *candle)
+			}
+		default:
+			t.Fatalf("expected %+v, got no candlestick", want)
+		}
+	}
+
+	// The forming minute and its Heikin-Ashi candlestick, opening at the mean of the previous one.
+	forming := agg.Forming()
+	if len(forming) != 2 || forming[1].BarType != aggregatorsvc.BarHeikinAshi || forming[1].Open != 102 ||
+		forming[1].Close != 110 {
+		t.Errorf("unexpected forming candlesticks: %+v", forming)
+	}
+}
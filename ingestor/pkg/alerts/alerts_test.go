@@ -0,0 +1,227 @@
+package alerts_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/alerts"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+)
+
+var start = time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+func add(t *testing.T, evaluator *alerts.Evaluator, rule alerts.Rule) alerts.Rule {
+	t.Helper()
+
+	added, err := evaluator.Add(rule, start)
+	if err != nil {
+		t.Fatalf("Add(%+v) failed: %v", rule, err)
+	}
+
+	return added
+}
+
+// trades feeds prices a second apart, returning the values the rules fired at.
+func trades(evaluator *alerts.Evaluator, prices ...float64) []float64 {
+	var fired []float64
+
+	for i, price := range prices {
+		for _, firing := range evaluator.Trade("BTCUSDT", start.Add(time.Duration(i)*time.Second), price) {
+			fired = append(fired, firing.Value)
+		}
+	}
+
+	return fired
+}
+
+// candles feeds 1m candles of volumes and closes, returning the values the rules fired at.
+func candles(evaluator *alerts.Evaluator, volumes, closes []float64) []float64 {
+	var fired []float64
+
+	for i := range closes {
+		candle := &aggregator.Candlestick{Symbol: "BTCUSDT", Close: closes[i], Volume: volumes[i],
+			Timestamp: start.Add(time.Duration(i) * time.Minute)}
+
+		for _, firing := range evaluator.Candle(candle) {
+			fired = append(fired, firing.Value)
+		}
+	}
+
+	return fired
+}
+
+func equal(x, y []float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRule_Normalize(t *testing.T) {
+	t.Parallel()
+
+	rule, err := alerts.Rule{Symbol: "btcusdt", Type: alerts.VolumeSpike, Multiplier: 3, Level: 5,
+		Direction: alerts.DirectionUp}.Normalize()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := alerts.Rule{Symbol: "BTCUSDT", Type: alerts.VolumeSpike, Multiplier: 3, Periods: alerts.DefaultPeriods}
+	if rule != want {
+		t.Errorf("expected %+v, got %+v", want, rule)
+	}
+
+	sma, kagi := &indicators.Spec{Type: indicators.SMA}, &indicators.Spec{Type: "kagi"}
+
+	for name, invalid := range map[string]alerts.Rule{
+		"symbol":     {Symbol: "BTC-USDT", Type: alerts.PriceCross, Level: 1},
+		"type":       {Symbol: "BTCUSDT", Type: "kagi"},
+		"level":      {Symbol: "BTCUSDT", Type: alerts.PriceCross},
+		"window":     {Symbol: "BTCUSDT", Type: alerts.PercentMove, Percent: 1, Window: 48 * time.Hour},
+		"periods":    {Symbol: "BTCUSDT", Type: alerts.VolumeSpike, Multiplier: 2, Periods: -1},
+		"fast":       {Symbol: "BTCUSDT", Type: alerts.IndicatorCross},
+		"slow":       {Symbol: "BTCUSDT", Type: alerts.IndicatorCross, Fast: sma, Slow: kagi},
+		"direction":  {Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 1, Direction: "sideways"},
+		"webhook":    {Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 1, WebhookURL: "ftp://example.com"},
+		"cooldown":   {Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 1, Cooldown: -time.Second},
+		"multiplier": {Symbol: "BTCUSDT", Type: alerts.VolumeSpike},
+	} {
+		if _, err := invalid.Normalize(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEvaluator_PriceCross(t *testing.T) {
+	t.Parallel()
+
+	evaluator := alerts.NewEvaluator()
+	add(t, evaluator, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 100})
+	add(t, evaluator, alerts.Rule{Symbol: "ETHUSDT", Type: alerts.PriceCross, Level: 100})
+
+	// Reaching the level crosses it, staying on it doesn't cross again.
+	if fired := trades(evaluator, 99, 100, 100, 101, 98, 102); !equal(fired, []float64{100, 98, 102}) {
+		t.Errorf("expected firings at 100, 98 and 102, got %v", fired)
+	}
+
+	up := alerts.NewEvaluator()
+	add(t, up, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 100, Direction: alerts.DirectionUp,
+		Cooldown: 10 * time.Second})
+
+	// The third crossing up is within the cooldown of the first.
+	if fired := trades(up, 99, 101, 99, 101); !equal(fired, []float64{101}) {
+		t.Errorf("expected a single firing at 101, got %v", fired)
+	}
+}
+
+func TestEvaluator_PercentMove(t *testing.T) {
+	t.Parallel()
+
+	evaluator := alerts.NewEvaluator()
+	add(t, evaluator, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.PercentMove, Percent: 10, Window: 3 * time.Second})
+
+	// 100 to 110 within the window fires, then the move starts over from 110. 100 leaves the window before 109 to
+	// 120 completes, and 120 to 108 falls 10%.
+	prices := []float64{100, 105, 110, 109, 115, 119, 120, 108}
+	if fired := trades(evaluator, prices...); !equal(fired, []float64{110, 120, 108}) {
+		t.Errorf("expected firings at 110, 120 and 108, got %v", fired)
+	}
+
+	slow := alerts.NewEvaluator()
+	add(t, slow, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.PercentMove, Percent: 10, Window: 2 * time.Second})
+
+	if fired := trades(slow, 100, 104, 108, 112); len(fired) != 0 {
+		t.Errorf("expected no firing for moves slower than the window, got %v", fired)
+	}
+}
+
+func TestEvaluator_VolumeSpike(t *testing.T) {
+	t.Parallel()
+
+	evaluator := alerts.NewEvaluator()
+	add(t, evaluator, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.VolumeSpike, Multiplier: 3, Periods: 2})
+
+	// 60 is 2.4 times the average of 10 and 40, 200 is 5.7 times the one of 60 and 10.
+	volumes := []float64{10, 40, 60, 10, 200}
+	if fired := candles(evaluator, volumes, make([]float64, len(volumes))); !equal(fired, []float64{200}) {
+		t.Errorf("expected a firing at 200, got %v", fired)
+	}
+
+	ignored := &aggregator.Candlestick{Symbol: "BTCUSDT", Volume: 1000, Timestamp: start, Partial: true}
+	if fired := evaluator.Candle(ignored); len(fired) != 0 {
+		t.Errorf("expected partial candles to be ignored, got %v", fired)
+	}
+
+	synthetic := alerts.NewEvaluator(alerts.WithoutVolume("ETHBTC"))
+	if _, err := synthetic.Add(alerts.Rule{Symbol: "ethbtc", Type: alerts.VolumeSpike, Multiplier: 3},
+		start); !errors.Is(err, alerts.ErrNoVolume) {
+		t.Errorf("expected ErrNoVolume, got %v", err)
+	}
+}
+
+func TestEvaluator_IndicatorCross(t *testing.T) {
+	t.Parallel()
+
+	evaluator := alerts.NewEvaluator()
+	add(t, evaluator, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.IndicatorCross, Direction: alerts.DirectionUp,
+		Fast: &indicators.Spec{Type: indicators.SMA, Period: 1}, Slow: &indicators.Spec{Type: indicators.SMA, Period: 3}})
+
+	// The SMA(3) is ready from the third close: 1 is below 2, 4 above 2.33, 1 below 3 and 5 above 3.33, crossing
+	// up twice.
+	closes := []float64{3, 2, 1, 4, 1, 5}
+	if fired := candles(evaluator, make([]float64, len(closes)), closes); !equal(fired, []float64{4, 5}) {
+		t.Errorf("expected firings at 4 and 5, got %v", fired)
+	}
+}
+
+func TestEvaluator_Store(t *testing.T) {
+	t.Parallel()
+
+	store := alerts.NewFileStore(filepath.Join(t.TempDir(), "rules.json"))
+	evaluator := alerts.NewEvaluator(alerts.WithStore(store), alerts.WithMaxRules(2))
+
+	first := add(t, evaluator, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 100, Owner: "dashboard"})
+	second := add(t, evaluator, alerts.Rule{Symbol: "BTCUSDT", Type: alerts.IndicatorCross,
+		Fast: &indicators.Spec{Type: indicators.RSI}, Level: 70, WebhookURL: "https://example.com/hook"})
+
+	if _, err := evaluator.Add(alerts.Rule{Symbol: "BTCUSDT", Type: alerts.PriceCross, Level: 1},
+		start); !errors.Is(err, alerts.ErrTooManyRules) {
+		t.Errorf("expected ErrTooManyRules, got %v", err)
+	}
+
+	if _, err := evaluator.Remove(first.ID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	if _, err := evaluator.Remove(first.ID); !errors.Is(err, alerts.ErrRuleNotFound) {
+		t.Errorf("expected ErrRuleNotFound, got %v", err)
+	}
+
+	restarted := alerts.NewEvaluator(alerts.WithStore(store))
+
+	loaded, err := restarted.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(second.WebhookSecret) != 64 || first.WebhookSecret != "" {
+		t.Errorf("expected a secret for the rule with a webhook only, got %q and %q", second.WebhookSecret,
+			first.WebhookSecret)
+	}
+
+	rules := restarted.Rules()
+	if loaded != 1 || len(rules) != 1 || rules[0].ID != second.ID || rules[0].Fast.Period != 14 ||
+		rules[0].WebhookURL != second.WebhookURL || rules[0].WebhookSecret != second.WebhookSecret {
+		t.Errorf("expected the second rule to be restored, got %+v", rules)
+	}
+}
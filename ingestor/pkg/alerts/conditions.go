@@ -0,0 +1,256 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+)
+
+// signal is what made a rule fire: the value crossing or reaching its reference.
+type signal struct {
+	value     float64
+	reference float64
+	message   string
+}
+
+// condition holds the state of a rule between trades and candlesticks. Both return whether the rule fires.
+type condition interface {
+	trade(at time.Time, price float64) (signal, bool)
+	candle(candle *aggregator.Candlestick) (signal, bool)
+}
+
+func newCondition(rule Rule) condition {
+	switch rule.Type {
+	case PercentMove:
+		return &percentMove{rule: rule}
+	case VolumeSpike:
+		return &volumeSpike{rule: rule, volumes: make([]float64, 0, rule.Periods)}
+	case IndicatorCross:
+		c := &indicatorCross{rule: rule, fast: indicators.New(*rule.Fast)}
+		if rule.Slow != nil {
+			c.slow = indicators.New(*rule.Slow)
+		}
+
+		return c
+	default:
+		return &priceCross{rule: rule}
+	}
+}
+
+// crossing tracks the sign of the difference between a value and its reference, to tell when it crosses.
+type crossing struct {
+	prev    float64
+	hasPrev bool
+}
+
+// cross records diff, returning the direction of the crossing it completes, if any. Reaching the reference from
+// below crosses up, from above down.
+func (c *crossing) cross(diff float64) (Direction, bool) {
+	prev, hasPrev := c.prev, c.hasPrev
+	c.prev, c.hasPrev = diff, true
+
+	switch {
+	case !hasPrev:
+		return DirectionAny, false
+	case prev < 0 && diff >= 0:
+		return DirectionUp, true
+	case prev > 0 && diff <= 0:
+		return DirectionDown, true
+	default:
+		return DirectionAny, false
+	}
+}
+
+func (c *crossing) reset() {
+	c.hasPrev = false
+}
+
+func matches(want, got Direction) bool {
+	return want == DirectionAny || want == got
+}
+
+// priceCross compares every trade's price to the level.
+type priceCross struct {
+	rule     Rule
+	crossing crossing
+}
+
+func (c *priceCross) trade(_ time.Time, price float64) (signal, bool) {
+	direction, crossed := c.crossing.cross(price - c.rule.Level)
+	if !crossed || !matches(c.rule.Direction, direction) {
+		return signal{}, false
+	}
+
+	return signal{
+		value:     price,
+		reference: c.rule.Level,
+		message:   fmt.Sprintf("%s crossed %s %v at %v", c.rule.Symbol, direction, c.rule.Level, price),
+	}, true
+}
+
+func (c *priceCross) candle(*aggregator.Candlestick) (signal, bool) {
+	return signal{}, false
+}
+
+// sample is the lowest or highest price traded within a second.
+type sample struct {
+	second int64
+	price  float64
+}
+
+// percentMove keeps the lowest and highest prices of the window in monotonic queues, at most one sample per
+// second each, so a window holds at most as many samples as it has seconds.
+type percentMove struct {
+	rule Rule
+	// lows increase and highs decrease from the oldest sample, so their first samples are the window's extremes.
+	lows  []sample
+	highs []sample
+}
+
+func (c *percentMove) trade(at time.Time, price float64) (signal, bool) {
+	second := at.Unix()
+	c.lows = push(c.lows, sample{second: second, price: price}, func(kept float64) bool { return kept < price })
+	c.highs = push(c.highs, sample{second: second, price: price}, func(kept float64) bool { return kept > price })
+
+	oldest := at.Add(-c.rule.Window).Unix()
+	c.lows = evict(c.lows, oldest)
+	c.highs = evict(c.highs, oldest)
+
+	low, high := c.lows[0].price, c.highs[0].price
+
+	var s signal
+
+	switch {
+	case c.rule.Direction != DirectionDown && low > 0 && (price-low)/low*100 >= c.rule.Percent:
+		s = signal{value: price, reference: low, message: fmt.Sprintf("%s rose %.2f%% from %v to %v within %v",
+			c.rule.Symbol, (price-low)/low*100, low, price, c.rule.Window)}
+	case c.rule.Direction != DirectionUp && high > 0 && (high-price)/high*100 >= c.rule.Percent:
+		s = signal{value: price, reference: high, message: fmt.Sprintf("%s fell %.2f%% from %v to %v within %v",
+			c.rule.Symbol, (high-price)/high*100, high, price, c.rule.Window)}
+	default:
+		return signal{}, false
+	}
+
+	// The move starts over from this trade, so it fires once rather than on every trade beyond it.
+	c.lows = append(c.lows[:0], sample{second: second, price: price})
+	c.highs = append(c.highs[:0], sample{second: second, price: price})
+
+	return s, true
+}
+
+// push adds s to queue after dropping the samples that no longer matter, those keep returns false for. A sample
+// of the same second as the last kept one is dropped too, the kept one is as extreme and expires with it.
+func push(queue []sample, s sample, keep func(kept float64) bool) []sample {
+	for len(queue) > 0 && !keep(queue[len(queue)-1].price) {
+		queue = queue[:len(queue)-1]
+	}
+
+	if len(queue) > 0 && queue[len(queue)-1].second == s.second {
+		return queue
+	}
+
+	return append(queue, s)
+}
+
+// evict drops the samples before the second oldest, keeping the last one.
+func evict(queue []sample, oldest int64) []sample {
+	i := 0
+	for i < len(queue)-1 && queue[i].second < oldest {
+		i++
+	}
+
+	// Reslicing shrinks the capacity, so appending eventually copies the kept samples and frees the dropped ones.
+	return queue[i:]
+}
+
+func (c *percentMove) candle(*aggregator.Candlestick) (signal, bool) {
+	return signal{}, false
+}
+
+// volumeSpike compares the volume of every 1m candle to the average of the ones before.
+type volumeSpike struct {
+	rule    Rule
+	volumes []float64
+	next    int
+	sum     float64
+}
+
+func (c *volumeSpike) trade(time.Time, float64) (signal, bool) {
+	return signal{}, false
+}
+
+func (c *volumeSpike) candle(candle *aggregator.Candlestick) (signal, bool) {
+	var (
+		s     signal
+		fired bool
+	)
+
+	if len(c.volumes) == c.rule.Periods {
+		average := c.sum / float64(c.rule.Periods)
+
+		if average > 0 && candle.Volume >= c.rule.Multiplier*average {
+			s, fired = signal{value: candle.Volume, reference: average, message: fmt.Sprintf(
+				"%s traded %v in the minute of %s, %.2fx the average of %v", c.rule.Symbol, candle.Volume,
+				candle.Timestamp.Format(time.RFC3339), candle.Volume/average, average)}, true
+		}
+
+		c.sum -= c.volumes[c.next]
+		c.volumes[c.next] = candle.Volume
+		c.next = (c.next + 1) % c.rule.Periods
+	} else {
+		c.volumes = append(c.volumes, candle.Volume)
+	}
+
+	c.sum += candle.Volume
+
+	return s, fired
+}
+
+// indicatorCross compares the fast indicator of every 1m close to the slow one, or to the level.
+type indicatorCross struct {
+	rule     Rule
+	fast     indicators.Indicator
+	slow     indicators.Indicator
+	crossing crossing
+}
+
+func (c *indicatorCross) trade(time.Time, float64) (signal, bool) {
+	return signal{}, false
+}
+
+func (c *indicatorCross) candle(candle *aggregator.Candlestick) (signal, bool) {
+	fast := c.fast.Update(candle.Close)
+	reference, ready := c.rule.Level, fast.Ready
+
+	if c.slow != nil {
+		slow := c.slow.Update(candle.Close)
+		reference, ready = slow.Value, ready && slow.Ready
+	}
+
+	if !ready {
+		c.crossing.reset()
+
+		return signal{}, false
+	}
+
+	direction, crossed := c.crossing.cross(fast.Value - reference)
+	if !crossed || !matches(c.rule.Direction, direction) {
+		return signal{}, false
+	}
+
+	var over string
+	if c.rule.Slow != nil {
+		over = c.rule.Slow.String()
+	} else {
+		over = fmt.Sprint(c.rule.Level)
+	}
+
+	return signal{
+		value:     fast.Value,
+		reference: reference,
+		message: fmt.Sprintf("%s %s crossed %s %s at %v", c.rule.Symbol, c.rule.Fast, direction, over,
+			fast.Value),
+	}, true
+}
@@ -0,0 +1,354 @@
+package alerts
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+)
+
+// DefaultMaxRules bounds the rules of an Evaluator unless WithMaxRules says otherwise.
+const DefaultMaxRules = 1000
+
+var (
+	// ErrTooManyRules is returned by Add when the evaluator holds its maximum of rules.
+	ErrTooManyRules = errors.New("too many alert rules")
+	// ErrRuleNotFound is returned by Remove for an unknown rule.
+	ErrRuleNotFound = errors.New("alert rule not found")
+	// ErrNoVolume is returned by Add for a volume spike of a symbol traded without volume.
+	ErrNoVolume = errors.New("symbol is traded without volume")
+)
+
+// Firing is a rule firing. Value is the price, volume or indicator value that fired it, Reference the level,
+// window extreme, average volume or slow indicator value it reached.
+type Firing struct {
+	// ID identifies the firing, so deliveries retried or received twice can be told apart.
+	ID        string  `json:"id"`
+	Rule      Rule    `json:"rule"`
+	Value     float64 `json:"value"`
+	Reference float64 `json:"reference"`
+	Message   string  `json:"message"`
+	// Time is the time of the trade or the start of the candlestick that fired the rule.
+	Time    time.Time `json:"time"`
+	FiredAt time.Time `json:"fired_at"`
+}
+
+// Evaluator evaluates alert rules on trades and candlesticks, saving them to its store on every change. It is
+// safe for concurrent use.
+type Evaluator struct {
+	mu    sync.Mutex
+	rules map[string]*entry
+	// bySymbol indexes the rules by symbol and ID, so a trade only evaluates the rules of its symbol.
+	bySymbol map[string]map[string]*entry
+	store    Store
+	maxRules int
+	// volumeless are the symbols traded without quantity.
+	volumeless []string
+}
+
+// entry is a rule with the state of its condition.
+type entry struct {
+	rule      Rule
+	condition condition
+	lastFired time.Time
+}
+
+type options struct {
+	store      Store
+	maxRules   int
+	volumeless []string
+}
+
+type Option func(o *options)
+
+// WithStore saves the rules to store, from which Load restores them.
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithMaxRules bounds the rules the evaluator holds.
+func WithMaxRules(maxRules int) Option {
+	return func(o *options) {
+		o.maxRules = maxRules
+	}
+}
+
+// WithoutVolume declares symbols traded without quantity, e.g. synthetic symbols, whose volume spikes would never
+// fire and are rejected.
+func WithoutVolume(symbols ...string) Option {
+	return func(o *options) {
+		o.volumeless = append(o.volumeless, symbols...)
+	}
+}
+
+func NewEvaluator(opts ...Option) *Evaluator {
+	opt := options{maxRules: DefaultMaxRules}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return &Evaluator{
+		rules:      make(map[string]*entry),
+		bySymbol:   make(map[string]map[string]*entry),
+		store:      opt.store,
+		maxRules:   opt.maxRules,
+		volumeless: opt.volumeless,
+	}
+}
+
+// Load restores the rules saved in the store, returning how many. Their conditions start over, e.g. a percent
+// move's window starts with the next trade.
+func (e *Evaluator) Load() (int, error) {
+	if e.store == nil {
+		return 0, nil
+	}
+
+	rules, err := e.store.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var unsigned bool
+
+	for _, rule := range rules {
+		normalized, err := e.normalize(rule)
+		if err != nil {
+			return 0, fmt.Errorf("invalid saved alert rule %s: %w", rule.ID, err)
+		}
+
+		// Saved before rules had their own secret.
+		if normalized.WebhookURL != "" && normalized.WebhookSecret == "" {
+			normalized.WebhookSecret, unsigned = newSecret(), true
+		}
+
+		e.add(normalized)
+	}
+
+	if unsigned {
+		if err := e.save(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rules), nil
+}
+
+// Add normalizes and adds rule, returning it with its ID, creation time and, when it has a webhook, the secret its
+// deliveries are signed with.
+func (e *Evaluator) Add(rule Rule, now time.Time) (Rule, error) {
+	rule, err := e.normalize(rule)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	rule.ID, rule.CreatedAt = newID(), now.UTC()
+	if rule.WebhookURL != "" {
+		rule.WebhookSecret = newSecret()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.rules) >= e.maxRules {
+		return Rule{}, fmt.Errorf("%w, at most %d", ErrTooManyRules, e.maxRules)
+	}
+
+	e.add(rule)
+
+	if err := e.save(); err != nil {
+		e.remove(rule.ID)
+
+		return Rule{}, err
+	}
+
+	return rule, nil
+}
+
+// normalize normalizes rule, rejecting the volume spikes of symbols traded without volume.
+func (e *Evaluator) normalize(rule Rule) (Rule, error) {
+	rule, err := rule.Normalize()
+	if err != nil {
+		return Rule{}, err
+	}
+
+	if rule.Type == VolumeSpike && slices.Contains(e.volumeless, rule.Symbol) {
+		return Rule{}, fmt.Errorf("%s: %w: %s", rule.Type, ErrNoVolume, rule.Symbol)
+	}
+
+	return rule, nil
+}
+
+// Remove removes the rule of id, returning it.
+func (e *Evaluator) Remove(id string) (Rule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	removed, ok := e.rules[id]
+	if !ok {
+		return Rule{}, ErrRuleNotFound
+	}
+
+	e.remove(id)
+
+	if err := e.save(); err != nil {
+		e.add(removed.rule)
+
+		return Rule{}, err
+	}
+
+	return removed.rule, nil
+}
+
+// Rule returns the rule of id.
+func (e *Evaluator) Rule(id string) (Rule, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if entry, ok := e.rules[id]; ok {
+		return entry.rule, true
+	}
+
+	return Rule{}, false
+}
+
+// Rules returns the rules ordered by creation.
+func (e *Evaluator) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.sorted()
+}
+
+// Trade evaluates the rules of symbol on a trade, returning the ones that fired.
+func (e *Evaluator) Trade(symbol string, at time.Time, price float64) []Firing {
+	return e.evaluate(symbol, at, func(c condition) (signal, bool) {
+		return c.trade(at, price)
+	})
+}
+
+// Candle evaluates the rules of the candlestick's symbol on it, returning the ones that fired. Only completed 1m
+// candlesticks are evaluated, partial ones and the other bars are ignored.
+func (e *Evaluator) Candle(candle *aggregator.Candlestick) []Firing {
+	if candle.BarType != aggregator.BarTime || candle.Partial {
+		return nil
+	}
+
+	return e.evaluate(candle.Symbol, candle.Timestamp, func(c condition) (signal, bool) {
+		return c.candle(candle)
+	})
+}
+
+func (e *Evaluator) evaluate(symbol string, at time.Time, check func(c condition) (signal, bool)) []Firing {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var firings []Firing
+
+	for _, entry := range e.bySymbol[symbol] {
+		s, fired := check(entry.condition)
+		if !fired {
+			continue
+		}
+
+		// A rule cooling down keeps its state up to date, it just doesn't fire.
+		if !entry.lastFired.IsZero() && at.Sub(entry.lastFired) < entry.rule.Cooldown {
+			continue
+		}
+
+		entry.lastFired = at
+		firings = append(firings, Firing{
+			ID:        newID(),
+			Rule:      entry.rule,
+			Value:     s.value,
+			Reference: s.reference,
+			Message:   s.message,
+			Time:      at.UTC(),
+			FiredAt:   time.Now().UTC(),
+		})
+	}
+
+	slices.SortFunc(firings, func(x, y Firing) int {
+		return cmp.Or(x.Rule.CreatedAt.Compare(y.Rule.CreatedAt), cmp.Compare(x.Rule.ID, y.Rule.ID))
+	})
+
+	return firings
+}
+
+// add adds rule with a new state, the caller holds mu.
+func (e *Evaluator) add(rule Rule) {
+	added := &entry{rule: rule, condition: newCondition(rule)}
+	e.rules[rule.ID] = added
+
+	if e.bySymbol[rule.Symbol] == nil {
+		e.bySymbol[rule.Symbol] = make(map[string]*entry)
+	}
+
+	e.bySymbol[rule.Symbol][rule.ID] = added
+}
+
+// remove removes the rule of id, the caller holds mu.
+func (e *Evaluator) remove(id string) {
+	removed, ok := e.rules[id]
+	if !ok {
+		return
+	}
+
+	delete(e.rules, id)
+	delete(e.bySymbol[removed.rule.Symbol], id)
+
+	if len(e.bySymbol[removed.rule.Symbol]) == 0 {
+		delete(e.bySymbol, removed.rule.Symbol)
+	}
+}
+
+// save saves the rules to the store, the caller holds mu.
+func (e *Evaluator) save() error {
+	if e.store == nil {
+		return nil
+	}
+
+	return e.store.Save(e.sorted())
+}
+
+// sorted returns the rules ordered by creation, the caller holds mu.
+func (e *Evaluator) sorted() []Rule {
+	rules := make([]Rule, 0, len(e.rules))
+
+	for _, entry := range e.rules {
+		rules = append(rules, entry.rule)
+	}
+
+	slices.SortFunc(rules, func(x, y Rule) int {
+		return cmp.Or(x.CreatedAt.Compare(y.CreatedAt), cmp.Compare(x.ID, y.ID))
+	})
+
+	return rules
+}
+
+// newID returns a random identifier.
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// newSecret returns a random webhook secret of 256 bits.
+func newSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,229 @@
+// Package alerts evaluates alert rules on the trades and candlesticks of the ingestor.
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+)
+
+// Type is the condition of a Rule.
+type Type string
+
+// Types of Rule.Type.
+const (
+	// PriceCross fires when a trade's price crosses Level.
+	PriceCross Type = "price_cross"
+	// PercentMove fires when the price moved Percent from its lowest or highest trade within Window.
+	PercentMove Type = "percent_move"
+	// VolumeSpike fires when the volume of a 1m candle is Multiplier times the average of the Periods before.
+	VolumeSpike Type = "volume_spike"
+	// IndicatorCross fires when the Fast indicator of the 1m closes crosses the Slow one, or Level without it.
+	IndicatorCross Type = "indicator_cross"
+)
+
+// Direction restricts the moves a rule fires on.
+type Direction string
+
+// Directions of Rule.Direction.
+const (
+	DirectionAny  Direction = ""
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Bounds of the parameters of a Rule.
+const (
+	MaxWindow      = 24 * time.Hour
+	MaxPeriods     = 1000
+	DefaultPeriods = 20
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]+$`)
+
+// Rule is a condition on the trades or 1m candles of a symbol, and where its firings are delivered.
+type Rule struct {
+	// ID is assigned when the rule is added.
+	ID string `json:"id"`
+	// Owner is the identity that added the rule, empty without authentication.
+	Owner     string    `json:"owner,omitempty"`
+	Symbol    string    `json:"symbol"`
+	Type      Type      `json:"type"`
+	Direction Direction `json:"direction,omitempty"`
+	// Level is the price of a PriceCross, or the level the Fast indicator of an IndicatorCross crosses without a
+	// Slow one.
+	Level float64 `json:"level,omitempty"`
+	// Percent and Window are the move of a PercentMove, e.g. 5 for 5% within 15m.
+	Percent float64       `json:"percent,omitempty"`
+	Window  time.Duration `json:"window,omitempty"`
+	// Multiplier and Periods are the spike of a VolumeSpike, e.g. 3 times the average of the last 20 candles.
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Periods    int     `json:"periods,omitempty"`
+	// Fast and Slow are the indicators of an IndicatorCross, compared on their value: the SMA, EMA, RSI, MACD line
+	// or middle Bollinger band.
+	Fast *indicators.Spec `json:"fast,omitempty"`
+	Slow *indicators.Spec `json:"slow,omitempty"`
+	// WebhookURL receives the firings, which are only streamed without it.
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// WebhookSecret signs the deliveries to WebhookURL, a secret of the rule's owner alone, set by Evaluator.Add.
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	// Cooldown is how long a rule stays quiet after firing.
+	Cooldown  time.Duration `json:"cooldown,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Normalize returns r with its defaults set and without the parameters its type ignores, validating it.
+func (r Rule) Normalize() (Rule, error) {
+	out := Rule{
+		ID:         r.ID,
+		Owner:      r.Owner,
+		Symbol:     strings.ToUpper(r.Symbol),
+		Type:       r.Type,
+		Direction:  r.Direction,
+		WebhookURL: r.WebhookURL,
+		Cooldown:   r.Cooldown,
+		CreatedAt:  r.CreatedAt,
+	}
+
+	if !symbolPattern.MatchString(out.Symbol) {
+		return Rule{}, fmt.Errorf("symbol %q is not a symbol", r.Symbol)
+	}
+
+	if err := out.normalizeCondition(r); err != nil {
+		return Rule{}, fmt.Errorf("%s: %w", r.Type, err)
+	}
+
+	switch out.Direction {
+	case DirectionAny, DirectionUp, DirectionDown:
+	default:
+		return Rule{}, fmt.Errorf("unknown direction %q", r.Direction)
+	}
+
+	if out.Type == VolumeSpike {
+		out.Direction = DirectionAny
+	}
+
+	if out.WebhookURL != "" {
+		u, err := url.Parse(out.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Rule{}, fmt.Errorf("webhook URL %q is not an http or https URL", r.WebhookURL)
+		}
+
+		out.WebhookSecret = r.WebhookSecret
+	}
+
+	if out.Cooldown < 0 {
+		return Rule{}, fmt.Errorf("cooldown %v must not be negative", r.Cooldown)
+	}
+
+	return out, nil
+}
+
+// normalizeCondition copies and validates the parameters of the type of r.
+func (r *Rule) normalizeCondition(in Rule) error {
+	switch in.Type {
+	case PriceCross:
+		if !positive(in.Level) {
+			return fmt.Errorf("level %v must be positive", in.Level)
+		}
+
+		r.Level = in.Level
+	case PercentMove:
+		if !positive(in.Percent) {
+			return fmt.Errorf("percent %v must be positive", in.Percent)
+		}
+
+		if in.Window <= 0 || in.Window > MaxWindow {
+			return fmt.Errorf("window %v must be positive and at most %v", in.Window, MaxWindow)
+		}
+
+		r.Percent, r.Window = in.Percent, in.Window
+	case VolumeSpike:
+		if !positive(in.Multiplier) {
+			return fmt.Errorf("multiplier %v must be positive", in.Multiplier)
+		}
+
+		r.Multiplier, r.Periods = in.Multiplier, in.Periods
+		if r.Periods == 0 {
+			r.Periods = DefaultPeriods
+		}
+
+		if r.Periods < 1 || r.Periods > MaxPeriods {
+			return fmt.Errorf("periods %d must be between 1 and %d", in.Periods, MaxPeriods)
+		}
+	case IndicatorCross:
+		return r.normalizeIndicators(in)
+	default:
+		return errors.New("unknown rule type")
+	}
+
+	return nil
+}
+
+func (r *Rule) normalizeIndicators(in Rule) error {
+	if in.Fast == nil {
+		return errors.New("fast indicator is required")
+	}
+
+	fast, err := in.Fast.Normalize()
+	if err != nil {
+		return fmt.Errorf("fast indicator: %w", err)
+	}
+
+	r.Fast = &fast
+
+	if in.Slow == nil {
+		if math.IsNaN(in.Level) || math.IsInf(in.Level, 0) {
+			return fmt.Errorf("level %v must be a number", in.Level)
+		}
+
+		r.Level = in.Level
+
+		return nil
+	}
+
+	slow, err := in.Slow.Normalize()
+	if err != nil {
+		return fmt.Errorf("slow indicator: %w", err)
+	}
+
+	r.Slow = &slow
+
+	return nil
+}
+
+func positive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 0)
+}
+
+// String describes r, e.g. BTCUSDT price_cross up 100000.
+func (r Rule) String() string {
+	var condition string
+
+	switch r.Type {
+	case PriceCross:
+		condition = fmt.Sprintf("%v", r.Level)
+	case PercentMove:
+		condition = fmt.Sprintf("%v%% within %v", r.Percent, r.Window)
+	case VolumeSpike:
+		condition = fmt.Sprintf("%vx the average of %d candles", r.Multiplier, r.Periods)
+	case IndicatorCross:
+		if r.Slow != nil {
+			condition = fmt.Sprintf("%s over %s", r.Fast, r.Slow)
+		} else {
+			condition = fmt.Sprintf("%s over %v", r.Fast, r.Level)
+		}
+	}
+
+	if r.Direction != DirectionAny {
+		return fmt.Sprintf("%s %s %s %s", r.Symbol, r.Type, r.Direction, condition)
+	}
+
+	return fmt.Sprintf("%s %s %s", r.Symbol, r.Type, condition)
+}
@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// storeVersion is the format of the files written by FileStore, bumped on incompatible changes.
+const storeVersion = 1
+
+// Store saves the rules of an Evaluator, so they survive a restart.
+type Store interface {
+	Load() ([]Rule, error)
+	Save(rules []Rule) error
+}
+
+// FileStore saves the rules to a JSON file.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// rulesFile is the content of a FileStore's file.
+type rulesFile struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Load reads the saved rules, none when no file was saved yet.
+func (s *FileStore) Load() ([]Rule, error) {
+	data, err := os.ReadFile(s.path) //nolint:gosec // path is the configured rules file.
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules %s: %w", s.path, err)
+	}
+
+	var file rulesFile
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode alert rules %s: %w", s.path, err)
+	}
+
+	if file.Version != storeVersion {
+		return nil, fmt.Errorf("alert rules %s have version %d, expected %d", s.path, file.Version, storeVersion)
+	}
+
+	return file.Rules, nil
+}
+
+// Save writes rules to a temporary file renamed over the file, so a crash while writing leaves the previous
+// rules intact.
+func (s *FileStore) Save(rules []Rule) error {
+	data, err := json.MarshalIndent(rulesFile{Version: storeVersion, Rules: rules}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode alert rules: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create alert rules file: %w", err)
+	}
+
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write alert rules: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to sync alert rules: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close alert rules: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace alert rules %s: %w", s.path, err)
+	}
+
+	return nil
+}
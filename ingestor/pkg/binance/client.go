@@ -0,0 +1,316 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+
+var logger = logging.Component("binance")
+
+type Config struct {
+	WebsocketBaseURL string
+	Symbols          []string
+	// OnConnect and OnParseError are optional hooks called on every websocket connection and on every
+	// message that isn't an aggregated trade, e.g. to count them.
+	OnConnect    func()
+	OnParseError func()
+}
+
+type AggTrade struct {
+	Stream string    `json:"stream"`
+	Data   TradeData `json:"data"`
+}
+
+type TradeData struct {
+	EventType     string `json:"e"`
+	EventTime     int64  `json:"E"`
+	Symbol        string `json:"s"`
+	AggTradeID    int64  `json:"a"`
+	Price         string `json:"p"`
+	Quantity      string `json:"q"`
+	FirstTradeID  int64  `json:"f"`
+	LastTradeID   int64  `json:"l"`
+	TradeTime     int64  `json:"T"`
+	IsMarketMaker bool   `json:"m"`
+	Ignore        bool   `json:"M"`
+	// SpanContext is the span of the trade in the pipeline, unset when tracing is disabled.
+	SpanContext trace.SpanContext `json:"-"`
+}
+
+// Connection describes an open websocket connection and the streams it is subscribed to.
+type Connection struct {
+	URL         string    `json:"url"`
+	Streams     []string  `json:"streams"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+type Client struct {
+	symbols      []string
+	websocketURL string
+	conn         *websocket.Conn
+	onConnect    func()
+	onParseError func()
+
+	// mu guards connection, which is read by other goroutines than the one connecting, and serializes the
+	// writes of subscription requests.
+	mu         sync.Mutex
+	connection *Connection
+	// lastRequestID numbers the subscription requests, so their responses can be matched in the logs.
+	lastRequestID int64
+}
+
+// request is a live subscription request sent over the combined stream connection.
+type request struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int64    `json:"id"`
+}
+
+// response answers a request, it is read instead of a trade.
+type response struct {
+	ID    *int64 `json:"id"`
+	Error *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+func NewClient(cfg *Config) *Client {
+	return &Client{
+		symbols:      cfg.Symbols,
+		websocketURL: cfg.WebsocketBaseURL,
+		onConnect:    cfg.OnConnect,
+		onParseError: cfg.OnParseError,
+	}
+}
+
+func (c *Client) Connect() error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	logger.Info("connecting to Binance websocket", "url", c.websocketURL, "symbols", c.symbols)
+
+	streamURL, err := url.Parse(c.websocketURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse websocket url: %w", err)
+	}
+
+	streamURL.Path = path.Join(streamURL.Path, "stream")
+	streams := streamNames(c.symbols)
+
+	query := streamURL.Query()
+	query.Set("streams", strings.Join(streams, "/"))
+	streamURL.RawQuery = query.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(streamURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+
+	c.conn = conn
+
+	c.mu.Lock()
+	c.connection = &Connection{URL: c.websocketURL, Streams: streams, ConnectedAt: time.Now().UTC()}
+	c.mu.Unlock()
+
+	if c.onConnect != nil {
+		c.onConnect()
+	}
+
+	return nil
+}
+
+func (c *Client) ReadAggregatedTicks(ctx context.Context, tradeChan chan<- TradeData) error {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return fmt.Errorf("connection error: %w", err)
+		}
+	}
+
+	defer close(tradeChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("context cancelled, closing websocket")
+
+			return ctx.Err()
+		default:
+			_, message, err := c.conn.ReadMessage()
+			if err != nil {
+				logger.Error("websocket read failed", logging.Err(err))
+
+				return err
+			}
+
+			var aggTrade AggTrade
+
+			if err := json.Unmarshal(message, &aggTrade); err != nil {
+				logger.Warn("error unmarshalling tick data", "message", string(message), logging.Err(err))
+
+				if c.onParseError != nil {
+					c.onParseError()
+				}
+
+				continue
+			}
+
+			// Responses to subscription requests come without a stream.
+			if aggTrade.Stream == "" {
+				c.handleResponse(message)
+
+				continue
+			}
+
+			aggTrade.Data.SpanContext = receiveSpan(ctx, aggTrade.Data)
+
+			select {
+			case tradeChan <- aggTrade.Data:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// receiveSpan records the receipt of a trade. It starts at the Binance event time, so its duration is the
+// latency from the exchange, as far as the clocks agree.
+func receiveSpan(ctx context.Context, trade TradeData) trace.SpanContext {
+	_, span := otel.Tracer(tracerName).Start(ctx, "binance.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(time.UnixMilli(trade.EventTime)),
+		trace.WithAttributes(
+			attribute.String("symbol", trade.Symbol),
+			attribute.Int64("binance.agg_trade_id", trade.AggTradeID),
+		))
+	defer span.End()
+
+	return span.SpanContext()
+}
+
+// Subscribe subscribes the open connection to the trades of symbols, which are also subscribed to on later
+// connections.
+func (c *Client) Subscribe(symbols ...string) error {
+	return c.updateSubscription("SUBSCRIBE", symbols)
+}
+
+// Unsubscribe unsubscribes the open connection from the trades of symbols. Trades already in flight may still
+// be read after it returns.
+func (c *Client) Unsubscribe(symbols ...string) error {
+	return c.updateSubscription("UNSUBSCRIBE", symbols)
+}
+
+func (c *Client) updateSubscription(method string, symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil || c.connection == nil {
+		return errors.New("not connected")
+	}
+
+	c.lastRequestID++
+	streams := streamNames(symbols)
+
+	if err := c.conn.WriteJSON(request{Method: method, Params: streams, ID: c.lastRequestID}); err != nil {
+		return fmt.Errorf("failed to send %s request: %w", method, err)
+	}
+
+	logger.Info("sent subscription request", "method", method, "streams", streams, "request_id", c.lastRequestID)
+
+	if method == "SUBSCRIBE" {
+		c.symbols = append(c.symbols, symbols...)
+		c.connection.Streams = append(c.connection.Streams, streams...)
+
+		return nil
+	}
+
+	c.symbols = slices.DeleteFunc(c.symbols, func(symbol string) bool { return slices.Contains(symbols, symbol) })
+	c.connection.Streams = slices.DeleteFunc(c.connection.Streams, func(stream string) bool {
+		return slices.Contains(streams, stream)
+	})
+
+	return nil
+}
+
+// handleResponse logs the response to a subscription request.
+func (c *Client) handleResponse(message []byte) {
+	var resp response
+
+	if err := json.Unmarshal(message, &resp); err != nil || resp.ID == nil {
+		logger.Warn("unexpected websocket message", "message", string(message))
+
+		if c.onParseError != nil {
+			c.onParseError()
+		}
+
+		return
+	}
+
+	if resp.Error != nil {
+		logger.Error("subscription request failed", "request_id", *resp.ID, "code", resp.Error.Code,
+			logging.Err(errors.New(resp.Error.Msg)))
+
+		return
+	}
+
+	logger.Debug("subscription request succeeded", "request_id", *resp.ID)
+}
+
+func streamNames(symbols []string) []string {
+	streams := make([]string, 0, len(symbols))
+
+	for _, symbol := range symbols {
+		streams = append(streams, fmt.Sprintf("%s@aggTrade", strings.ToLower(symbol)))
+	}
+
+	return streams
+}
+
+// Connections returns the open websocket connections with their streams, none before Connect or after Close.
+func (c *Client) Connections() []Connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.connection == nil {
+		return []Connection{}
+	}
+
+	connection := *c.connection
+	connection.Streams = slices.Clone(connection.Streams)
+
+	return []Connection{connection}
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.connection = nil
+	c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+
+	return nil
+}
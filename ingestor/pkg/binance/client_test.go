@@ -0,0 +1,119 @@
+package binance_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+// fakeStream serves a combined stream, answering subscription requests and sending a trade of every stream
+// subscribed live.
+func fakeStream(t *testing.T, requests chan<- map[string]any) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer func() { _ = conn.Close() }()
+
+		for {
+			var req map[string]any
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+
+			requests <- req
+
+			_ = conn.WriteJSON(map[string]any{"result": nil, "id": req["id"]})
+
+			if req["method"] != "SUBSCRIBE" {
+				continue
+			}
+
+			for _, param := range req["params"].([]any) {
+				stream := param.(string)
+				symbol := strings.ToUpper(strings.TrimSuffix(stream, "@aggTrade"))
+
+				_ = conn.WriteJSON(map[string]any{"stream": stream, "data": map[string]any{
+					"e": "aggTrade", "s": symbol, "p": "1.0", "q": "2.0", "T": time.Now().UnixMilli(),
+				}})
+			}
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	requests := make(chan map[string]any, 2)
+	server := fakeStream(t, requests)
+
+	client := binance.NewClient(&binance.Config{
+		WebsocketBaseURL: "ws" + strings.TrimPrefix(server.URL, "http"),
+		Symbols:          []string{"BTCUSDT"},
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trades := make(chan binance.TradeData, 1)
+
+	go func() { _ = client.ReadAggregatedTicks(ctx, trades) }()
+
+	if err := client.Subscribe("ETHUSDT"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if req := <-requests; req["method"] != "SUBSCRIBE" || req["params"].([]any)[0] != "ethusdt@aggTrade" {
+		t.Errorf("unexpected subscription request %v", req)
+	}
+
+	// The response to the request is not a trade.
+	select {
+	case trade := <-trades:
+		if trade.Symbol != "ETHUSDT" {
+			t.Errorf("expected an ETHUSDT trade, got %+v", trade)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a trade of the subscribed symbol")
+	}
+
+	if err := client.Unsubscribe("BTCUSDT"); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+
+	if req := <-requests; req["method"] != "UNSUBSCRIBE" {
+		t.Errorf("unexpected unsubscription request %v", req)
+	}
+
+	connections := client.Connections()
+	if len(connections) != 1 || len(connections[0].Streams) != 1 || connections[0].Streams[0] != "ethusdt@aggTrade" {
+		t.Errorf("expected the connection to list only ethusdt@aggTrade, got %+v", connections)
+	}
+}
+
+func TestClient_Subscribe_NotConnected(t *testing.T) {
+	client := binance.NewClient(&binance.Config{WebsocketBaseURL: "ws://localhost", Symbols: []string{"BTCUSDT"}})
+
+	if err := client.Subscribe("ETHUSDT"); err == nil {
+		t.Error("expected an error subscribing before Connect")
+	}
+}
@@ -0,0 +1,156 @@
+package envconfig_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/envconfig"
+	"github.com/spf13/viper"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+
+	return path
+}
+
+func TestReadFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "app:\n  grpc_port: 50051\n  name: yaml\nBINANCE_SYMBOLS: [BTCUSDT, ETHUSDT]\n",
+		"config.toml": "BINANCE_SYMBOLS = [\"BTCUSDT\", \"ETHUSDT\"]\n\n[app]\ngrpc_port = 50051\nname = \"toml\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("APP_NAME", "env")
+
+			v := viper.New()
+			v.AutomaticEnv()
+
+			if err := envconfig.ReadFile(v, writeFile(t, name, content)); err != nil {
+				t.Fatalf("ReadFile failed: %v", err)
+			}
+
+			if got := v.GetInt("APP_GRPC_PORT"); got != 50051 {
+				t.Errorf("expected the nested port 50051, got %d", got)
+			}
+
+			if got := v.GetStringSlice("BINANCE_SYMBOLS"); len(got) != 2 || got[1] != "ETHUSDT" {
+				t.Errorf("expected the flat symbols, got %v", got)
+			}
+
+			if got := v.GetString("APP_NAME"); got != "env" {
+				t.Errorf("expected the environment to override the file, got %q", got)
+			}
+		})
+	}
+}
+
+func TestReadFile_Invalid(t *testing.T) {
+	v := viper.New()
+
+	if err := envconfig.ReadFile(v, writeFile(t, "config.ini", "a=b")); err == nil {
+		t.Error("expected an error for an unsupported file type")
+	}
+
+	if err := envconfig.ReadFile(v, writeFile(t, "config.yaml", "app: [")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+
+	if err := envconfig.ReadFile(v, filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cfg := struct {
+		App struct {
+			Port    uint16        `env:"APP_PORT"`
+			Timeout time.Duration `env:"APP_TIMEOUT"`
+		}
+		Symbols  []string `env:"SYMBOLS"`
+		APIKey   string   `env:"API_KEY" secret:"true"`
+		Token    string   `env:"TOKEN" secret:"true"`
+		WriteDSN string   `env:"WRITE_DSN" secret:"dsn"`
+		ReadDSN  string   `env:"READ_DSN" secret:"dsn"`
+		internal string
+	}{
+		Symbols:  []string{"BTCUSDT", "ETHUSDT"},
+		APIKey:   "hunter2",
+		WriteDSN: "postgres://app:hunter2@db:5432/candles",
+		ReadDSN:  "host=db password=hunter2 dbname=candles",
+	}
+	cfg.App.Port, cfg.App.Timeout = 8080, time.Minute
+
+	var buf bytes.Buffer
+	if err := envconfig.Write(&buf, &cfg); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := `APP_PORT=8080
+APP_TIMEOUT=1m0s
+SYMBOLS="BTCUSDT ETHUSDT"
+API_KEY=REDACTED
+TOKEN=
+WRITE_DSN=postgres://app:REDACTED@db:5432/candles
+READ_DSN="host=db password=REDACTED dbname=candles"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Error("expected every secret redacted")
+	}
+}
+
+func TestProblems(t *testing.T) {
+	var problems envconfig.Problems
+
+	problems.Required("DB_WRITE_DSN", "")
+	problems.Port("APP_GRPC_PORT", 50051)
+	problems.Port("APP_METRICS_PORT", 70000)
+	problems.URL("BINANCE_WEBSOCKET_BASE_URL", "https://stream.binance.com", "ws", "wss")
+	problems.URL("BINANCE_REST_BASE_URL", "api.binance.com", "https")
+	problems.HostPort("SERVER_ADDRESS", "localhost")
+	problems.OneOf("LOG_FORMAT", "JSON", "json", "text")
+	problems.LogLevel("LOG_LEVEL", "verbose")
+	problems.Between("TRACING_SAMPLE_RATIO", 1.5, 0, 1)
+
+	err := problems.Err()
+	if err == nil {
+		t.Fatal("expected problems")
+	}
+
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("expected 7 problems, got %d:\n%v", len(lines), err)
+	}
+
+	for i, key := range []string{
+		"DB_WRITE_DSN", "APP_METRICS_PORT", "BINANCE_WEBSOCKET_BASE_URL", "BINANCE_REST_BASE_URL", "SERVER_ADDRESS",
+		"LOG_LEVEL",
+		"TRACING_SAMPLE_RATIO",
+	} {
+		if !strings.HasPrefix(lines[i], key+": ") {
+			t.Errorf("expected problem %d to be about %s, got %q", i, key, lines[i])
+		}
+	}
+
+	only := problems.Only("LOG_LEVEL", "APP_GRPC_PORT").Err()
+	if only == nil || !strings.HasPrefix(only.Error(), "LOG_LEVEL: ") || strings.Contains(only.Error(), "\n") {
+		t.Errorf("expected only the LOG_LEVEL problem, got %v", only)
+	}
+
+	var none envconfig.Problems
+	if err := none.Err(); err != nil {
+		t.Errorf("expected no error without problems, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Problems collects the invalid settings of a configuration, so they are reported at once rather than failing
+// later one by one. Its zero value is ready to use.
+type Problems struct {
+	keys []string
+	errs []error
+}
+
+// Addf records a problem with the setting key.
+func (p *Problems) Addf(key, format string, args ...any) {
+	p.keys = append(p.keys, key)
+	p.errs = append(p.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+// Only returns the problems with the settings keys, for a command that uses only those.
+func (p *Problems) Only(keys ...string) *Problems {
+	var only Problems
+
+	for i, key := range p.keys {
+		if slices.Contains(keys, key) {
+			only.keys = append(only.keys, key)
+			only.errs = append(only.errs, p.errs[i])
+		}
+	}
+
+	return &only
+}
+
+// Required checks that value is set.
+func (p *Problems) Required(key, value string) {
+	if value == "" {
+		p.Addf(key, "is required")
+	}
+}
+
+// Port checks that port is set and is a TCP port, between 1 and 65535.
+func (p *Problems) Port(key string, port int) {
+	switch {
+	case port == 0:
+		p.Addf(key, "is required")
+	case port < 1 || port > math.MaxUint16:
+		p.Addf(key, "%d must be between 1 and %d", port, math.MaxUint16)
+	}
+}
+
+// URL checks that value is an absolute URL with one of schemes, when it is set.
+func (p *Problems) URL(key, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" {
+		p.Addf(key, "%q is not an absolute URL", value)
+
+		return
+	}
+
+	if !slices.Contains(schemes, parsed.Scheme) {
+		p.Addf(key, "scheme of %q must be one of %s", value, strings.Join(schemes, ", "))
+	}
+}
+
+// HostPort checks that value is a host:port address, when it is set.
+func (p *Problems) HostPort(key, value string) {
+	if value == "" {
+		return
+	}
+
+	if _, port, err := net.SplitHostPort(value); err != nil || port == "" {
+		p.Addf(key, "%q is not a host:port address", value)
+	}
+}
+
+// OneOf checks that value is one of allowed, ignoring case.
+func (p *Problems) OneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, strings.ToLower(value)) {
+		p.Addf(key, "%q must be one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+// LogLevel checks that value is a log level, when it is set.
+func (p *Problems) LogLevel(key, value string) {
+	var level slog.Level
+
+	if value != "" && level.UnmarshalText([]byte(value)) != nil {
+		p.Addf(key, "%q is not a log level, expected debug, info, warn or error", value)
+	}
+}
+
+// Between checks that value is within [lower, upper].
+func (p *Problems) Between(key string, value, lower, upper float64) {
+	if value < lower || value > upper {
+		p.Addf(key, "%v must be between %v and %v", value, lower, upper)
+	}
+}
+
+// NotNegative checks that value isn't negative.
+func (p *Problems) NotNegative(key string, value float64) {
+	if value < 0 {
+		p.Addf(key, "%v must not be negative", value)
+	}
+}
+
+// Err returns the problems joined, one per line, or nil when there are none.
+func (p *Problems) Err() error {
+	return errors.Join(p.errs...)
+}
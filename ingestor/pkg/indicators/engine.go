@@ -0,0 +1,162 @@
+package indicators
+
+import "sync"
+
+// MaxHistory bounds the closes an Engine keeps per series.
+const MaxHistory = 10000
+
+// Key identifies a series of closes, the candles of a symbol at an interval, e.g. 1m or renko:50.
+type Key struct {
+	Symbol   string
+	Interval string
+}
+
+// Engine maintains the indicators requested by subscribers for every series it is fed. It keeps the last closes
+// of each series, so an indicator requested later starts warmed up instead of waiting for new candles. It is
+// safe for concurrent use.
+type Engine struct {
+	mu      sync.Mutex
+	history int
+	// specs counts the subscribers requesting each indicator.
+	specs  map[Spec]int
+	series map[Key]*series
+}
+
+// series holds the last closes of a series and the state of its indicators.
+type series struct {
+	closes     []float64
+	indicators map[Spec]Indicator
+}
+
+// NewEngine creates an engine keeping the last history closes of each series.
+func NewEngine(history int) *Engine {
+	return &Engine{
+		history: history,
+		specs:   make(map[Spec]int),
+		series:  make(map[Key]*series),
+	}
+}
+
+// Register starts maintaining specs, normalized, for every series, until they are unregistered as many times.
+func (e *Engine) Register(specs ...Spec) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, spec := range specs {
+		e.specs[spec]++
+	}
+}
+
+// Unregister stops maintaining specs once no subscriber requests them, dropping their state.
+func (e *Engine) Unregister(specs ...Spec) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, spec := range specs {
+		if e.specs[spec]--; e.specs[spec] > 0 {
+			continue
+		}
+
+		delete(e.specs, spec)
+
+		for _, s := range e.series {
+			delete(s.indicators, spec)
+		}
+	}
+}
+
+// Update adds the close of a candle of key and returns the values of the registered indicators. An indicator
+// new to the series is first fed its earlier closes.
+func (e *Engine) Update(key Key, close float64) []Value {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := e.get(key)
+
+	for spec := range e.specs {
+		if _, ok := s.indicators[spec]; !ok {
+			indicator := New(spec)
+
+			for _, c := range s.closes {
+				indicator.Update(c)
+			}
+
+			s.indicators[spec] = indicator
+		}
+	}
+
+	s.add(close, e.history)
+
+	values := make([]Value, 0, len(s.indicators))
+
+	for _, indicator := range s.indicators {
+		values = append(values, indicator.Update(close))
+	}
+
+	return values
+}
+
+// Warm adds earlier closes of key, oldest first, e.g. from stored candles on startup. Closes older than the
+// ones the series already has are ignored, as are the indicators already maintained for it.
+func (e *Engine) Warm(key Key, closes []float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := e.get(key)
+	if len(s.closes) > 0 {
+		return
+	}
+
+	for _, c := range closes {
+		s.add(c, e.history)
+	}
+}
+
+// Closes returns how many closes of key are kept.
+func (e *Engine) Closes(key Key) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if s, ok := e.series[key]; ok {
+		return len(s.closes)
+	}
+
+	return 0
+}
+
+// Remove drops the series of symbol, e.g. once it is no longer traded.
+func (e *Engine) Remove(symbol string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key := range e.series {
+		if key.Symbol == symbol {
+			delete(e.series, key)
+		}
+	}
+}
+
+// get returns the series of key, creating it. The caller holds mu.
+func (e *Engine) get(key Key) *series {
+	s, ok := e.series[key]
+	if !ok {
+		s = &series{indicators: make(map[Spec]Indicator)}
+		e.series[key] = s
+	}
+
+	return s
+}
+
+// add keeps close, dropping the oldest close past history.
+func (s *series) add(close float64, history int) {
+	if history <= 0 {
+		return
+	}
+
+	// Reslicing shrinks the capacity too, so appending eventually copies the kept closes to a new array and the
+	// dropped ones are freed.
+	s.closes = append(s.closes, close)
+	if len(s.closes) > history {
+		s.closes = s.closes[len(s.closes)-history:]
+	}
+}
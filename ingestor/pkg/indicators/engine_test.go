@@ -0,0 +1,60 @@
+package indicators_test
+
+import (
+	"testing"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+)
+
+func TestEngine(t *testing.T) {
+	t.Parallel()
+
+	engine := indicators.NewEngine(3)
+	key := indicators.Key{Symbol: "BTCUSDT", Interval: "1m"}
+	sma := indicators.Spec{Type: indicators.SMA, Period: 3}
+
+	engine.Warm(key, []float64{1, 2, 3, 4})
+
+	if closes := engine.Closes(key); closes != 3 {
+		t.Errorf("expected the last 3 closes kept, got %d", closes)
+	}
+
+	if values := engine.Update(key, 5); len(values) != 0 {
+		t.Errorf("expected no values without registered indicators, got %+v", values)
+	}
+
+	// Registered late, the SMA is fed the kept closes 3, 4 and 5 first, so it is ready with the next one.
+	engine.Register(sma, sma)
+
+	values := engine.Update(key, 6)
+	if len(values) != 1 || !values[0].Ready || values[0].Value != 5 {
+		t.Errorf("expected a warmed up SMA of 5, got %+v", values)
+	}
+
+	// Warming a series already fed is ignored.
+	engine.Warm(key, []float64{100, 100, 100})
+
+	other := indicators.Key{Symbol: "ETHUSDT", Interval: "1m"}
+	if values := engine.Update(other, 10); len(values) != 1 || values[0].Ready {
+		t.Errorf("expected an SMA not ready for a new series, got %+v", values)
+	}
+
+	// Registered twice, the SMA is kept until unregistered twice.
+	engine.Unregister(sma)
+
+	if values := engine.Update(key, 7); len(values) != 1 || values[0].Value != 6 {
+		t.Errorf("expected an SMA of 6, got %+v", values)
+	}
+
+	engine.Unregister(sma)
+
+	if values := engine.Update(key, 8); len(values) != 0 {
+		t.Errorf("expected no values once unregistered, got %+v", values)
+	}
+
+	engine.Remove("ETHUSDT")
+
+	if closes := engine.Closes(other); closes != 0 {
+		t.Errorf("expected the removed series dropped, got %d closes", closes)
+	}
+}
@@ -0,0 +1,337 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Type is a technical indicator.
+type Type string
+
+// Types of Spec.Type.
+const (
+	SMA       Type = "sma"
+	EMA       Type = "ema"
+	RSI       Type = "rsi"
+	MACD      Type = "macd"
+	Bollinger Type = "bollinger"
+)
+
+// MaxPeriod bounds the periods of a Spec, as SMA and Bollinger bands keep that many closes.
+const MaxPeriod = 1000
+
+// Default parameters of the indicators, used for the ones a Spec leaves unset.
+const (
+	DefaultPeriod       = 20
+	DefaultRSIPeriod    = 14
+	DefaultFastPeriod   = 12
+	DefaultSlowPeriod   = 26
+	DefaultSignalPeriod = 9
+	DefaultStdDev       = 2
+)
+
+// Spec is an indicator with its parameters. Period applies to SMA, EMA, RSI and Bollinger bands, FastPeriod,
+// SlowPeriod and SignalPeriod to MACD, and StdDev, the width of the bands in standard deviations, to Bollinger
+// bands. Specs are comparable, so equal ones share their state.
+type Spec struct {
+	Type         Type    `json:"type"`
+	Period       int     `json:"period,omitempty"`
+	FastPeriod   int     `json:"fast_period,omitempty"`
+	SlowPeriod   int     `json:"slow_period,omitempty"`
+	SignalPeriod int     `json:"signal_period,omitempty"`
+	StdDev       float64 `json:"std_dev,omitempty"`
+}
+
+// Normalize returns s with the defaults of its unset parameters and without the parameters its type ignores,
+// validating it.
+func (s Spec) Normalize() (Spec, error) {
+	out := Spec{Type: s.Type}
+
+	switch s.Type {
+	case SMA, EMA, RSI, Bollinger:
+		out.Period = s.Period
+		if out.Period == 0 {
+			out.Period = DefaultPeriod
+			if s.Type == RSI {
+				out.Period = DefaultRSIPeriod
+			}
+		}
+
+		if err := checkPeriod("period", out.Period); err != nil {
+			return Spec{}, fmt.Errorf("%s: %w", s.Type, err)
+		}
+	case MACD:
+		out.FastPeriod = withDefault(s.FastPeriod, DefaultFastPeriod)
+		out.SlowPeriod = withDefault(s.SlowPeriod, DefaultSlowPeriod)
+		out.SignalPeriod = withDefault(s.SignalPeriod, DefaultSignalPeriod)
+
+		for name, period := range map[string]int{
+			"fast period": out.FastPeriod, "slow period": out.SlowPeriod, "signal period": out.SignalPeriod,
+		} {
+			if err := checkPeriod(name, period); err != nil {
+				return Spec{}, fmt.Errorf("%s: %w", s.Type, err)
+			}
+		}
+
+		if out.FastPeriod >= out.SlowPeriod {
+			return Spec{}, fmt.Errorf("%s: fast period %d must be shorter than slow period %d", s.Type,
+				out.FastPeriod, out.SlowPeriod)
+		}
+	default:
+		return Spec{}, fmt.Errorf("unknown indicator %q", s.Type)
+	}
+
+	if s.Type == Bollinger {
+		out.StdDev = s.StdDev
+		if out.StdDev == 0 {
+			out.StdDev = DefaultStdDev
+		}
+
+		if out.StdDev < 0 || math.IsInf(out.StdDev, 0) || math.IsNaN(out.StdDev) {
+			return Spec{}, fmt.Errorf("%s: standard deviations %v must be positive", s.Type, s.StdDev)
+		}
+	}
+
+	return out, nil
+}
+
+func withDefault(period, fallback int) int {
+	if period == 0 {
+		return fallback
+	}
+
+	return period
+}
+
+func checkPeriod(name string, period int) error {
+	if period < 1 || period > MaxPeriod {
+		return fmt.Errorf("%s %d must be between 1 and %d", name, period, MaxPeriod)
+	}
+
+	return nil
+}
+
+// String describes s, e.g. sma(20), macd(12,26,9) or bollinger(20,2).
+func (s Spec) String() string {
+	switch s.Type {
+	case MACD:
+		return fmt.Sprintf("%s(%d,%d,%d)", s.Type, s.FastPeriod, s.SlowPeriod, s.SignalPeriod)
+	case Bollinger:
+		return fmt.Sprintf("%s(%d,%s)", s.Type, s.Period, strconv.FormatFloat(s.StdDev, 'f', -1, 64))
+	default:
+		return fmt.Sprintf("%s(%d)", s.Type, s.Period)
+	}
+}
+
+// Value is the value of an indicator after a close. Value is the SMA, EMA, RSI, MACD line or middle Bollinger
+// band, Signal and Histogram are set for MACD, Upper and Lower for Bollinger bands. Ready is false, and the
+// values zero, until the indicator received enough closes.
+type Value struct {
+	Spec      Spec
+	Ready     bool
+	Value     float64
+	Signal    float64
+	Histogram float64
+	Upper     float64
+	Lower     float64
+}
+
+// Indicator computes an indicator incrementally, one close at a time.
+type Indicator interface {
+	Update(close float64) Value
+}
+
+// New returns the indicator of spec, which must be normalized.
+func New(spec Spec) Indicator {
+	switch spec.Type {
+	case EMA:
+		return &ema{spec: spec, alpha: 2 / float64(spec.Period+1)}
+	case RSI:
+		return &rsi{spec: spec}
+	case MACD:
+		return &macd{
+			spec:   spec,
+			fast:   &ema{alpha: 2 / float64(spec.FastPeriod+1), spec: Spec{Period: spec.FastPeriod}},
+			slow:   &ema{alpha: 2 / float64(spec.SlowPeriod+1), spec: Spec{Period: spec.SlowPeriod}},
+			signal: &ema{alpha: 2 / float64(spec.SignalPeriod+1), spec: Spec{Period: spec.SignalPeriod}},
+		}
+	case Bollinger:
+		return &bollinger{spec: spec, window: window{size: spec.Period}}
+	default:
+		return &sma{spec: spec, window: window{size: spec.Period}}
+	}
+}
+
+// window holds the last size closes and their sum.
+type window struct {
+	size   int
+	closes []float64
+	next   int
+	sum    float64
+}
+
+func (w *window) add(close float64) {
+	if len(w.closes) < w.size {
+		w.closes = append(w.closes, close)
+	} else {
+		w.sum -= w.closes[w.next]
+		w.closes[w.next] = close
+		w.next = (w.next + 1) % w.size
+	}
+
+	w.sum += close
+}
+
+func (w *window) full() bool {
+	return len(w.closes) == w.size
+}
+
+func (w *window) mean() float64 {
+	return w.sum / float64(len(w.closes))
+}
+
+// sma is the mean of the last Period closes.
+type sma struct {
+	spec   Spec
+	window window
+}
+
+func (s *sma) Update(close float64) Value {
+	s.window.add(close)
+
+	if !s.window.full() {
+		return Value{Spec: s.spec}
+	}
+
+	return Value{Spec: s.spec, Ready: true, Value: s.window.mean()}
+}
+
+// ema weighs closes by 2/(Period+1), seeded with the SMA of the first Period closes.
+type ema struct {
+	spec  Spec
+	alpha float64
+	count int
+	value float64
+}
+
+func (e *ema) Update(close float64) Value {
+	e.count++
+
+	switch {
+	case e.count < e.spec.Period:
+		e.value += close
+
+		return Value{Spec: e.spec}
+	case e.count == e.spec.Period:
+		e.value = (e.value + close) / float64(e.spec.Period)
+	default:
+		e.value += e.alpha * (close - e.value)
+	}
+
+	return Value{Spec: e.spec, Ready: true, Value: e.value}
+}
+
+// rsi is Wilder's relative strength index: the average gains and losses of the first Period changes are their
+// means, later ones are smoothed by 1/Period.
+type rsi struct {
+	spec      Spec
+	count     int
+	prev      float64
+	avgGain   float64
+	avgLoss   float64
+	hasPrev   bool
+	smoothing bool
+}
+
+func (r *rsi) Update(close float64) Value {
+	if !r.hasPrev {
+		r.prev, r.hasPrev = close, true
+
+		return Value{Spec: r.spec}
+	}
+
+	change := close - r.prev
+	r.prev = close
+	gain, loss := max(change, 0), max(-change, 0)
+	period := float64(r.spec.Period)
+
+	if r.smoothing {
+		r.avgGain = (r.avgGain*(period-1) + gain) / period
+		r.avgLoss = (r.avgLoss*(period-1) + loss) / period
+	} else {
+		r.avgGain += gain
+		r.avgLoss += loss
+		r.count++
+
+		if r.count < r.spec.Period {
+			return Value{Spec: r.spec}
+		}
+
+		r.avgGain /= period
+		r.avgLoss /= period
+		r.smoothing = true
+	}
+
+	var value float64
+
+	switch {
+	case r.avgLoss == 0 && r.avgGain == 0:
+		value = 50
+	case r.avgLoss == 0:
+		value = 100
+	default:
+		value = 100 - 100/(1+r.avgGain/r.avgLoss)
+	}
+
+	return Value{Spec: r.spec, Ready: true, Value: value}
+}
+
+// macd is the fast EMA minus the slow one, with the signal line an EMA of it and the histogram their difference.
+type macd struct {
+	spec   Spec
+	fast   *ema
+	slow   *ema
+	signal *ema
+}
+
+func (m *macd) Update(close float64) Value {
+	fast, slow := m.fast.Update(close), m.slow.Update(close)
+	if !slow.Ready {
+		return Value{Spec: m.spec}
+	}
+
+	line := fast.Value - slow.Value
+
+	signal := m.signal.Update(line)
+	if !signal.Ready {
+		return Value{Spec: m.spec}
+	}
+
+	return Value{Spec: m.spec, Ready: true, Value: line, Signal: signal.Value, Histogram: line - signal.Value}
+}
+
+// bollinger is the SMA of the last Period closes with bands StdDev population standard deviations apart.
+type bollinger struct {
+	spec   Spec
+	window window
+}
+
+func (b *bollinger) Update(close float64) Value {
+	b.window.add(close)
+
+	if !b.window.full() {
+		return Value{Spec: b.spec}
+	}
+
+	mean := b.window.mean()
+
+	var variance float64
+	for _, c := range b.window.closes {
+		variance += (c - mean) * (c - mean)
+	}
+
+	width := b.spec.StdDev * math.Sqrt(variance/float64(b.window.size))
+
+	return Value{Spec: b.spec, Ready: true, Value: mean, Upper: mean + width, Lower: mean - width}
+}
@@ -0,0 +1,165 @@
+package indicators_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/indicators"
+)
+
+// update feeds closes to the indicator of spec, returning its values.
+func update(t *testing.T, spec indicators.Spec, closes ...float64) []indicators.Value {
+	t.Helper()
+
+	spec, err := spec.Normalize()
+	if err != nil {
+		t.Fatalf("invalid spec %+v: %v", spec, err)
+	}
+
+	indicator := indicators.New(spec)
+	values := make([]indicators.Value, 0, len(closes))
+
+	for _, c := range closes {
+		values = append(values, indicator.Update(c))
+	}
+
+	return values
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// assertValues checks the values of an indicator, want holding nil for the ones not ready.
+func assertValues(t *testing.T, got []indicators.Value, want []*indicators.Value) {
+	t.Helper()
+
+	for i, w := range want {
+		g := got[i]
+
+		if w == nil {
+			if g.Ready {
+				t.Errorf("value %d: expected not ready, got %+v", i, g)
+			}
+
+			continue
+		}
+
+		if !g.Ready || !approx(g.Value, w.Value) || !approx(g.Signal, w.Signal) ||
+			!approx(g.Histogram, w.Histogram) || !approx(g.Upper, w.Upper) || !approx(g.Lower, w.Lower) {
+			t.Errorf("value %d: expected %+v, got %+v", i, *w, g)
+		}
+	}
+}
+
+func TestSMA(t *testing.T) {
+	t.Parallel()
+
+	got := update(t, indicators.Spec{Type: indicators.SMA, Period: 3}, 1, 2, 3, 4, 5)
+
+	assertValues(t, got, []*indicators.Value{nil, nil, {Value: 2}, {Value: 3}, {Value: 4}})
+}
+
+func TestEMA(t *testing.T) {
+	t.Parallel()
+
+	// Seeded with the SMA of the first 3 closes, then weighed by 2/(3+1).
+	got := update(t, indicators.Spec{Type: indicators.EMA, Period: 3}, 1, 2, 3, 4, 5, 10)
+
+	assertValues(t, got, []*indicators.Value{nil, nil, {Value: 2}, {Value: 3}, {Value: 4}, {Value: 7}})
+}
+
+func TestRSI(t *testing.T) {
+	t.Parallel()
+
+	got := update(t, indicators.Spec{Type: indicators.RSI, Period: 3}, 10, 11, 12, 11, 13, 12)
+
+	// Average gain and loss are 2/3 and 1/3 after the first 3 changes, then smoothed: 10/9 and 2/9 after +2,
+	// 20/27 and 13/27 after -1.
+	assertValues(t, got, []*indicators.Value{
+		nil, nil, nil,
+		{Value: 100 - 100/(1+2.0)},
+		{Value: 100 - 100/(1+5.0)},
+		{Value: 100 - 100/(1+20.0/13)},
+	})
+}
+
+func TestRSI_Flat(t *testing.T) {
+	t.Parallel()
+
+	got := update(t, indicators.Spec{Type: indicators.RSI, Period: 2}, 5, 5, 5, 6)
+
+	assertValues(t, got, []*indicators.Value{nil, nil, {Value: 50}, {Value: 100}})
+}
+
+func TestMACD(t *testing.T) {
+	t.Parallel()
+
+	got := update(t, indicators.Spec{Type: indicators.MACD, FastPeriod: 2, SlowPeriod: 3, SignalPeriod: 2},
+		1, 2, 3, 4, 6)
+
+	// The fast EMA is 1.5, 2.5, 3.5 and 31/6 from the second close, the slow one 2, 3 and 4.5 from the third, so
+	// the MACD line is 0.5, 0.5 and 2/3. The signal is seeded with the mean of the first 2, then moves 2/3 of
+	// the way to the line.
+	signal := 0.5 + 2.0/3*(2.0/3-0.5)
+
+	assertValues(t, got, []*indicators.Value{
+		nil, nil, nil,
+		{Value: 0.5, Signal: 0.5},
+		{Value: 2.0 / 3, Signal: signal, Histogram: 2.0/3 - signal},
+	})
+}
+
+func TestBollinger(t *testing.T) {
+	t.Parallel()
+
+	got := update(t, indicators.Spec{Type: indicators.Bollinger, Period: 3}, 1, 2, 3, 6)
+
+	// Bands 2 population standard deviations from the mean: sqrt(2/3) for 1, 2, 3 and sqrt(26/9) for 2, 3, 6.
+	first, second := 2*math.Sqrt(2.0/3), 2*math.Sqrt(26.0/9)
+
+	assertValues(t, got, []*indicators.Value{
+		nil, nil,
+		{Value: 2, Upper: 2 + first, Lower: 2 - first},
+		{Value: 11.0 / 3, Upper: 11.0/3 + second, Lower: 11.0/3 - second},
+	})
+}
+
+func TestSpec_Normalize(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		spec indicators.Spec
+		want indicators.Spec
+	}{
+		{indicators.Spec{Type: indicators.SMA, StdDev: 3}, indicators.Spec{Type: indicators.SMA, Period: 20}},
+		{indicators.Spec{Type: indicators.RSI}, indicators.Spec{Type: indicators.RSI, Period: 14}},
+		{indicators.Spec{Type: indicators.MACD, Period: 5}, indicators.Spec{Type: indicators.MACD, FastPeriod: 12,
+			SlowPeriod: 26, SignalPeriod: 9}},
+		{indicators.Spec{Type: indicators.Bollinger, Period: 10}, indicators.Spec{Type: indicators.Bollinger,
+			Period: 10, StdDev: 2}},
+	} {
+		got, err := tc.spec.Normalize()
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", tc.spec, err)
+
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("%+v: expected %+v, got %+v", tc.spec, tc.want, got)
+		}
+	}
+
+	for _, spec := range []indicators.Spec{
+		{Type: "vwap"},
+		{Type: indicators.EMA, Period: -1},
+		{Type: indicators.SMA, Period: indicators.MaxPeriod + 1},
+		{Type: indicators.MACD, FastPeriod: 26, SlowPeriod: 12},
+		{Type: indicators.Bollinger, StdDev: -1},
+	} {
+		if _, err := spec.Normalize(); err == nil {
+			t.Errorf("%+v: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the correlation fields, so the same field has the same name in every component.
+const (
+	KeyComponent = "component"
+	KeySymbol    = "symbol"
+	KeyInterval  = "interval"
+	KeyStreamID  = "stream_id"
+	KeyPeer      = "peer"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+	KeyError     = "error"
+)
+
+// Formats of Config.Format.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config selects the format, the default level and the level of single components.
+type Config struct {
+	// Format is FormatJSON or FormatText, JSON when empty.
+	Format string
+	// Level is the default level: debug, info, warn or error.
+	Level string
+	// ComponentLevels overrides Level per component as "component=level" pairs, e.g. "binance=debug".
+	ComponentLevels []string
+	// SampleInitial records of a message are logged per second, then every SampleThereafter-th one.
+	// Only records at info level and below are sampled, zero disables sampling.
+	SampleInitial    int
+	SampleThereafter int
+}
+
+type state struct {
+	handler         slog.Handler
+	level           slog.Level
+	componentLevels map[string]slog.Level
+	sampler         *sampler
+}
+
+var current atomic.Pointer[state]
+
+//nolint:gochecknoinits // loggers taken before Setup must log to stderr rather than nowhere.
+func init() {
+	current.Store(&state{
+		handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		level:   slog.LevelInfo,
+	})
+}
+
+// Setup configures every logger of Component and the default slog logger, writing to stderr.
+func Setup(cfg Config) error {
+	return SetupWriter(os.Stderr, cfg)
+}
+
+// SetupWriter is Setup writing to w.
+func SetupWriter(w io.Writer, cfg Config) error {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+
+	componentLevels := make(map[string]slog.Level, len(cfg.ComponentLevels))
+
+	for _, pair := range cfg.ComponentLevels {
+		component, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid component level %q, expected component=level", pair)
+		}
+
+		if componentLevels[component], err = parseLevel(value); err != nil {
+			return err
+		}
+	}
+
+	// Levels are filtered per component by handler, the underlying handler logs everything it is given.
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	var h slog.Handler
+
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	current.Store(&state{
+		handler:         h,
+		level:           level,
+		componentLevels: componentLevels,
+		sampler:         newSampler(cfg.SampleInitial, cfg.SampleThereafter),
+	})
+
+	slog.SetDefault(slog.New(&handler{}))
+
+	return nil
+}
+
+// Component returns the logger of a component, tagged with its name and filtered at its level. It may be taken
+// before Setup, e.g. in a package variable, and follows the configuration set up later.
+func Component(name string) *slog.Logger {
+	return slog.New(&handler{component: name})
+}
+
+// Err returns err as the error attribute.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+func parseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", value, err)
+	}
+
+	return level, nil
+}
+
+// handler resolves the current configuration for every record, so loggers follow Setup.
+type handler struct {
+	component string
+	// ops are the WithAttrs and WithGroup calls to replay on the current handler.
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level(current.Load())
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	s := current.Load()
+
+	if record.Level <= slog.LevelInfo && !s.sampler.allow(record.Level, h.component, record.Message, record.Time) {
+		return nil
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String(KeyTraceID, spanContext.TraceID().String()),
+			slog.String(KeySpanID, spanContext.SpanID().String()),
+		)
+	}
+
+	next := s.handler
+	if h.component != "" {
+		next = next.WithAttrs([]slog.Attr{slog.String(KeyComponent, h.component)})
+	}
+
+	for _, op := range h.ops {
+		next = op(next)
+	}
+
+	return next.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	return &handler{
+		component: h.component,
+		ops:       append(h.ops[:len(h.ops):len(h.ops)], op),
+	}
+}
+
+func (h *handler) level(s *state) slog.Level {
+	if level, ok := s.componentLevels[h.component]; ok {
+		return level
+	}
+
+	return s.level
+}
+
+// Fatal logs msg at error level and exits, the counterpart of log.Fatal.
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,127 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setup(t *testing.T, cfg logging.Config) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	if err := logging.SetupWriter(&buf, cfg); err != nil {
+		t.Fatalf("SetupWriter failed: %v", err)
+	}
+
+	t.Cleanup(func() { _ = logging.SetupWriter(&bytes.Buffer{}, logging.Config{}) })
+
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var out []map[string]any
+
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON record %q: %v", line, err)
+		}
+
+		out = append(out, record)
+	}
+
+	return out
+}
+
+func TestComponent_Levels(t *testing.T) {
+	// Taken before Setup, as package variables are.
+	binance, grpc := logging.Component("binance"), logging.Component("grpc")
+
+	buf := setup(t, logging.Config{Level: "warn", ComponentLevels: []string{"binance=debug"}})
+
+	binance.Debug("trade received", logging.KeySymbol, "BTCUSDT")
+	grpc.Info("client connected")
+	grpc.Warn("stream rejected")
+
+	got := records(t, buf)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d: %v", len(got), got)
+	}
+
+	if got[0]["component"] != "binance" || got[0]["symbol"] != "BTCUSDT" || got[0]["level"] != "DEBUG" {
+		t.Errorf("unexpected binance record %v", got[0])
+	}
+
+	if got[1]["component"] != "grpc" || got[1]["msg"] != "stream rejected" {
+		t.Errorf("unexpected grpc record %v", got[1])
+	}
+}
+
+func TestComponent_TraceID(t *testing.T) {
+	buf := setup(t, logging.Config{})
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	logging.Component("persistor").With(logging.KeyStreamID, 7).InfoContext(ctx, "candle saved")
+
+	got := records(t, buf)
+	if len(got) != 1 || got[0]["trace_id"] != spanContext.TraceID().String() || got[0]["stream_id"] != float64(7) {
+		t.Errorf("expected the trace and stream IDs in the record, got %v", got)
+	}
+}
+
+func TestComponent_Sampling(t *testing.T) {
+	buf := setup(t, logging.Config{Level: "debug", SampleInitial: 2, SampleThereafter: 5})
+	logger := logging.Component("aggregator")
+
+	for range 12 {
+		logger.Debug("candle updated")
+		logger.Error("aggregation failed")
+	}
+
+	var debug, errors int
+
+	for _, record := range records(t, buf) {
+		switch record["level"] {
+		case "DEBUG":
+			debug++
+		case "ERROR":
+			errors++
+		}
+	}
+
+	// The first 2, then the 7th and 12th.
+	if debug != 4 {
+		t.Errorf("expected 4 sampled debug records, got %d", debug)
+	}
+
+	if errors != 12 {
+		t.Errorf("expected every error record, got %d", errors)
+	}
+}
+
+func TestSetup_Invalid(t *testing.T) {
+	for _, cfg := range []logging.Config{
+		{Level: "verbose"},
+		{Format: "xml"},
+		{ComponentLevels: []string{"binance"}},
+	} {
+		if err := logging.SetupWriter(&bytes.Buffer{}, cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sampleInterval is the period SampleInitial and SampleThereafter count records in.
+const sampleInterval = time.Second
+
+type sampleKey struct {
+	level     slog.Level
+	component string
+	message   string
+}
+
+// sampler lets through the first initial records of a message per interval and every thereafter-th one after,
+// so a message logged per trade costs a bounded amount of output.
+type sampler struct {
+	initial    int
+	thereafter int
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[sampleKey]int
+}
+
+func newSampler(initial, thereafter int) *sampler {
+	if initial <= 0 {
+		return nil
+	}
+
+	return &sampler{
+		initial:    initial,
+		thereafter: thereafter,
+		counts:     make(map[sampleKey]int),
+	}
+}
+
+func (s *sampler) allow(level slog.Level, component, message string, at time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if window := at.Truncate(sampleInterval); !window.Equal(s.window) {
+		s.window = window
+		clear(s.counts)
+	}
+
+	key := sampleKey{level: level, component: component, message: message}
+	s.counts[key]++
+	n := s.counts[key]
+
+	if n <= s.initial {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
@@ -0,0 +1,274 @@
+// Package synthetic derives the trades of synthetic symbols, crosses and weighted indices, from the last prices of
+// the symbols they are made of, so they are aggregated like the symbols traded on Binance.
+package synthetic
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+)
+
+// Type is how a synthetic symbol's price is derived from its components.
+type Type string
+
+// Types of Spec.Type.
+const (
+	// Cross divides the price of the first component by the one of the second, e.g. ETHBTC from ETHUSDT and
+	// BTCUSDT.
+	Cross Type = "cross"
+	// Index sums the prices of the components times their weights.
+	Index Type = "index"
+)
+
+// DefaultStaleAfter is how old the last trade of a component may be unless WithStaleAfter says otherwise.
+const DefaultStaleAfter = 30 * time.Second
+
+// Component is a symbol a synthetic symbol is derived from, and its weight in an index.
+type Component struct {
+	Symbol string
+	Weight float64
+}
+
+// Spec configures a synthetic symbol.
+type Spec struct {
+	Symbol     string
+	Type       Type
+	Components []Component
+}
+
+// ParseSpec parses a spec written as SYMBOL=BASE/QUOTE for a cross, e.g. ETHBTC=ETHUSDT/BTCUSDT, or as
+// SYMBOL=WEIGHT*COMPONENT+... for an index, e.g. DEFI=0.5*UNIUSDT+0.5*AAVEUSDT, a missing weight being 1.
+func ParseSpec(s string) (Spec, error) {
+	symbol, expr, ok := strings.Cut(s, "=")
+	if !ok || symbol == "" || expr == "" {
+		return Spec{}, fmt.Errorf("synthetic %q is not SYMBOL=BASE/QUOTE or SYMBOL=WEIGHT*SYMBOL+...", s)
+	}
+
+	spec := Spec{Symbol: strings.ToUpper(symbol), Type: Index}
+	if !validSymbol(spec.Symbol) {
+		return Spec{}, fmt.Errorf("synthetic %q has an invalid symbol %q", s, symbol)
+	}
+
+	if base, quote, ok := strings.Cut(expr, "/"); ok {
+		spec.Type = Cross
+		spec.Components = []Component{
+			{Symbol: strings.ToUpper(base), Weight: 1},
+			{Symbol: strings.ToUpper(quote), Weight: 1},
+		}
+	} else {
+		for _, term := range strings.Split(expr, "+") {
+			component := Component{Symbol: strings.ToUpper(term), Weight: 1}
+
+			if weight, name, ok := strings.Cut(term, "*"); ok {
+				w, err := strconv.ParseFloat(weight, 64)
+				if err != nil || w <= 0 || math.IsInf(w, 0) {
+					return Spec{}, fmt.Errorf("synthetic %q has weight %q, expected a positive number", s, weight)
+				}
+
+				component = Component{Symbol: strings.ToUpper(name), Weight: w}
+			}
+
+			spec.Components = append(spec.Components, component)
+		}
+	}
+
+	for i, component := range spec.Components {
+		if !validSymbol(component.Symbol) {
+			return Spec{}, fmt.Errorf("synthetic %q has an invalid component %q", s, component.Symbol)
+		}
+
+		if component.Symbol == spec.Symbol {
+			return Spec{}, fmt.Errorf("synthetic %q is made of itself", s)
+		}
+
+		if slices.ContainsFunc(spec.Components[:i], func(c Component) bool { return c.Symbol == component.Symbol }) {
+			return Spec{}, fmt.Errorf("synthetic %q has component %s twice", s, component.Symbol)
+		}
+	}
+
+	return spec, nil
+}
+
+func (s Spec) String() string {
+	if s.Type == Cross {
+		return fmt.Sprintf("%s=%s/%s", s.Symbol, s.Components[0].Symbol, s.Components[1].Symbol)
+	}
+
+	terms := make([]string, len(s.Components))
+	for i, component := range s.Components {
+		terms[i] = strconv.FormatFloat(component.Weight, 'f', -1, 64) + "*" + component.Symbol
+	}
+
+	return s.Symbol + "=" + strings.Join(terms, "+")
+}
+
+// price returns the price of the synthetic symbol from the prices of its components, in order.
+func (s Spec) price(prices []float64) float64 {
+	if s.Type == Cross {
+		return prices[0] / prices[1]
+	}
+
+	var sum float64
+	for i, component := range s.Components {
+		sum += component.Weight * prices[i]
+	}
+
+	return sum
+}
+
+func validSymbol(symbol string) bool {
+	if symbol == "" {
+		return false
+	}
+
+	for _, r := range symbol {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Engine derives the trades of synthetic symbols from the trades of their components. A synthetic symbol trades
+// whenever one of its components does, at the price derived from the last price of every component, unless one
+// of them didn't trade yet or last traded longer than the stale period before: its candles then have a gap
+// rather than a price made of outdated ones. It is not safe for concurrent use.
+type Engine struct {
+	specs []Spec
+	// bySymbol indexes the specs by their components.
+	bySymbol map[string][]int
+	last     map[string]quote
+	// stale is whether each spec isn't priced, true until all its components traded.
+	stale      []bool
+	staleAfter time.Duration
+	onStale    func(symbol string, stale []string)
+}
+
+// quote is the last trade of a component.
+type quote struct {
+	price float64
+	at    time.Time
+}
+
+type options struct {
+	staleAfter time.Duration
+	onStale    func(symbol string, stale []string)
+}
+
+type Option func(o *options)
+
+// WithStaleAfter stops pricing a synthetic symbol while the last trade of one of its components is older than d.
+func WithStaleAfter(d time.Duration) Option {
+	return func(o *options) {
+		o.staleAfter = d
+	}
+}
+
+// WithStaleness calls onStale with the stale components of a synthetic symbol when it stops being priced, and
+// with none when it is priced, first or again. Synthetic symbols aren't priced until all their components traded.
+func WithStaleness(onStale func(symbol string, stale []string)) Option {
+	return func(o *options) {
+		o.onStale = onStale
+	}
+}
+
+func NewEngine(specs []Spec, opts ...Option) *Engine {
+	opt := options{staleAfter: DefaultStaleAfter, onStale: func(string, []string) {}}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	e := &Engine{
+		specs:      specs,
+		bySymbol:   make(map[string][]int),
+		last:       make(map[string]quote),
+		stale:      make([]bool, len(specs)),
+		staleAfter: opt.staleAfter,
+		onStale:    opt.onStale,
+	}
+
+	for i, spec := range specs {
+		e.stale[i] = true
+
+		for _, component := range spec.Components {
+			e.bySymbol[component.Symbol] = append(e.bySymbol[component.Symbol], i)
+		}
+	}
+
+	return e
+}
+
+// Symbols returns the synthetic symbols.
+func (e *Engine) Symbols() []string {
+	symbols := make([]string, len(e.specs))
+	for i, spec := range e.specs {
+		symbols[i] = spec.Symbol
+	}
+
+	return symbols
+}
+
+// Trade records the price of a component's trade, returning the trades of the synthetic symbols it makes up.
+// They have the time and span of tick and no quantity, as nothing was traded, so their candles have no volume.
+func (e *Engine) Trade(tick binance.TradeData) []binance.TradeData {
+	indices := e.bySymbol[tick.Symbol]
+	if len(indices) == 0 {
+		return nil
+	}
+
+	price, err := strconv.ParseFloat(tick.Price, 64)
+	if err != nil || price <= 0 {
+		return nil
+	}
+
+	at := time.UnixMilli(tick.TradeTime)
+	if last, ok := e.last[tick.Symbol]; !ok || !at.Before(last.at) {
+		e.last[tick.Symbol] = quote{price: price, at: at}
+	}
+
+	var trades []binance.TradeData
+
+	for _, i := range indices {
+		spec := e.specs[i]
+		prices := make([]float64, len(spec.Components))
+
+		var stale []string
+
+		for j, component := range spec.Components {
+			last, ok := e.last[component.Symbol]
+			if !ok || at.Sub(last.at) > e.staleAfter {
+				stale = append(stale, component.Symbol)
+			}
+
+			prices[j] = last.price
+		}
+
+		if (len(stale) > 0) != e.stale[i] {
+			e.stale[i] = len(stale) > 0
+			e.onStale(spec.Symbol, stale)
+		}
+
+		if len(stale) > 0 {
+			continue
+		}
+
+		trades = append(trades, binance.TradeData{
+			EventType:   tick.EventType,
+			EventTime:   tick.EventTime,
+			Symbol:      spec.Symbol,
+			Price:       strconv.FormatFloat(spec.price(prices), 'f', -1, 64),
+			Quantity:    "0",
+			TradeTime:   tick.TradeTime,
+			SpanContext: tick.SpanContext,
+		})
+	}
+
+	return trades
+}
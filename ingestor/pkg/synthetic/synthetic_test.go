@@ -0,0 +1,119 @@
+package synthetic_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/synthetic"
+)
+
+var start = time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+func trade(symbol string, price float64, after time.Duration) binance.TradeData {
+	return binance.TradeData{
+		Symbol:    symbol,
+		Price:     strconv.FormatFloat(price, 'f', -1, 64),
+		Quantity:  "1",
+		TradeTime: start.Add(after).UnixMilli(),
+	}
+}
+
+func mustParse(t *testing.T, s string) synthetic.Spec {
+	t.Helper()
+
+	spec, err := synthetic.ParseSpec(s)
+	if err != nil {
+		t.Fatalf("ParseSpec(%q) failed: %v", s, err)
+	}
+
+	return spec
+}
+
+func TestParseSpec(t *testing.T) {
+	t.Parallel()
+
+	for s, want := range map[string]synthetic.Spec{
+		"ethbtc=ethusdt/btcusdt": {Symbol: "ETHBTC", Type: synthetic.Cross,
+			Components: []synthetic.Component{{Symbol: "ETHUSDT", Weight: 1}, {Symbol: "BTCUSDT", Weight: 1}}},
+		"MAJORS=0.6*BTCUSDT+ETHUSDT": {Symbol: "MAJORS", Type: synthetic.Index,
+			Components: []synthetic.Component{{Symbol: "BTCUSDT", Weight: 0.6}, {Symbol: "ETHUSDT", Weight: 1}}},
+	} {
+		spec := mustParse(t, s)
+		if !reflect.DeepEqual(spec, want) {
+			t.Errorf("ParseSpec(%q) = %+v, expected %+v", s, spec, want)
+		}
+
+		if again := mustParse(t, spec.String()); !reflect.DeepEqual(again, want) {
+			t.Errorf("ParseSpec(%q) = %+v, expected %+v", spec.String(), again, want)
+		}
+	}
+
+	for _, s := range []string{
+		"ETHBTC", "=ETHUSDT/BTCUSDT", "ETHBTC=", "ETH-BTC=ETHUSDT/BTCUSDT", "ETHBTC=ETHUSDT/", "X=A/B/C",
+		"X=0*BTCUSDT", "X=-1*BTCUSDT", "X=abc*BTCUSDT", "X=BTCUSDT+", "X=BTCUSDT+BTCUSDT", "X=X+BTCUSDT",
+	} {
+		if _, err := synthetic.ParseSpec(s); err == nil {
+			t.Errorf("ParseSpec(%q): expected an error", s)
+		}
+	}
+}
+
+func TestEngine_Trade(t *testing.T) {
+	t.Parallel()
+
+	var changes []string
+
+	engine := synthetic.NewEngine([]synthetic.Spec{
+		mustParse(t, "ETHBTC=ETHUSDT/BTCUSDT"),
+		mustParse(t, "MAJORS=0.5*BTCUSDT+2*ETHUSDT"),
+	}, synthetic.WithStaleAfter(10*time.Second), synthetic.WithStaleness(func(symbol string, stale []string) {
+		changes = append(changes, symbol+":"+strconv.Itoa(len(stale)))
+	}))
+
+	if symbols := engine.Symbols(); !reflect.DeepEqual(symbols, []string{"ETHBTC", "MAJORS"}) {
+		t.Errorf("unexpected symbols %v", symbols)
+	}
+
+	prices := func(tick binance.TradeData) map[string]string {
+		out := make(map[string]string)
+
+		for _, derived := range engine.Trade(tick) {
+			if derived.TradeTime != tick.TradeTime || derived.Quantity != "0" {
+				t.Errorf("unexpected trade %+v for %+v", derived, tick)
+			}
+
+			out[derived.Symbol] = derived.Price
+		}
+
+		return out
+	}
+
+	// Nothing is priced before every component traded.
+	if got := prices(trade("ETHUSDT", 3000, 0)); len(got) != 0 {
+		t.Errorf("expected no trades before BTCUSDT traded, got %v", got)
+	}
+
+	for _, tc := range []struct {
+		tick binance.TradeData
+		want map[string]string
+	}{
+		{trade("BTCUSDT", 100000, time.Second), map[string]string{"ETHBTC": "0.03", "MAJORS": "56000"}},
+		{trade("ETHUSDT", 4000, 2*time.Second), map[string]string{"ETHBTC": "0.04", "MAJORS": "58000"}},
+		{trade("DOGEUSDT", 1, 3*time.Second), map[string]string{}},
+		// BTCUSDT last traded 12s before, so neither is priced until it trades again.
+		{trade("ETHUSDT", 5000, 13*time.Second), map[string]string{}},
+		{trade("BTCUSDT", 125000, 14*time.Second), map[string]string{"ETHBTC": "0.04", "MAJORS": "72500"}},
+	} {
+		if got := prices(tc.tick); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s at %s: expected %v, got %v", tc.tick.Symbol, tc.tick.Price, tc.want, got)
+		}
+	}
+
+	want := []string{"ETHBTC:0", "MAJORS:0", "ETHBTC:1", "MAJORS:1", "ETHBTC:0", "MAJORS:0"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected staleness changes %v, got %v", want, changes)
+	}
+}
@@ -0,0 +1,229 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+var logger = logging.Component("tls")
+
+// Files locates the PEM files of a TLS identity. CAFile verifies the peer: client certificates on a server,
+// which enables mTLS, or the server certificate on a client, in place of the system roots.
+type Files struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Reloader keeps the certificates of Files loaded and reloads them when the files change, so rotated
+// certificates are used by new connections without a restart.
+type Reloader struct {
+	files Files
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+func NewReloader(files Files) (*Reloader, error) {
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, errors.New("a certificate and its key must be configured together")
+	}
+
+	r := &Reloader{files: files}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the files again when any of them changed since the last load and reports whether it did.
+// The previous certificates are kept when the new ones are invalid.
+func (r *Reloader) Reload() (bool, error) {
+	modTimes, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	changed := r.modTimes == nil || !equalModTimes(r.modTimes, modTimes)
+	r.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+
+	if r.files.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to load certificate: %w", err)
+		}
+
+		cert = &pair
+	}
+
+	var caPool *x509.CertPool
+
+	if r.files.CAFile != "" {
+		pem, err := os.ReadFile(r.files.CAFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("no certificates found in CA file %s", r.files.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.caPool, r.modTimes = cert, caPool, modTimes
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch reloads the files every interval until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				logger.Error("failed to reload TLS certificates, keeping the current ones", logging.Err(err))
+			} else if reloaded {
+				logger.Info("reloaded TLS certificates")
+			}
+		}
+	}
+}
+
+// ServerConfig returns a server TLS config using the current certificate, which requires and verifies client
+// certificates against CAFile when it is set.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			if r.cert == nil {
+				return nil, errors.New("no server certificate configured")
+			}
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+			}
+
+			if r.caPool != nil {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = r.caPool
+			}
+
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns a client TLS config presenting the current certificate, when there is one, and
+// verifying the server against CAFile, or the system roots when it is not set.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			if r.cert == nil {
+				return &tls.Certificate{}, nil
+			}
+
+			return r.cert, nil
+		},
+		// The roots may be rotated, so the chain is verified against the current pool in VerifyConnection
+		// instead of a RootCAs fixed here.
+		InsecureSkipVerify: true, //nolint:gosec // verified in VerifyConnection.
+		VerifyConnection: func(state tls.ConnectionState) error {
+			r.mu.RLock()
+			roots := r.caPool
+			r.mu.RUnlock()
+
+			return verifyServer(state, roots)
+		},
+	}
+}
+
+func verifyServer(state tls.ConnectionState, roots *x509.CertPool) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       state.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify server certificate: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+
+	for _, file := range []string{r.files.CertFile, r.files.KeyFile, r.files.CAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", file, err)
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func equalModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for file, modTime := range a {
+		if !modTime.Equal(b[file]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,251 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tlsconfig"
+)
+
+var modTime = time.Now()
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T, name string) authority {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	certPEM, key := sign(t, template, nil)
+	block, _ := pem.Decode(certPEM)
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	return authority{cert: cert, key: key, pem: certPEM}
+}
+
+// issue writes a certificate signed by ca into dir and returns its files.
+func (ca authority) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) tlsconfig.Files {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	certPEM, key := sign(t, template, &ca)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	files := tlsconfig.Files{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+
+	writeFile(t, files.CertFile, certPEM)
+	writeFile(t, files.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	return files
+}
+
+func sign(t *testing.T, template *x509.Certificate, ca *authority) ([]byte, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	parent, parentKey := template, key
+	if ca != nil {
+		parent, parentKey = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+
+	// Make sure a rewrite within the file system's timestamp resolution is still seen as a change.
+	modTime = modTime.Add(time.Second)
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatalf("failed to touch %s: %v", name, err)
+	}
+}
+
+// handshake serves one TLS connection with server and dials it with client.
+func handshake(t *testing.T, server, client *tls.Config) error {
+	t.Helper()
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_ = conn.(*tls.Conn).Handshake()
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", lis.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// TLS 1.3 servers reject client certificates after the client finished its handshake, so wait for it.
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+
+	serverFiles := ca.issue(t, dir, "localhost", x509.ExtKeyUsageServerAuth)
+	serverFiles.CAFile = caFile
+	clientFiles := ca.issue(t, dir, "persistor", x509.ExtKeyUsageClientAuth)
+	clientFiles.CAFile = caFile
+
+	server, err := tlsconfig.NewReloader(serverFiles)
+	if err != nil {
+		t.Fatalf("NewReloader failed for server: %v", err)
+	}
+
+	client, err := tlsconfig.NewReloader(clientFiles)
+	if err != nil {
+		t.Fatalf("NewReloader failed for client: %v", err)
+	}
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("localhost")); err != nil {
+		t.Errorf("mTLS handshake failed: %v", err)
+	}
+
+	anonymous, err := tlsconfig.NewReloader(tlsconfig.Files{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewReloader failed for anonymous client: %v", err)
+	}
+
+	if err := handshake(t, server.ServerConfig(), anonymous.ClientConfig("localhost")); err == nil {
+		t.Error("expected a client without certificate to be rejected")
+	}
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("ingestor")); err == nil {
+		t.Error("expected a server name mismatch to be rejected")
+	}
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, "ca")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+
+	server, err := tlsconfig.NewReloader(ca.issue(t, dir, "localhost", x509.ExtKeyUsageServerAuth))
+	if err != nil {
+		t.Fatalf("NewReloader failed for server: %v", err)
+	}
+
+	client, err := tlsconfig.NewReloader(tlsconfig.Files{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewReloader failed for client: %v", err)
+	}
+
+	if reloaded, err := server.Reload(); err != nil || reloaded {
+		t.Errorf("expected unchanged files not to be reloaded, got %v and error %v", reloaded, err)
+	}
+
+	// Rotate the server certificate to one of another authority the client doesn't trust yet.
+	rotated := newAuthority(t, "rotated-ca")
+	rotated.issue(t, dir, "localhost", x509.ExtKeyUsageServerAuth)
+
+	if reloaded, err := server.Reload(); err != nil || !reloaded {
+		t.Fatalf("expected the rotated certificate to be reloaded, got %v and error %v", reloaded, err)
+	}
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("localhost")); err == nil {
+		t.Fatal("expected the rotated certificate to be untrusted before the client reloads its CA")
+	}
+
+	writeFile(t, caFile, rotated.pem)
+
+	if _, err := client.Reload(); err != nil {
+		t.Fatalf("client Reload failed: %v", err)
+	}
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("localhost")); err != nil {
+		t.Errorf("handshake with the rotated certificate failed: %v", err)
+	}
+
+	writeFile(t, caFile, []byte("not a certificate"))
+
+	if _, err := client.Reload(); err == nil {
+		t.Error("expected an invalid CA file to fail reloading")
+	}
+
+	if err := handshake(t, server.ServerConfig(), client.ClientConfig("localhost")); err != nil {
+		t.Errorf("the previous CA should be kept after a failed reload: %v", err)
+	}
+}
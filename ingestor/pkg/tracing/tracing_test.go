@@ -0,0 +1,41 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	if _, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "jaeger"}); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	defer func() { _ = shutdown(context.Background()) }()
+
+	if carrier := tracing.Inject(context.Background()); carrier != nil {
+		t.Errorf("expected no carrier without a span, got %v", carrier)
+	}
+
+	want := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	carrier := tracing.Inject(trace.ContextWithSpanContext(context.Background(), want))
+	got := trace.SpanContextFromContext(tracing.Extract(context.Background(), carrier))
+
+	if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() || !got.IsSampled() || !got.IsRemote() {
+		t.Errorf("expected the remote span context %v, got %v", want, got)
+	}
+}
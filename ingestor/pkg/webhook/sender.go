@@ -0,0 +1,315 @@
+// Package webhook delivers JSON payloads to webhooks, signed with HMAC-SHA256 and retried with backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+// Headers of a delivery. The signature is the hex HMAC-SHA256 of the timestamp, a dot and the body, so receivers
+// can reject replays of old deliveries.
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Results of a delivery, reported to the WithResults callback.
+const (
+	ResultDelivered = "delivered"
+	ResultFailed    = "failed"
+	ResultDropped   = "dropped"
+)
+
+// Defaults of a Sender.
+const (
+	DefaultRetries   = 5
+	DefaultBackoff   = time.Second
+	DefaultQueueSize = 1000
+	DefaultTimeout   = 10 * time.Second
+)
+
+var (
+	// ErrForbiddenAddress is returned for webhooks resolving to a loopback, private, link-local or otherwise
+	// non-public address outside the networks allowed by WithAllowedNetworks.
+	ErrForbiddenAddress = errors.New("webhook address is not public")
+	// sharedAddressSpace is the carrier-grade NAT range, not routed on the internet but not private either.
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+const (
+	// workers is how many deliveries are sent at once.
+	workers           = 4
+	maxBackoff        = time.Minute
+	maxResponseLength = 1024
+)
+
+var logger = logging.Component("webhook")
+
+// Delivery is a payload to POST to URL, signed with Secret unless it is empty. ID is sent in HeaderID, the same
+// for every attempt.
+type Delivery struct {
+	URL     string
+	ID      string
+	Secret  []byte
+	Payload any
+}
+
+// Sender delivers queued payloads in the background, retrying the ones that failed with a network error, a 429
+// or a 5xx response. It only connects to public addresses, checked once resolved so a hostname can't be pointed at
+// an internal service, and to the networks allowed by WithAllowedNetworks.
+type Sender struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+	queue   chan Delivery
+	results func(result string)
+}
+
+type options struct {
+	timeout   time.Duration
+	allowed   []netip.Prefix
+	retries   int
+	backoff   time.Duration
+	queueSize int
+	results   func(result string)
+}
+
+type Option func(o *options)
+
+// WithTimeout bounds each attempt, DefaultTimeout by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithAllowedNetworks also lets webhooks resolve to addresses of networks, e.g. a receiver in the cluster.
+func WithAllowedNetworks(networks ...netip.Prefix) Option {
+	return func(o *options) {
+		o.allowed = append(o.allowed, networks...)
+	}
+}
+
+// WithRetries retries a failed delivery up to retries times, waiting backoff, doubled after every attempt.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries, o.backoff = retries, backoff
+	}
+}
+
+// WithQueueSize bounds the deliveries waiting to be sent, later ones are dropped.
+func WithQueueSize(size int) Option {
+	return func(o *options) {
+		o.queueSize = size
+	}
+}
+
+// WithResults calls results with the result of every delivery, e.g. to count them.
+func WithResults(results func(result string)) Option {
+	return func(o *options) {
+		o.results = results
+	}
+}
+
+func NewSender(opts ...Option) *Sender {
+	opt := options{
+		timeout:   DefaultTimeout,
+		retries:   DefaultRetries,
+		backoff:   DefaultBackoff,
+		queueSize: DefaultQueueSize,
+		results:   func(string) {},
+	}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	dialer := &net.Dialer{Timeout: opt.timeout, Control: func(_, address string, _ syscall.RawConn) error {
+		return checkAddress(address, opt.allowed)
+	}}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout: opt.timeout,
+			// Without a proxy, which would connect to the addresses on its behalf.
+			Transport: &http.Transport{DialContext: dialer.DialContext, ForceAttemptHTTP2: true},
+		},
+		retries: opt.retries,
+		backoff: opt.backoff,
+		queue:   make(chan Delivery, opt.queueSize),
+		results: opt.results,
+	}
+}
+
+// Send queues delivery, returning false when the queue is full and it was dropped.
+func (s *Sender) Send(delivery Delivery) bool {
+	select {
+	case s.queue <- delivery:
+		return true
+	default:
+		s.results(ResultDropped)
+		logger.Warn("webhook queue full, dropped delivery", "id", delivery.ID)
+
+		return false
+	}
+}
+
+// Run sends the queued deliveries until ctx is done. Deliveries still queued or being retried then are dropped.
+func (s *Sender) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case delivery := <-s.queue:
+					s.deliver(ctx, delivery)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if pending := len(s.queue); pending > 0 {
+		logger.Warn("dropped pending webhook deliveries", "deliveries", pending)
+	}
+}
+
+// deliver sends delivery, retrying it until it succeeds, fails for good or ctx is done.
+func (s *Sender) deliver(ctx context.Context, delivery Delivery) {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		s.results(ResultFailed)
+		logger.Error("failed to encode webhook payload", "id", delivery.ID, logging.Err(err))
+
+		return
+	}
+
+	backoff := s.backoff
+
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, delivery, body)
+		if err == nil {
+			s.results(ResultDelivered)
+
+			return
+		}
+
+		if !retry || attempt >= s.retries || !sleep(ctx, backoff) {
+			s.results(ResultFailed)
+			logger.Warn("failed to deliver webhook", "id", delivery.ID, "attempts", attempt+1, logging.Err(err))
+
+			return
+		}
+
+		logger.Debug("retrying webhook", "id", delivery.ID, "attempt", attempt+1, "backoff", backoff,
+			logging.Err(err))
+
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// post makes one attempt, returning whether a failure may be retried.
+func (s *Sender) post(ctx context.Context, delivery Delivery, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+
+	if len(delivery.Secret) > 0 {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(delivery.Secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrForbiddenAddress), fmt.Errorf("failed to post webhook: %w", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		return false, nil
+	}
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseLength))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+
+	return retry, fmt.Errorf("webhook responded %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+}
+
+// checkAddress returns ErrForbiddenAddress unless address, the IP and port about to be connected to, is public or
+// in allowed.
+func checkAddress(address string, allowed []netip.Prefix) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	addr := addrPort.Addr().Unmap()
+	if slices.ContainsFunc(allowed, func(network netip.Prefix) bool { return network.Contains(addr) }) {
+		return nil
+	}
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of timestamp, a dot and body with secret, the signature of a delivery.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,186 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/webhook"
+)
+
+// start runs a sender with retries a millisecond apart, allowed to post to the test servers on loopback unless
+// opts are given, returning a channel of its results.
+func start(t *testing.T, opts ...webhook.Option) (*webhook.Sender, chan string) {
+	t.Helper()
+
+	if len(opts) == 0 {
+		opts = []webhook.Option{webhook.WithAllowedNetworks(netip.MustParsePrefix("127.0.0.0/8"))}
+	}
+
+	results := make(chan string, 10)
+	opts = append([]webhook.Option{
+		webhook.WithRetries(2, time.Millisecond),
+		webhook.WithResults(func(result string) { results <- result }),
+	}, opts...)
+
+	sender := webhook.NewSender(opts...)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		sender.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	return sender, results
+}
+
+func result(t *testing.T, results chan string) string {
+	t.Helper()
+
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery result")
+
+		return ""
+	}
+}
+
+func TestSender_Signed(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	received := make(chan map[string]any, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		timestamp := r.Header.Get(webhook.HeaderTimestamp)
+		if r.Header.Get(webhook.HeaderSignature) != "sha256="+webhook.Sign(secret, timestamp, body) ||
+			r.Header.Get(webhook.HeaderID) != "alert-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		var payload map[string]any
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	t.Cleanup(server.Close)
+
+	sender, results := start(t)
+
+	if !sender.Send(webhook.Delivery{URL: server.URL, ID: "alert-1", Secret: secret,
+		Payload: map[string]any{"symbol": "BTCUSDT"}}) {
+		t.Fatal("expected the delivery to be queued")
+	}
+
+	if r := result(t, results); r != webhook.ResultDelivered {
+		t.Fatalf("expected %q, got %q", webhook.ResultDelivered, r)
+	}
+
+	if payload := <-received; payload["symbol"] != "BTCUSDT" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+}
+
+func TestSender_Retries(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		statuses []int
+		attempts int32
+		result   string
+	}{
+		"recovered":   {[]int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK}, 3, "delivered"},
+		"exhausted":   {[]int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3, "failed"},
+		"not retried": {[]int{http.StatusBadRequest, http.StatusOK}, 1, "failed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				attempt := int(attempts.Add(1)) - 1
+				if attempt < len(tc.statuses) {
+					w.WriteHeader(tc.statuses[attempt])
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			sender, results := start(t)
+			sender.Send(webhook.Delivery{URL: server.URL, ID: "alert-1", Payload: struct{}{}})
+
+			if r := result(t, results); r != tc.result {
+				t.Errorf("expected %q, got %q", tc.result, r)
+			}
+
+			if got := attempts.Load(); got != tc.attempts {
+				t.Errorf("expected %d attempts, got %d", tc.attempts, got)
+			}
+		})
+	}
+}
+
+func TestSender_ForbiddenAddress(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		attempts.Add(1)
+	}))
+	t.Cleanup(server.Close)
+
+	// Loopback isn't allowed, and neither is the cloud metadata service, link-local.
+	sender, results := start(t, webhook.WithAllowedNetworks(netip.MustParsePrefix("10.0.0.0/8")))
+
+	for _, url := range []string{server.URL, "http://169.254.169.254/latest/meta-data"} {
+		sender.Send(webhook.Delivery{URL: url, ID: "alert-1", Payload: struct{}{}})
+
+		if r := result(t, results); r != webhook.ResultFailed {
+			t.Errorf("%s: expected %q, got %q", url, webhook.ResultFailed, r)
+		}
+	}
+
+	if got := attempts.Load(); got != 0 {
+		t.Errorf("expected no request to reach the server, got %d", got)
+	}
+}
+
+func TestSender_QueueFull(t *testing.T) {
+	t.Parallel()
+
+	results := make(chan string, 10)
+	// Not running, so the queue doesn't drain.
+	sender := webhook.NewSender(webhook.WithQueueSize(1),
+		webhook.WithResults(func(result string) { results <- result }))
+
+	if !sender.Send(webhook.Delivery{URL: "http://localhost", ID: "1"}) ||
+		sender.Send(webhook.Delivery{URL: "http://localhost", ID: "2"}) {
+		t.Error("expected the second delivery to be dropped")
+	}
+
+	if r := <-results; r != webhook.ResultDropped {
+		t.Errorf("expected %q, got %q", webhook.ResultDropped, r)
+	}
+}
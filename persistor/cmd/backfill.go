@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/db"
+	aggtraderepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/aggtrade"
+	backfillrepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/backfill"
+	backfillsvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/backfill"
+)
+
+func runBackfill(ctx context.Context, cfg *config.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	symbols := flags.String("symbols", "", "comma delimited symbols to backfill")
+	from := flags.String("from", "", "RFC 3339 start of the range")
+	to := flags.String("to", "", "RFC 3339 end of the range, defaults to now")
+	source := flags.String("source", backfillsvc.SourceKlines,
+		fmt.Sprintf("%s, or %s to re-aggregate trades", backfillsvc.SourceKlines, backfillsvc.SourceAggTrades))
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *symbols == "" || *from == "" {
+		return errors.New("usage: persistor backfill -symbols BTCUSDT[,ETHUSDT] -from t [-to t] [-source klines|aggtrades]")
+	}
+
+	start, err := parseTimeFlag(*from, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	end, err := parseTimeFlag(*to, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	dbInstance, err := db.New(ctx, db.WithReadDSN(cfg.Database.ReadDSN), db.WithWriteDSN(cfg.Database.WriteDSN))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	defer func() {
+		if err := dbInstance.Close(); err != nil {
+			logger.Error("failed to close database", logging.Err(err))
+		}
+	}()
+
+	svc := backfillsvc.NewService(
+		newBinanceClient(cfg),
+		aggtraderepo.NewRepository(dbInstance.DB()),
+		backfillrepo.NewRepository(dbInstance.DB()),
+	)
+
+	for _, symbol := range strings.Split(*symbols, ",") {
+		req := backfillsvc.Request{
+			Symbol: strings.TrimSpace(symbol),
+			From:   start,
+			To:     end,
+			Source: *source,
+		}
+
+		result, err := svc.Backfill(ctx, req)
+		backfillsvc.LogResult(req, result)
+
+		if err != nil {
+			return fmt.Errorf("failed to backfill %s: %w", result.Symbol, err)
+		}
+	}
+
+	return nil
+}
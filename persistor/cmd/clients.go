@@ -3,36 +3,142 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tlsconfig"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
 	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/aggregator"
 	aggtraderepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/aggtrade"
 	aggtradesvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/aggtrade"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 	"gorm.io/gorm"
 )
 
-func RegisterAggregatorClient(ctx context.Context, conn *grpc.ClientConn, db *gorm.DB) error {
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// RegisterAggregatorClient stores the candles streamed by the ingestor until ctx is done. A stream that can't be
+// opened or fails is reopened with backoff, so the ingestor being down never stops the candle history from being
+// served, and reconciliation repairs the minutes missed meanwhile.
+func RegisterAggregatorClient(ctx context.Context, conn *grpc.ClientConn, db *gorm.DB, symbols []string) {
 	svc := aggtradesvc.NewService(aggtraderepo.NewRepository(db))
 	client := aggregatorpb.NewAggregatorServiceClient(conn)
+	backoff := minReconnectBackoff
+
+	for {
+		started := time.Now()
+
+		err := streamCandlesticks(ctx, svc, client, symbols)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A stream that lasted was healthy, reconnect promptly.
+		if time.Since(started) > maxReconnectBackoff {
+			backoff = minReconnectBackoff
+		}
+
+		logger.Warn("candle stream from the ingestor failed, reconnecting", "backoff", backoff, logging.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(2*backoff, maxReconnectBackoff)
+	}
+}
+
+type streamHandler interface {
+	HandleStream(ctx context.Context, stream grpc.ServerStreamingClient[aggregatorpb.StreamResponse]) error
+}
 
-	stream, err := client.StreamCandlesticks(ctx, &aggregatorpb.StreamRequest{})
+// streamCandlesticks stores the candles of one stream until it fails or ctx is done.
+func streamCandlesticks(ctx context.Context, svc streamHandler, client aggregatorpb.AggregatorServiceClient,
+	symbols []string) error {
+	stream, err := client.StreamCandlesticks(ctx, &aggregatorpb.StreamRequest{
+		Symbols: symbols,
+		// Footprints are only streamed for the symbols the ingestor builds them for.
+		Footprints: true,
+	})
 	if err != nil {
-		log.Fatalf("could not stream candlesticks from aggregator service: %v", err)
+		return fmt.Errorf("could not stream candlesticks from aggregator service: %w", err)
 	}
 
-	errGrp := errgroup.Group{}
+	logger.Info("connected to gRPC server, listening from aggregator service", "symbols", symbols)
 
-	errGrp.Go(func() error {
-		return svc.HandleStream(ctx, stream)
+	return svc.HandleStream(ctx, stream)
+}
+
+// aggregatorCredentials returns the transport credentials to dial the ingestor with, reloading rotated
+// certificates until ctx is done when TLS is enabled.
+func aggregatorCredentials(ctx context.Context, cfg *config.AppConfig) (credentials.TransportCredentials, error) {
+	if !cfg.ServerTLS.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	certs, err := tlsconfig.NewReloader(tlsconfig.Files{
+		CertFile: cfg.ServerTLS.CertFile,
+		KeyFile:  cfg.ServerTLS.KeyFile,
+		CAFile:   cfg.ServerTLS.CAFile,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ingestor TLS certificates: %w", err)
+	}
+
+	go certs.Watch(ctx, cfg.ServerTLS.ReloadInterval)
+
+	logger.Info("dialing ingestor over TLS", "client_certificate", cfg.ServerTLS.CertFile != "")
+
+	return credentials.NewTLS(certs.ClientConfig(cfg.ServerTLS.ServerName)), nil
+}
+
+// tokenCredentials attaches the API key or the JWT read from a file to every call to the ingestor. The file
+// is read per call, so a refreshed token is used without a restart.
+type tokenCredentials struct {
+	apiKey    string
+	tokenFile string
+	secure    bool
+}
+
+// aggregatorCallCredentials returns the per-RPC credentials for the ingestor, nil when none are configured.
+func aggregatorCallCredentials(cfg *config.AppConfig) credentials.PerRPCCredentials {
+	if cfg.ServerAuth.APIKey == "" && cfg.ServerAuth.TokenFile == "" {
+		return nil
+	}
 
-	log.Println("connected to gRPC server, listening from aggregator service...")
+	if !cfg.ServerTLS.Enabled {
+		logger.Warn("sending ingestor credentials over plaintext, enable SERVER_TLS_ENABLED outside local setups")
+	}
 
-	if err := errGrp.Wait(); err != nil {
-		return fmt.Errorf("aggregator client is failing: %w", err)
+	return &tokenCredentials{
+		apiKey:    cfg.ServerAuth.APIKey,
+		tokenFile: cfg.ServerAuth.TokenFile,
+		secure:    cfg.ServerTLS.Enabled,
 	}
+}
+
+func (c *tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	if c.apiKey != "" {
+		return map[string]string{"x-api-key": c.apiKey}, nil
+	}
+
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingestor token: %w", err)
+	}
+
+	return map[string]string{"authorization": "Bearer " + strings.TrimSpace(string(token))}, nil
+}
 
-	return nil
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return c.secure
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
+)
+
+const usage = `usage: persistor [command]
+
+Without a command the persistor consumes the ingestor stream and serves candle history.
+
+Commands:
+  config check                     print the effective configuration with secrets redacted, and validate it
+  migrate up|down|status|version   manage the database schema
+  maintenance run                  apply the retention, downsampling and partition rules once
+  backfill -symbols S[,S] -from t [-to t] [-source klines|aggtrades]
+                                   upsert the history of symbols, resuming interrupted ranges
+  reconcile -symbols S[,S] [-from t] [-to t] [-repair]
+                                   compare 1m candles with the exchange's klines and record discrepancies`
+
+// logSettings are validated for every command, as they all log.
+var logSettings = []string{"LOG_FORMAT", "LOG_LEVEL"}
+
+// commandSettings returns the settings a command uses, which are the only ones validated for it, or nil for the
+// server and config check, which validate every setting.
+func commandSettings(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	dsns := []string{"DB_WRITE_DSN", "DB_READ_DSN"}
+	binance := []string{"BINANCE_REST_BASE_URL", "BINANCE_REST_WEIGHT_LIMIT"}
+
+	switch args[0] {
+	case "migrate":
+		return slices.Concat(logSettings, []string{"DB_WRITE_DSN"})
+	case "maintenance":
+		return slices.Concat(logSettings, dsns, []string{"MAINTENANCE_INTERVAL", "MAINTENANCE_RULES",
+			"MAINTENANCE_BATCH_SIZE", "MAINTENANCE_PARTITIONS_AHEAD"})
+	case "backfill":
+		return slices.Concat(logSettings, dsns, binance)
+	case "reconcile":
+		return slices.Concat(logSettings, dsns, binance,
+			[]string{"RECONCILE_PRICE_TOLERANCE", "RECONCILE_VOLUME_TOLERANCE"})
+	default:
+		return nil
+	}
+}
+
+func runCommand(ctx context.Context, cfg *config.AppConfig, loadErr error, args []string) error {
+	switch args[0] {
+	case "config":
+		return runConfig(cfg, loadErr, args[1:])
+	case "help", "-h", "--help":
+		_, err := fmt.Fprintln(os.Stdout, usage)
+
+		return err
+	}
+
+	if loadErr != nil {
+		return loadErr
+	}
+
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, cfg, args[1:])
+	case "maintenance":
+		return runMaintenance(ctx, cfg, args[1:])
+	case "backfill":
+		return runBackfill(ctx, cfg, args[1:])
+	case "reconcile":
+		return runReconcile(ctx, cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
+
+// runConfig prints the effective configuration, then fails when it is invalid.
+func runConfig(cfg *config.AppConfig, loadErr error, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errors.New("usage: persistor config check")
+	}
+
+	if err := cfg.Write(os.Stdout); err != nil {
+		return err
+	}
+
+	if loadErr != nil {
+		_, _ = fmt.Fprintln(os.Stderr, loadErr)
+
+		return errors.New("configuration is invalid")
+	}
+
+	return nil
+}
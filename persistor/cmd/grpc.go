@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	candlesgrpc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/grpc/candles"
+	candlespb "github.com/majidmvulle/binance-trading-chart-service/persistor/pkg/api/candles"
+	"google.golang.org/grpc"
+)
+
+type options struct {
+	candleServer *candlesgrpc.Server
+}
+
+type Option func(o *options)
+
+type ServerWrapper struct {
+	grpcServer *grpc.Server
+	options    *options
+}
+
+func NewGrpcServer(opts ...Option) *ServerWrapper {
+	opt := options{}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return &ServerWrapper{
+		grpcServer: grpc.NewServer(),
+		options:    &opt,
+	}
+}
+
+func WithCandleServer(candleServer *candlesgrpc.Server) Option {
+	return func(o *options) {
+		o.candleServer = candleServer
+	}
+}
+
+func (s *ServerWrapper) StartGRPCServer(port uint16) error {
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+
+	if s.options.candleServer != nil {
+		candlespb.RegisterCandleServiceServer(s.grpcServer, s.options.candleServer)
+	}
+
+	logger.Info("gRPC serving", "port", port)
+
+	if err := s.grpcServer.Serve(lis); err != nil {
+		return fmt.Errorf("failed to serve gRPC: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ServerWrapper) GracefulStop() {
+	logger.Info("stopping gRPC server gracefully")
+	s.grpcServer.GracefulStop()
+	logger.Info("gRPC server stopped")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	candlesgrpc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/grpc/candles"
+	candleshttp "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/http/candles"
+)
+
+const readHeaderTimeout = 5 * time.Second
+
+type HTTPServer struct {
+	server *http.Server
+}
+
+func NewHTTPServer(port uint16, candleServer *candlesgrpc.Server) *HTTPServer {
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/candles", candleshttp.NewHandler(candleServer))
+
+	return &HTTPServer{
+		server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+func (s *HTTPServer) Start() error {
+	logger.Info("HTTP serving", "addr", s.server.Addr)
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve HTTP: %w", err)
+	}
+
+	return nil
+}
+
+func (s *HTTPServer) Shutdown(ctx context.Context) {
+	if err := s.server.Shutdown(ctx); err != nil {
+		logger.Error("failed to shutdown HTTP server", logging.Err(err))
+	}
+}
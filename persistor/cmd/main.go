@@ -2,50 +2,173 @@ package main
 
 import (
 	"context"
-	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tracing"
 	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
 	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/db"
+	candlesgrpc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/grpc/candles"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/metrics"
+	aggtraderepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/aggtrade"
+	candlessvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/candles"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
-const minutesToRun = 10
+const shutdownTimeout = 5 * time.Second
 
+var logger = logging.Component("main")
+
+//nolint:funlen
 func main() {
-	cfg := config.Config()
+	cfg, loadErr := config.Load(commandSettings(os.Args[1:])...)
+
+	// Invalid log settings are also among the problems of loadErr, reported below or by config check.
+	if err := logging.Setup(logging.Config{
+		Format:           cfg.Log.Format,
+		Level:            cfg.Log.Level,
+		ComponentLevels:  cfg.Log.ComponentLevels,
+		SampleInitial:    cfg.Log.SampleInitial,
+		SampleThereafter: cfg.Log.SampleThereafter,
+	}); err != nil && loadErr == nil {
+		logging.Fatal(logger, "failed to set up logging", logging.Err(err))
+	}
+
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), cfg, loadErr, os.Args[1:]); err != nil {
+			logging.Fatal(logger, "command failed", "command", os.Args[1], logging.Err(err))
+		}
+
+		return
+	}
+
+	if loadErr != nil {
+		logging.Fatal(logger, "failed to load configuration", logging.Err(loadErr))
+	}
+
+	if cfg.Database.MigrateOnStart {
+		if err := migrateOnStart(context.Background(), cfg); err != nil {
+			logging.Fatal(logger, "failed to migrate database", logging.Err(err))
+		}
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName:  cfg.App.Name,
+		Exporter:     cfg.Tracing.Exporter,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		logging.Fatal(logger, "failed to set up tracing", logging.Err(err))
+	}
+
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("failed to flush traces", logging.Err(err))
+		}
+	}()
 
 	dbInstance, err := db.New(context.Background(),
 		db.WithReadDSN(cfg.Database.ReadDSN),
 		db.WithWriteDSN(cfg.Database.WriteDSN),
 	)
 	if err != nil {
-		log.Fatalf("failed to open database: %v", err)
+		logging.Fatal(logger, "failed to open database", logging.Err(err))
+	}
+
+	// Runs until a signal arrives. The candle being saved then is saved before exiting, see aggtrade.HandleStream.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	creds, err := aggregatorCredentials(ctx, cfg)
+	if err != nil {
+		logging.Fatal(logger, "failed to configure ingestor credentials", logging.Err(err))
 	}
 
-	conn, err := grpc.NewClient(cfg.ServerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if callCreds := aggregatorCallCredentials(cfg); callCreds != nil {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(callCreds))
+	}
+
+	conn, err := grpc.NewClient(cfg.ServerAddress, dialOpts...)
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+		logging.Fatal(logger, "did not connect", logging.Err(err))
 	}
 	defer func(conn *grpc.ClientConn) {
 		err := conn.Close()
 		if err != nil {
-			log.Fatalf("could not close grpc connection: %v", err)
+			logging.Fatal(logger, "could not close grpc connection", logging.Err(err))
 		}
 	}(conn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), minutesToRun*time.Minute)
-	defer cancel()
+	downsampled, err := downsampledIntervals(cfg)
+	if err != nil {
+		logging.Fatal(logger, "invalid maintenance rules", logging.Err(err))
+	}
+
+	candleServer := candlesgrpc.NewServer(candlessvc.NewService(aggtraderepo.NewRepository(dbInstance.DB()),
+		candlessvc.WithDownsampled(downsampled...)))
+	grpcServer := NewGrpcServer(WithCandleServer(candleServer))
+	httpServer := NewHTTPServer(uint16(cfg.App.HTTPPort), candleServer) //nolint:gosec // Validate checks the range.
+	metricsServer := metrics.NewServer(uint16(cfg.App.MetricsPort))     //nolint:gosec // Validate checks the range.
+
+	errGrp, ctx := errgroup.WithContext(ctx)
+
+	// The stream consumer reconnects on its own and never stops the servers, it only stops with them.
+	consumerDone := make(chan struct{})
+
+	go func() {
+		defer close(consumerDone)
+
+		RegisterAggregatorClient(ctx, conn, dbInstance.DB(), cfg.ServerAuth.Symbols)
+	}()
+
+	errGrp.Go(func() error {
+		return grpcServer.StartGRPCServer(uint16(cfg.App.GrpcPort)) //nolint:gosec // Validate checks the range.
+	})
+
+	if cfg.Maintenance.Enabled {
+		errGrp.Go(func() error {
+			return RegisterMaintenanceJob(ctx, cfg, dbInstance.DB())
+		})
+	}
+
+	errGrp.Go(func() error {
+		return httpServer.Start()
+	})
 
-	errGrp := errgroup.Group{}
+	errGrp.Go(func() error {
+		return metricsServer.Start()
+	})
 
 	errGrp.Go(func() error {
-		return RegisterAggregatorClient(ctx, conn, dbInstance.DB())
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		httpServer.Shutdown(shutdownCtx)
+		metricsServer.Shutdown(shutdownCtx)
+		grpcServer.GracefulStop()
+
+		return nil
 	})
 
-	if err := errGrp.Wait(); err != nil {
-		log.Fatalf("clients failing: %v", err)
+	err = errGrp.Wait()
+	<-consumerDone
+
+	if err != nil {
+		logging.Fatal(logger, "servers failing", logging.Err(err))
+	}
+
+	if err := dbInstance.Close(); err != nil {
+		logger.Error("failed to close database", logging.Err(err))
 	}
+
+	logger.Info("shut down")
 }
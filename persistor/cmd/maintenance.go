@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/db"
+	maintenancerepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/maintenance"
+	maintenancesvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/maintenance"
+	"gorm.io/gorm"
+)
+
+// RegisterMaintenanceJob runs the retention, downsampling and partition maintenance on its schedule until ctx is done.
+func RegisterMaintenanceJob(ctx context.Context, cfg *config.AppConfig, gormDB *gorm.DB) error {
+	maintenanceCfg, err := maintenanceConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	svc := maintenancesvc.NewService(maintenancerepo.NewRepository(gormDB), maintenanceCfg)
+
+	logger.Info("maintenance job scheduled", "interval", cfg.Maintenance.Interval)
+
+	return svc.Start(ctx, cfg.Maintenance.Interval)
+}
+
+func runMaintenance(ctx context.Context, cfg *config.AppConfig, args []string) error {
+	if len(args) != 1 || args[0] != "run" {
+		return errors.New("usage: persistor maintenance run")
+	}
+
+	maintenanceCfg, err := maintenanceConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	dbInstance, err := db.New(ctx, db.WithReadDSN(cfg.Database.ReadDSN), db.WithWriteDSN(cfg.Database.WriteDSN))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	defer func() {
+		if err := dbInstance.Close(); err != nil {
+			logger.Error("failed to close database", logging.Err(err))
+		}
+	}()
+
+	svc := maintenancesvc.NewService(maintenancerepo.NewRepository(dbInstance.DB()), maintenanceCfg)
+
+	report, err := svc.Run(ctx)
+	maintenancesvc.LogReport(report)
+
+	if err != nil {
+		return fmt.Errorf("maintenance run failed: %w", err)
+	}
+
+	logger.Info("maintenance run finished", "report", report.String())
+
+	return nil
+}
+
+// downsampledIntervals returns the intervals the maintenance rules downsample expired candles to, which candle
+// history is read from even while the job is disabled.
+func downsampledIntervals(cfg *config.AppConfig) ([]string, error) {
+	rules, err := maintenancesvc.ParseRules(cfg.Maintenance.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var intervals []string
+
+	for _, rule := range rules {
+		if rule.DownsampleTo != "" {
+			intervals = append(intervals, rule.DownsampleTo)
+		}
+	}
+
+	return intervals, nil
+}
+
+func maintenanceConfig(cfg *config.AppConfig) (maintenancesvc.Config, error) {
+	rules, err := maintenancesvc.ParseRules(cfg.Maintenance.Rules)
+	if err != nil {
+		return maintenancesvc.Config{}, err
+	}
+
+	return maintenancesvc.Config{
+		Rules:           rules,
+		BatchSize:       cfg.Maintenance.BatchSize,
+		PartitionsAhead: cfg.Maintenance.PartitionsAhead,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/db"
+)
+
+func runMigrate(ctx context.Context, cfg *config.AppConfig, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: persistor migrate up|down|status|version")
+	}
+
+	migrator, err := db.NewMigrator(cfg.Database.WriteDSN)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			logger.Error("failed to close migrator", logging.Err(err))
+		}
+	}()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, migrator)
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to roll back migration: %w", err)
+		}
+
+		logger.Info("migration rolled back", "result", result.String())
+
+		return nil
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, version)
+
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func migrateUp(ctx context.Context, migrator *db.Migrator) error {
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	for _, result := range results {
+		logger.Info("migration applied", "result", result.String())
+	}
+
+	if len(results) == 0 {
+		logger.Info("database schema is up to date")
+	}
+
+	return nil
+}
+
+func printMigrationStatus(ctx context.Context, migrator *db.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+
+	for _, status := range statuses {
+		appliedAt := "-"
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+	}
+
+	return w.Flush()
+}
+
+// migrateOnStart applies pending migrations before the persistor starts serving.
+func migrateOnStart(ctx context.Context, cfg *config.AppConfig) error {
+	migrator, err := db.NewMigrator(cfg.Database.WriteDSN)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := migrator.Close(); err != nil {
+			logger.Error("failed to close migrator", logging.Err(err))
+		}
+	}()
+
+	return migrateUp(ctx, migrator)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/config"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/db"
+	aggtraderepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/aggtrade"
+	reconciliationrepo "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/reconciliation"
+	reconciliationsvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/reconciliation"
+)
+
+const defaultReconcileWindow = 24 * time.Hour
+
+func runReconcile(ctx context.Context, cfg *config.AppConfig, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	symbols := flags.String("symbols", "", "comma delimited symbols to reconcile")
+	from := flags.String("from", "", "RFC 3339 start of the range, defaults to 24h before -to")
+	to := flags.String("to", "", "RFC 3339 end of the range, defaults to now")
+	repair := flags.Bool("repair", false, "overwrite our candles with the exchange's where they differ")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *symbols == "" {
+		return errors.New("usage: persistor reconcile -symbols BTCUSDT[,ETHUSDT] [-from t] [-to t] [-repair]")
+	}
+
+	end, err := parseTimeFlag(*to, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	start, err := parseTimeFlag(*from, end.Add(-defaultReconcileWindow))
+	if err != nil {
+		return err
+	}
+
+	dbInstance, err := db.New(ctx, db.WithReadDSN(cfg.Database.ReadDSN), db.WithWriteDSN(cfg.Database.WriteDSN))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	defer func() {
+		if err := dbInstance.Close(); err != nil {
+			logger.Error("failed to close database", logging.Err(err))
+		}
+	}()
+
+	svc := reconciliationsvc.NewService(
+		newBinanceClient(cfg),
+		aggtraderepo.NewRepository(dbInstance.DB()),
+		reconciliationrepo.NewRepository(dbInstance.DB()),
+		reconciliationsvc.Tolerance{
+			Price:  cfg.Reconciliation.PriceTolerance,
+			Volume: cfg.Reconciliation.VolumeTolerance,
+		},
+	)
+
+	for _, symbol := range strings.Split(*symbols, ",") {
+		result, err := svc.Reconcile(ctx, reconciliationsvc.Request{
+			Symbol: strings.TrimSpace(symbol),
+			From:   start,
+			To:     end,
+			Repair: *repair,
+		})
+
+		logger.Info("reconciled candles", logging.KeySymbol, result.Symbol, "compared", result.Compared,
+			"discrepancies", len(result.Discrepancies), "unexpected", result.Unexpected, "repaired", result.Repaired)
+
+		if err != nil {
+			return fmt.Errorf("failed to reconcile %s: %w", result.Symbol, err)
+		}
+	}
+
+	return nil
+}
+
+func newBinanceClient(cfg *config.AppConfig) *binance.Client {
+	return binance.NewClient(&binance.Config{
+		BaseURL:     cfg.Binance.RestBaseURL,
+		WeightLimit: cfg.Binance.WeightLimit,
+	})
+}
+
+func parseTimeFlag(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339: %w", value, err)
+	}
+
+	return t, nil
+}
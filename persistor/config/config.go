@@ -1,51 +1,194 @@
 package config
 
 import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/envconfig"
 	"github.com/spf13/viper"
 )
 
 var cfg *AppConfig
 
 type AppConfig struct {
+	// File is a YAML or TOML file of settings, which environment variables override.
+	File string `env:"CONFIG_FILE"`
+
 	App struct {
-		Name  string
-		Debug bool
-		Env   string
+		Name        string `env:"APP_NAME"`
+		Debug       bool   `env:"APP_DEBUG"`
+		Env         string `env:"APP_ENV"`
+		GrpcPort    int    `env:"APP_GRPC_PORT"`
+		HTTPPort    int    `env:"APP_HTTP_PORT"`
+		MetricsPort int    `env:"APP_METRICS_PORT"`
+	}
+	Log struct {
+		Format           string   `env:"LOG_FORMAT"`
+		Level            string   `env:"LOG_LEVEL"`
+		ComponentLevels  []string `env:"LOG_COMPONENT_LEVELS"`
+		SampleInitial    int      `env:"LOG_SAMPLE_INITIAL"`
+		SampleThereafter int      `env:"LOG_SAMPLE_THEREAFTER"`
+	}
+	ServerAddress string `env:"SERVER_ADDRESS"`
+	ServerAuth    struct {
+		APIKey    string   `env:"SERVER_API_KEY" secret:"true"`
+		TokenFile string   `env:"SERVER_TOKEN_FILE"`
+		Symbols   []string `env:"SERVER_SYMBOLS"`
+	}
+	ServerTLS struct {
+		Enabled        bool          `env:"SERVER_TLS_ENABLED"`
+		CAFile         string        `env:"SERVER_TLS_CA_FILE"`
+		CertFile       string        `env:"SERVER_TLS_CERT_FILE"`
+		KeyFile        string        `env:"SERVER_TLS_KEY_FILE"`
+		ServerName     string        `env:"SERVER_TLS_SERVER_NAME"`
+		ReloadInterval time.Duration `env:"SERVER_TLS_RELOAD_INTERVAL"`
+	}
+	Database struct {
+		WriteDSN       string `env:"DB_WRITE_DSN" secret:"dsn"`
+		ReadDSN        string `env:"DB_READ_DSN" secret:"dsn"`
+		MigrateOnStart bool   `env:"DB_MIGRATE_ON_START"`
+	}
+	Maintenance struct {
+		Enabled         bool          `env:"MAINTENANCE_ENABLED"`
+		Interval        time.Duration `env:"MAINTENANCE_INTERVAL"`
+		Rules           []string      `env:"MAINTENANCE_RULES"`
+		BatchSize       int           `env:"MAINTENANCE_BATCH_SIZE"`
+		PartitionsAhead int           `env:"MAINTENANCE_PARTITIONS_AHEAD"`
+	}
+	Binance struct {
+		RestBaseURL string `env:"BINANCE_REST_BASE_URL"`
+		WeightLimit int    `env:"BINANCE_REST_WEIGHT_LIMIT"`
 	}
-	ServerAddress string
-	Database      struct {
-		WriteDSN string
-		ReadDSN  string
+	Reconciliation struct {
+		PriceTolerance  float64 `env:"RECONCILE_PRICE_TOLERANCE"`
+		VolumeTolerance float64 `env:"RECONCILE_VOLUME_TOLERANCE"`
+	}
+	Tracing struct {
+		Exporter     string  `env:"TRACING_EXPORTER"`
+		OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+		OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE"`
+		SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO"`
 	}
 }
 
+// Config returns the configuration, loaded on the first call without validation.
 func Config() *AppConfig {
 	if cfg == nil {
-		loadConfig()
+		_ = loadConfig()
 	}
 
 	return cfg
 }
 
-func loadConfig() {
+// Load loads the configuration and validates the settings keys, or every setting when none are given, reporting
+// every invalid one at once. The configuration is returned along with the error, so it can still be printed.
+func Load(keys ...string) (*AppConfig, error) {
+	if err := loadConfig(); err != nil {
+		return cfg, err
+	}
+
+	validate := cfg.Validate
+	if len(keys) > 0 {
+		validate = func() error { return cfg.ValidateOnly(keys...) }
+	}
+
+	if err := validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// Write writes the effective configuration as KEY=value lines, with the API key and DSN passwords redacted.
+func (c *AppConfig) Write(w io.Writer) error {
+	return envconfig.Write(w, c)
+}
+
+func loadConfig() error {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 	viper.AddConfigPath(".")
 	viper.AutomaticEnv()
+	viper.SetDefault("APP_METRICS_PORT", 9090)
+	viper.SetDefault("LOG_FORMAT", "json")
+	viper.SetDefault("LOG_SAMPLE_INITIAL", 10)
+	viper.SetDefault("LOG_SAMPLE_THEREAFTER", 100)
+	viper.SetDefault("MAINTENANCE_INTERVAL", time.Hour)
+	viper.SetDefault("SERVER_TLS_RELOAD_INTERVAL", time.Minute)
+	viper.SetDefault("BINANCE_REST_BASE_URL", "https://api.binance.com")
+	viper.SetDefault("BINANCE_REST_WEIGHT_LIMIT", 6000)
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_OTLP_ENDPOINT", "localhost:4317")
+	viper.SetDefault("TRACING_OTLP_INSECURE", true)
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 0.01)
 
 	_ = viper.ReadInConfig()
 
 	cfg = &AppConfig{}
 
+	var fileErr error
+
+	if cfg.File = viper.GetString("CONFIG_FILE"); cfg.File != "" {
+		fileErr = envconfig.ReadFile(viper.GetViper(), cfg.File)
+	}
+
 	// App.
 	cfg.App.Name = viper.GetString("APP_NAME")
 	cfg.App.Debug = viper.GetBool("APP_DEBUG")
 	cfg.App.Env = viper.GetString("APP_ENV")
+	cfg.App.GrpcPort = viper.GetInt("APP_GRPC_PORT")
+	cfg.App.HTTPPort = viper.GetInt("APP_HTTP_PORT")
+	cfg.App.MetricsPort = viper.GetInt("APP_METRICS_PORT")
+
+	// Logging, APP_DEBUG lowers the default level to debug unless LOG_LEVEL is set.
+	cfg.Log.Format = viper.GetString("LOG_FORMAT")
+	cfg.Log.Level = viper.GetString("LOG_LEVEL")
+	cfg.Log.ComponentLevels = viper.GetStringSlice("LOG_COMPONENT_LEVELS")
+	cfg.Log.SampleInitial = viper.GetInt("LOG_SAMPLE_INITIAL")
+	cfg.Log.SampleThereafter = viper.GetInt("LOG_SAMPLE_THEREAFTER")
+
+	if cfg.Log.Level == "" && cfg.App.Debug {
+		cfg.Log.Level = "debug"
+	}
 
 	// Grpc Server.
 	cfg.ServerAddress = viper.GetString("SERVER_ADDRESS")
+	cfg.ServerAuth.APIKey = viper.GetString("SERVER_API_KEY")
+	cfg.ServerAuth.TokenFile = viper.GetString("SERVER_TOKEN_FILE")
+	cfg.ServerAuth.Symbols = viper.GetStringSlice("SERVER_SYMBOLS")
+	cfg.ServerTLS.Enabled = viper.GetBool("SERVER_TLS_ENABLED")
+	cfg.ServerTLS.CAFile = viper.GetString("SERVER_TLS_CA_FILE")
+	cfg.ServerTLS.CertFile = viper.GetString("SERVER_TLS_CERT_FILE")
+	cfg.ServerTLS.KeyFile = viper.GetString("SERVER_TLS_KEY_FILE")
+	cfg.ServerTLS.ServerName = viper.GetString("SERVER_TLS_SERVER_NAME")
+	cfg.ServerTLS.ReloadInterval = viper.GetDuration("SERVER_TLS_RELOAD_INTERVAL")
 
 	// Database.
 	cfg.Database.WriteDSN = viper.GetString("DB_WRITE_DSN")
 	cfg.Database.ReadDSN = viper.GetString("DB_READ_DSN")
+	cfg.Database.MigrateOnStart = viper.GetBool("DB_MIGRATE_ON_START")
+
+	// Maintenance.
+	cfg.Maintenance.Enabled = viper.GetBool("MAINTENANCE_ENABLED")
+	cfg.Maintenance.Interval = viper.GetDuration("MAINTENANCE_INTERVAL")
+	cfg.Maintenance.Rules = viper.GetStringSlice("MAINTENANCE_RULES")
+	cfg.Maintenance.BatchSize = viper.GetInt("MAINTENANCE_BATCH_SIZE")
+	cfg.Maintenance.PartitionsAhead = viper.GetInt("MAINTENANCE_PARTITIONS_AHEAD")
+
+	// Binance REST API.
+	cfg.Binance.RestBaseURL = viper.GetString("BINANCE_REST_BASE_URL")
+	cfg.Binance.WeightLimit = viper.GetInt("BINANCE_REST_WEIGHT_LIMIT")
+
+	// Reconciliation.
+	cfg.Reconciliation.PriceTolerance = viper.GetFloat64("RECONCILE_PRICE_TOLERANCE")
+	cfg.Reconciliation.VolumeTolerance = viper.GetFloat64("RECONCILE_VOLUME_TOLERANCE")
+
+	// Tracing.
+	cfg.Tracing.Exporter = viper.GetString("TRACING_EXPORTER")
+	cfg.Tracing.OTLPEndpoint = viper.GetString("TRACING_OTLP_ENDPOINT")
+	cfg.Tracing.OTLPInsecure = viper.GetBool("TRACING_OTLP_INSECURE")
+	cfg.Tracing.SampleRatio = viper.GetFloat64("TRACING_SAMPLE_RATIO")
+
+	return fileErr
 }
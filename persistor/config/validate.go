@@ -0,0 +1,92 @@
+package config
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/envconfig"
+	maintenancesvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/maintenance"
+)
+
+// Validate checks the required settings and the format of the others, returning every problem found.
+func (c *AppConfig) Validate() error {
+	return c.problems().Err()
+}
+
+// ValidateOnly checks the settings keys only, for the commands that don't need the others.
+func (c *AppConfig) ValidateOnly(keys ...string) error {
+	return c.problems().Only(keys...).Err()
+}
+
+func (c *AppConfig) problems() *envconfig.Problems {
+	var problems envconfig.Problems
+
+	problems.Port("APP_GRPC_PORT", c.App.GrpcPort)
+	problems.Port("APP_HTTP_PORT", c.App.HTTPPort)
+	problems.Port("APP_METRICS_PORT", c.App.MetricsPort)
+
+	if ports := []int{c.App.GrpcPort, c.App.HTTPPort, c.App.MetricsPort}; ports[0] != 0 &&
+		(ports[0] == ports[1] || ports[0] == ports[2] || ports[1] == ports[2]) {
+		problems.Addf("APP_GRPC_PORT", "APP_GRPC_PORT, APP_HTTP_PORT and APP_METRICS_PORT must differ")
+	}
+
+	problems.OneOf("LOG_FORMAT", c.Log.Format, "json", "text")
+	problems.LogLevel("LOG_LEVEL", c.Log.Level)
+
+	problems.Required("SERVER_ADDRESS", c.ServerAddress)
+	problems.HostPort("SERVER_ADDRESS", c.ServerAddress)
+
+	if (c.ServerTLS.CertFile == "") != (c.ServerTLS.KeyFile == "") {
+		problems.Addf("SERVER_TLS_CERT_FILE", "must be set together with SERVER_TLS_KEY_FILE")
+	}
+
+	if c.ServerTLS.Enabled && c.ServerTLS.ReloadInterval <= 0 {
+		problems.Addf("SERVER_TLS_RELOAD_INTERVAL", "must be positive")
+	}
+
+	validateDSN(&problems, "DB_WRITE_DSN", c.Database.WriteDSN)
+	validateDSN(&problems, "DB_READ_DSN", c.Database.ReadDSN)
+
+	if c.Maintenance.Enabled && c.Maintenance.Interval <= 0 {
+		problems.Addf("MAINTENANCE_INTERVAL", "must be positive")
+	}
+
+	// The rules are parsed even while the job is disabled, as candle history reads the intervals they downsample to.
+	if _, err := maintenancesvc.ParseRules(c.Maintenance.Rules); err != nil {
+		problems.Addf("MAINTENANCE_RULES", "%v", err)
+	}
+
+	problems.NotNegative("MAINTENANCE_BATCH_SIZE", float64(c.Maintenance.BatchSize))
+	problems.NotNegative("MAINTENANCE_PARTITIONS_AHEAD", float64(c.Maintenance.PartitionsAhead))
+
+	problems.Required("BINANCE_REST_BASE_URL", c.Binance.RestBaseURL)
+	problems.URL("BINANCE_REST_BASE_URL", c.Binance.RestBaseURL, "http", "https")
+
+	if c.Binance.WeightLimit <= 0 {
+		problems.Addf("BINANCE_REST_WEIGHT_LIMIT", "must be positive")
+	}
+
+	problems.NotNegative("RECONCILE_PRICE_TOLERANCE", c.Reconciliation.PriceTolerance)
+	problems.NotNegative("RECONCILE_VOLUME_TOLERANCE", c.Reconciliation.VolumeTolerance)
+
+	problems.OneOf("TRACING_EXPORTER", c.Tracing.Exporter, "none", "otlp", "stdout")
+	problems.Between("TRACING_SAMPLE_RATIO", c.Tracing.SampleRatio, 0, 1)
+
+	if c.Tracing.Exporter == "otlp" {
+		problems.HostPort("TRACING_OTLP_ENDPOINT", c.Tracing.OTLPEndpoint)
+	}
+
+	return &problems
+}
+
+// validateDSN checks that dsn is set and parses as a PostgreSQL connection string, without echoing it back
+// since it may hold a password.
+func validateDSN(problems *envconfig.Problems, key, dsn string) {
+	problems.Required(key, dsn)
+
+	if dsn == "" {
+		return
+	}
+
+	if _, err := pgx.ParseConfig(dsn); err != nil {
+		problems.Addf(key, "is not a PostgreSQL connection string")
+	}
+}
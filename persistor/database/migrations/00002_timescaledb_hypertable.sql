@@ -0,0 +1,53 @@
+-- Converts agg_trade_ticks into a TimescaleDB hypertable with a compression policy. Nothing is done when the
+-- timescaledb extension is not installed (or not preloaded), vanilla PostgreSQL keeps the plain table.
+--
+-- No retention policy is set, the maintenance job (MAINTENANCE_RULES) owns retention and rolls 1m candles up
+-- into agg_trade_candles before deleting them. 5m/1h/1d continuous aggregates were rejected: candle history is
+-- resampled from agg_trade_ticks and agg_trade_candles, and their refresh windows would miss backfilled history.
+
+-- +goose Up
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')
+        AND NOT (
+            EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb')
+            AND current_setting('shared_preload_libraries', true) LIKE '%timescaledb%'
+        ) THEN
+        RAISE NOTICE 'timescaledb is not available, keeping agg_trade_ticks a plain table';
+
+        RETURN;
+    END IF;
+
+    CREATE EXTENSION IF NOT EXISTS timescaledb;
+
+    PERFORM create_hypertable('agg_trade_ticks', 'timestamp',
+        chunk_time_interval => INTERVAL '1 day',
+        migrate_data => true,
+        if_not_exists => true);
+
+    ALTER TABLE agg_trade_ticks SET (
+        timescaledb.compress,
+        timescaledb.compress_segmentby = 'symbol',
+        timescaledb.compress_orderby = 'timestamp DESC'
+    );
+
+    PERFORM add_compression_policy('agg_trade_ticks', INTERVAL '7 days', if_not_exists => true);
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- The hypertable conversion and compression settings are not reverted: TimescaleDB has no way to turn
+-- a hypertable back into a plain table in place, so rolling back only removes the compression policy.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+        RETURN;
+    END IF;
+
+    PERFORM remove_compression_policy('agg_trade_ticks', if_exists => true);
+END
+$$;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- Holds candles downsampled from agg_trade_ticks (or from a finer interval of this table) by the persistor
+-- maintenance job before the source rows expire. Partitions are monthly ranges named agg_trade_candles_pYYYY_MM,
+-- created ahead of time and dropped once fully expired by the maintenance job.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE agg_trade_candles (
+    symbol text not null,
+    interval text not null,
+    timestamp timestamp with time zone not null,
+    open double precision not null,
+    high double precision not null,
+    low double precision not null,
+    close double precision not null,
+    volume double precision not null,
+    PRIMARY KEY (symbol, interval, timestamp)
+) PARTITION BY RANGE (timestamp);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE agg_trade_candles;
+-- +goose StatementEnd
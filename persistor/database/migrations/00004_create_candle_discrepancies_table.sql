@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE candle_discrepancies (
+    id bigserial primary key,
+    symbol text not null,
+    timestamp timestamp with time zone not null,
+    field text not null,
+    expected double precision,
+    actual double precision,
+    detected_at timestamp with time zone not null default now(),
+    repaired boolean not null default false
+);
+
+CREATE INDEX candle_discrepancies_symbol_timestamp_idx ON candle_discrepancies (symbol, timestamp);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE candle_discrepancies;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE backfill_progress (
+    symbol text not null,
+    source text not null,
+    start_time timestamp with time zone not null,
+    end_time timestamp with time zone not null,
+    cursor timestamp with time zone not null,
+    completed_at timestamp with time zone,
+    updated_at timestamp with time zone not null default now(),
+    primary key (symbol, source, start_time, end_time)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE backfill_progress;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- Marks candles the ingestor restored from a snapshot after a restart, which may miss the trades it received
+-- while it was down. They don't overwrite candles that aren't marked, e.g. backfilled from the exchange.
+
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE agg_trade_ticks ADD COLUMN reconstructed boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE agg_trade_ticks DROP COLUMN reconstructed;
+-- +goose StatementEnd
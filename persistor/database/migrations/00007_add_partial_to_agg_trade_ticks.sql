@@ -0,0 +1,12 @@
+-- Marks candles the ingestor flushed on shutdown before their minute ended. They are replaced by the complete
+-- candle of the minute when it is received, and don't overwrite candles that aren't partial.
+
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE agg_trade_ticks ADD COLUMN partial boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE agg_trade_ticks DROP COLUMN partial;
+-- +goose StatementEnd
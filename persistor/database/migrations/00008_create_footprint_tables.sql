@@ -0,0 +1,32 @@
+-- Holds the footprints of the 1m candles streamed with them, the buy and sell volume by price level, and the
+-- volume profiles of the UTC-day sessions they belong to, updated with every candle of the session.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE candle_footprints (
+    symbol text not null,
+    timestamp timestamp with time zone not null,
+    price double precision not null,
+    tick double precision not null,
+    buy_volume double precision not null,
+    sell_volume double precision not null,
+    PRIMARY KEY (symbol, timestamp, price)
+);
+
+CREATE TABLE session_profiles (
+    symbol text not null,
+    session_start timestamp with time zone not null,
+    poc double precision not null,
+    value_area_high double precision not null,
+    value_area_low double precision not null,
+    volume double precision not null,
+    updated_at timestamp with time zone not null,
+    PRIMARY KEY (symbol, session_start)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE session_profiles;
+DROP TABLE candle_footprints;
+-- +goose StatementEnd
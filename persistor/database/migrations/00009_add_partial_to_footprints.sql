@@ -0,0 +1,14 @@
+-- Marks the footprints of partial candles and the session profiles missing the start of their session. They don't
+-- overwrite footprints and profiles that aren't partial, which only complete ones replace.
+
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE candle_footprints ADD COLUMN partial boolean not null default false;
+ALTER TABLE session_profiles ADD COLUMN partial boolean not null default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE session_profiles DROP COLUMN partial;
+ALTER TABLE candle_footprints DROP COLUMN partial;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+// Package migrations embeds the goose SQL migrations so the persistor binary can apply them itself.
+package migrations
+
+import "embed"
+
+// FS holds every *.sql migration of this directory.
+//
+//go:embed *.sql
+var FS embed.FS
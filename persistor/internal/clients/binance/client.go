@@ -0,0 +1,315 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ingestorbinance "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+)
+
+var logger = logging.Component("binance")
+
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultWeightLimit = 6000
+	maxRetries         = 3
+	usedWeightHeader   = "X-Mbx-Used-Weight-1m"
+	klinesWeight       = 2
+	aggTradesWeight    = 4
+	// MaxKlinesLimit is the largest page /api/v3/klines returns.
+	MaxKlinesLimit = 1000
+	// MaxAggTradesLimit is the largest page /api/v3/aggTrades returns.
+	MaxAggTradesLimit = 1000
+	// MaxAggTradesWindow is the longest time window /api/v3/aggTrades accepts.
+	MaxAggTradesWindow = time.Hour
+)
+
+type Config struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// WeightLimit is the request weight the client may use per minute, requests wait for the next minute
+	// once it is reached.
+	WeightLimit int
+}
+
+// Kline is a candle as reported by the exchange.
+type Kline struct {
+	OpenTime  time.Time
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64
+	CloseTime time.Time
+	Trades    int64
+}
+
+// AggTradesQuery selects aggregate trades from an ID, or opened in [Start, End] when FromID is zero.
+type AggTradesQuery struct {
+	Symbol string
+	FromID int64
+	Start  time.Time
+	End    time.Time
+	Limit  int
+}
+
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	weightLimit int
+
+	mu           sync.Mutex
+	usedWeight   int
+	weightWindow time.Time
+}
+
+func NewClient(cfg *Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	weightLimit := cfg.WeightLimit
+	if weightLimit <= 0 {
+		weightLimit = defaultWeightLimit
+	}
+
+	return &Client{
+		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient:  httpClient,
+		weightLimit: weightLimit,
+	}
+}
+
+// Klines returns up to limit klines of a symbol and interval opened in [start, end], oldest first.
+func (c *Client) Klines(ctx context.Context, symbol, interval string, start, end time.Time,
+	limit int) ([]Kline, error) {
+	params := url.Values{}
+	params.Set("symbol", strings.ToUpper(symbol))
+	params.Set("interval", interval)
+	params.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	params.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+	params.Set("limit", strconv.Itoa(limit))
+
+	var rows [][]any
+
+	if err := c.get(ctx, "/api/v3/klines", klinesWeight, params, &rows); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, 0, len(rows))
+
+	for _, row := range rows {
+		kline, err := parseKline(row)
+		if err != nil {
+			return nil, err
+		}
+
+		klines = append(klines, kline)
+	}
+
+	return klines, nil
+}
+
+// AggTrades returns up to limit aggregate trades of a symbol, oldest first. The REST payload has no symbol,
+// so it is filled in from the query.
+func (c *Client) AggTrades(ctx context.Context, query AggTradesQuery) ([]ingestorbinance.TradeData, error) {
+	symbol := strings.ToUpper(query.Symbol)
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("limit", strconv.Itoa(query.Limit))
+
+	if query.FromID > 0 {
+		params.Set("fromId", strconv.FormatInt(query.FromID, 10))
+	} else {
+		params.Set("startTime", strconv.FormatInt(query.Start.UnixMilli(), 10))
+		params.Set("endTime", strconv.FormatInt(query.End.UnixMilli(), 10))
+	}
+
+	var trades []ingestorbinance.TradeData
+
+	if err := c.get(ctx, "/api/v3/aggTrades", aggTradesWeight, params, &trades); err != nil {
+		return nil, err
+	}
+
+	for i := range trades {
+		trades[i].Symbol = symbol
+	}
+
+	return trades, nil
+}
+
+// get calls path within the weight limit, waiting out 429 and 418 responses as long as Retry-After asks.
+func (c *Client) get(ctx context.Context, path string, weight int, params url.Values, out any) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.reserveWeight(ctx, weight); err != nil {
+			return err
+		}
+
+		retryAfter, err := c.do(ctx, path, params, out)
+		if retryAfter == 0 || attempt == maxRetries {
+			return err
+		}
+
+		logger.WarnContext(ctx, "rate limited, retrying", "path", path, "retry_after", retryAfter)
+
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// do calls path once and returns how long to wait before retrying when the request was rate limited.
+func (c *Client) do(ctx context.Context, path string, params url.Values, out any) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call %s: %w", path, err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	c.recordWeight(resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("%s returned %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+			return retryAfter(resp.Header), err
+		}
+
+		return 0, err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+
+	return 0, nil
+}
+
+// reserveWeight waits until weight fits in the current minute's limit and accounts for it.
+func (c *Client) reserveWeight(ctx context.Context, weight int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		now := time.Now()
+		if window := now.Truncate(time.Minute); window.After(c.weightWindow) {
+			c.weightWindow, c.usedWeight = window, 0
+		}
+
+		if c.usedWeight+weight <= c.weightLimit {
+			c.usedWeight += weight
+
+			return nil
+		}
+
+		wait := c.weightWindow.Add(time.Minute).Sub(now)
+		logger.InfoContext(ctx, "weight limit reached, waiting", "used_weight", c.usedWeight,
+			"weight_limit", c.weightLimit, "wait", wait)
+
+		c.mu.Unlock()
+		err := sleep(ctx, wait)
+		c.mu.Lock()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// recordWeight replaces our estimate with the weight the exchange reports for the current minute.
+func (c *Client) recordWeight(header http.Header) {
+	used, err := strconv.Atoi(header.Get(usedWeightHeader))
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if window := time.Now().Truncate(time.Minute); !window.Before(c.weightWindow) {
+		c.weightWindow, c.usedWeight = window, used
+	}
+}
+
+// retryAfter returns the Retry-After delay, or the time left in the current minute when it is missing.
+func retryAfter(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return max(time.Duration(seconds)*time.Second, time.Millisecond)
+	}
+
+	now := time.Now()
+
+	return now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseKline parses the positional kline array:
+// [openTime, open, high, low, close, volume, closeTime, quoteVolume, trades, takerBase, takerQuote, ignore].
+func parseKline(row []any) (Kline, error) {
+	const minFields = 9
+
+	if len(row) < minFields {
+		return Kline{}, fmt.Errorf("malformed kline with %d fields", len(row))
+	}
+
+	openTime, ok1 := row[0].(float64)
+	closeTime, ok2 := row[6].(float64)
+	trades, ok3 := row[8].(float64)
+
+	if !ok1 || !ok2 || !ok3 {
+		return Kline{}, fmt.Errorf("malformed kline times: %v", row)
+	}
+
+	kline := Kline{
+		OpenTime:  time.UnixMilli(int64(openTime)).UTC(),
+		CloseTime: time.UnixMilli(int64(closeTime)).UTC(),
+		Trades:    int64(trades),
+	}
+
+	for i, field := range []*float64{&kline.Open, &kline.High, &kline.Low, &kline.Close, &kline.Volume} {
+		value, ok := row[i+1].(string)
+		if !ok {
+			return Kline{}, fmt.Errorf("malformed kline value: %v", row[i+1])
+		}
+
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Kline{}, fmt.Errorf("failed to parse kline value: %w", err)
+		}
+
+		*field = parsed
+	}
+
+	return kline, nil
+}
@@ -0,0 +1,97 @@
+package binance_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/binance"
+)
+
+func TestClient_Klines(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/klines" || r.URL.Query().Get("symbol") != "BTCUSDT" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+
+		_, _ = w.Write([]byte(`[[1737973800000,"100.0","101.5","99.5","100.5","3.5",1737973859999,` +
+			`"350.0",4,"1.0","100.0","0"]]`))
+	}))
+	defer server.Close()
+
+	client := binance.NewClient(&binance.Config{BaseURL: server.URL})
+	start := time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+	klines, err := client.Klines(context.Background(), "btcusdt", "1m", start, start.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatalf("Klines failed: %v", err)
+	}
+
+	want := []binance.Kline{{
+		OpenTime:  start,
+		Open:      100.0,
+		High:      101.5,
+		Low:       99.5,
+		Close:     100.5,
+		Volume:    3.5,
+		CloseTime: start.Add(time.Minute - time.Millisecond),
+		Trades:    4,
+	}}
+
+	if !reflect.DeepEqual(klines, want) {
+		t.Errorf("klines are incorrect. \ngot: %#v \nwant: %#v", klines, want)
+	}
+}
+
+func TestClient_Klines_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := binance.NewClient(&binance.Config{BaseURL: server.URL})
+
+	if _, err := client.Klines(context.Background(), "NOPE", "1m", time.Now(), time.Now(), 10); err == nil {
+		t.Error("expected an error for a non 200 response")
+	}
+}
+
+func TestClient_AggTrades_RetriesRateLimited(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"code":-1003,"msg":"Too many requests."}`, http.StatusTooManyRequests)
+
+			return
+		}
+
+		if r.URL.Query().Get("fromId") != "42" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+
+		w.Header().Set("X-Mbx-Used-Weight-1m", "8")
+		_, _ = w.Write([]byte(`[{"a":42,"p":"100.5","q":"0.25","f":1,"l":2,"T":1737973800000,"m":true,"M":true}]`))
+	}))
+	defer server.Close()
+
+	client := binance.NewClient(&binance.Config{BaseURL: server.URL})
+
+	trades, err := client.AggTrades(context.Background(), binance.AggTradesQuery{Symbol: "btcusdt", FromID: 42,
+		Limit: 10})
+	if err != nil {
+		t.Fatalf("AggTrades failed: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected the rate limited request to be retried once, got %d calls", calls)
+	}
+
+	if len(trades) != 1 || trades[0].Symbol != "BTCUSDT" || trades[0].AggTradeID != 42 || trades[0].Price != "100.5" {
+		t.Errorf("unexpected trades: %+v", trades)
+	}
+}
@@ -0,0 +1,122 @@
+package candles
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	candlessvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/candles"
+	candlespb "github.com/majidmvulle/binance-trading-chart-service/persistor/pkg/api/candles"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type candleService interface {
+	ListCandles(ctx context.Context, query candlessvc.Query) (candlessvc.Page, error)
+}
+
+type Server struct {
+	candlespb.UnimplementedCandleServiceServer
+	candleService candleService
+}
+
+func NewServer(svc candleService) *Server {
+	return &Server{
+		candleService: svc,
+	}
+}
+
+func (s *Server) ListCandles(ctx context.Context,
+	req *candlespb.ListCandlesRequest) (*candlespb.ListCandlesResponse, error) {
+	query := candlessvc.Query{
+		Symbol:    req.GetSymbol(),
+		Interval:  req.GetInterval(),
+		PageSize:  int(req.GetPageSize()),
+		PageToken: req.GetPageToken(),
+		BoxSize:   req.GetBoxSize(),
+	}
+
+	series, ok := seriesFromProto[req.GetSeries()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown series %v", req.GetSeries())
+	}
+
+	query.Series = series
+
+	if req.GetStartTime() != nil {
+		query.From = req.GetStartTime().AsTime()
+	}
+
+	if req.GetEndTime() != nil {
+		query.To = req.GetEndTime().AsTime()
+	}
+
+	page, err := s.candleService.ListCandles(ctx, query)
+	if err != nil {
+		if errors.Is(err, candlessvc.ErrInvalidArgument) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &candlespb.ListCandlesResponse{
+		Candles:       make([]*candlespb.Candle, 0, len(page.Candles)+len(page.Bars)),
+		NextPageToken: page.NextPageToken,
+	}
+
+	for _, candle := range page.Candles {
+		resp.Candles = append(resp.Candles, toProto(candle, page.Interval))
+	}
+
+	for _, bar := range page.Bars {
+		resp.Candles = append(resp.Candles, barToProto(bar, page.Interval, req.GetSeries()))
+	}
+
+	return resp, nil
+}
+
+// seriesFromProto maps the API's series to the service's, unspecified meaning the candles.
+var seriesFromProto = map[candlespb.Series]candlessvc.Series{
+	candlespb.Series_SERIES_UNSPECIFIED: candlessvc.SeriesCandles,
+	candlespb.Series_SERIES_CANDLES:     candlessvc.SeriesCandles,
+	candlespb.Series_SERIES_HEIKIN_ASHI: candlessvc.SeriesHeikinAshi,
+	candlespb.Series_SERIES_RENKO:       candlessvc.SeriesRenko,
+	candlespb.Series_SERIES_RANGE:       candlessvc.SeriesRange,
+}
+
+func toProto(candle models.AggTradeTick, interval string) *candlespb.Candle {
+	return &candlespb.Candle{
+		Symbol:    candle.Symbol,
+		Interval:  interval,
+		Open:      candle.Open,
+		High:      candle.High,
+		Low:       candle.Low,
+		Close:     candle.Close,
+		Volume:    candle.Volume,
+		Timestamp: timestamppb.New(candle.Timestamp.In(time.UTC)),
+	}
+}
+
+func barToProto(bar aggregator.Candlestick, interval string, series candlespb.Series) *candlespb.Candle {
+	candle := &candlespb.Candle{
+		Symbol:    bar.Symbol,
+		Interval:  interval,
+		Open:      bar.Open,
+		High:      bar.High,
+		Low:       bar.Low,
+		Close:     bar.Close,
+		Volume:    bar.Volume,
+		Timestamp: timestamppb.New(bar.Timestamp.In(time.UTC)),
+		Series:    series,
+	}
+
+	if bar.CloseTime != nil {
+		candle.CloseTime = timestamppb.New(bar.CloseTime.In(time.UTC))
+	}
+
+	return candle
+}
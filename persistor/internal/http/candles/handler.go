@@ -0,0 +1,129 @@
+package candles
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	candlespb "github.com/majidmvulle/binance-trading-chart-service/persistor/pkg/api/candles"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Handler exposes the CandleService over JSON/HTTP, using the same field names as the protobuf messages.
+type Handler struct {
+	server    candlespb.CandleServiceServer
+	marshaler protojson.MarshalOptions
+}
+
+func NewHandler(server candlespb.CandleServiceServer) *Handler {
+	return &Handler{
+		server:    server,
+		marshaler: protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+	}
+}
+
+// ServeHTTP handles GET /v1/candles?symbol=&interval=&start_time=&end_time=&page_size=&page_token=&series=&box_size=,
+// times are RFC 3339 and series one of candles, heikin_ashi, renko or range.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := parseRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	resp, err := h.server.ListCandles(r.Context(), req)
+	if err != nil {
+		st := status.Convert(err)
+		writeError(w, httpStatus(st.Code()), st.Message())
+
+		return
+	}
+
+	body, err := h.marshaler.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func parseRequest(r *http.Request) (*candlespb.ListCandlesRequest, error) {
+	params := r.URL.Query()
+	req := &candlespb.ListCandlesRequest{
+		Symbol:    params.Get("symbol"),
+		Interval:  params.Get("interval"),
+		PageToken: params.Get("page_token"),
+	}
+
+	if v := params.Get("page_size"); v != "" {
+		pageSize, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page_size: %w", err)
+		}
+
+		req.PageSize = int32(pageSize)
+	}
+
+	if v := params.Get("series"); v != "" {
+		series, ok := candlespb.Series_value["SERIES_"+strings.ToUpper(v)]
+		if !ok {
+			return nil, fmt.Errorf("invalid series %q", v)
+		}
+
+		req.Series = candlespb.Series(series)
+	}
+
+	if v := params.Get("box_size"); v != "" {
+		boxSize, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid box_size: %w", err)
+		}
+
+		req.BoxSize = boxSize
+	}
+
+	for name, field := range map[string]**timestamppb.Timestamp{
+		"start_time": &req.StartTime,
+		"end_time":   &req.EndTime,
+	} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		*field = timestamppb.New(t)
+	}
+
+	return req, nil
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = fmt.Fprintf(w, "{\"error\":%q}\n", message)
+}
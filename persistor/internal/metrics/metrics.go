@@ -0,0 +1,110 @@
+// Package metrics holds the Prometheus metrics of the persistor. Labels are limited to symbols and a fixed
+// set of operations, so the number of series stays bounded.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace         = "persistor"
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Operations of the DB write metrics.
+const (
+	OpSaveTick           = "save_tick"
+	OpSaveTicks          = "save_ticks"
+	OpSaveFootprint      = "save_footprint"
+	OpSaveSessionProfile = "save_session_profile"
+)
+
+var logger = logging.Component("metrics")
+
+var (
+	CandlesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "candles_received_total",
+		Help:      "Candles received from the ingestor stream.",
+	}, []string{"symbol"})
+
+	CandleDelay = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "candle_delay_seconds",
+		Help:      "Time from the start of a candle to it being received from the ingestor.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 65, 70, 90, 120, 300},
+	}, []string{"symbol"})
+
+	DBWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_write_duration_seconds",
+		Help:      "Duration of candle writes to the database.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	DBWriteErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_write_errors_total",
+		Help:      "Candle writes to the database that failed.",
+	}, []string{"operation"})
+
+	DBBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_batch_size",
+		Help:      "Candles written to the database per write.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 7),
+	}, []string{"operation"})
+)
+
+// ObserveWrite records a database write of size candles started at start.
+func ObserveWrite(operation string, size int, start time.Time, err error) {
+	DBWriteDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	DBBatchSize.WithLabelValues(operation).Observe(float64(size))
+
+	if err != nil {
+		DBWriteErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// Server serves the metrics over HTTP.
+type Server struct {
+	server *http.Server
+}
+
+func NewServer(port uint16) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	return &Server{
+		server: &http.Server{
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+}
+
+func (s *Server) Start() error {
+	logger.Info("metrics serving", "addr", s.server.Addr)
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve metrics: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) {
+	if err := s.server.Shutdown(ctx); err != nil {
+		logger.Error("failed to shutdown metrics server", logging.Err(err))
+	}
+}
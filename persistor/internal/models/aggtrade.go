@@ -10,8 +10,29 @@ type AggTradeTick struct {
 	Low       float64   `gorm:"not null"                    json:"low"`
 	Close     float64   `gorm:"not null"                    json:"close"`
 	Volume    float64   `gorm:"not null"                    json:"volume"`
+	// Reconstructed is set on candles the ingestor restored from a snapshot after a restart.
+	Reconstructed bool `gorm:"not null" json:"reconstructed"`
+	// Partial is set on candles the ingestor flushed before their minute ended, on shutdown or when their symbol was
+	// removed.
+	Partial bool `gorm:"not null" json:"partial"`
 }
 
 func (AggTradeTick) TableName() string {
 	return "agg_trade_ticks"
 }
+
+// AggTradeCandle is a candle of a coarser interval downsampled from AggTradeTick rows.
+type AggTradeCandle struct {
+	Symbol    string    `gorm:"primaryKey"                  json:"symbol"`
+	Interval  string    `gorm:"primaryKey"                  json:"interval"`
+	Timestamp time.Time `gorm:"primaryKey;type:timestamptz" json:"timestamp"`
+	Open      float64   `gorm:"not null"                    json:"open"`
+	High      float64   `gorm:"not null"                    json:"high"`
+	Low       float64   `gorm:"not null"                    json:"low"`
+	Close     float64   `gorm:"not null"                    json:"close"`
+	Volume    float64   `gorm:"not null"                    json:"volume"`
+}
+
+func (AggTradeCandle) TableName() string {
+	return "agg_trade_candles"
+}
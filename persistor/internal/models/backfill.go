@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// BackfillProgress tracks how far the backfill of a symbol range got, so an interrupted run resumes from Cursor.
+// Every candle before Cursor has been persisted.
+type BackfillProgress struct {
+	Symbol      string     `gorm:"primaryKey"                  json:"symbol"`
+	Source      string     `gorm:"primaryKey"                  json:"source"`
+	StartTime   time.Time  `gorm:"primaryKey;type:timestamptz" json:"start_time"`
+	EndTime     time.Time  `gorm:"primaryKey;type:timestamptz" json:"end_time"`
+	Cursor      time.Time  `gorm:"not null;type:timestamptz"   json:"cursor"`
+	CompletedAt *time.Time `gorm:"type:timestamptz"            json:"completed_at"`
+	UpdatedAt   time.Time  `gorm:"not null;type:timestamptz"   json:"updated_at"`
+}
+
+func (BackfillProgress) TableName() string {
+	return "backfill_progress"
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	// DiscrepancyMissing is the Field of a discrepancy for a candle the exchange has and we don't.
+	DiscrepancyMissing = "missing"
+	// DiscrepancyUnexpected is the Field of a discrepancy for a candle we have and the exchange doesn't.
+	DiscrepancyUnexpected = "unexpected"
+)
+
+// CandleDiscrepancy records a persisted candle field that differs from the exchange's kline.
+// Expected holds the exchange value and Actual ours, Actual is nil for missing candles.
+type CandleDiscrepancy struct {
+	ID         int64     `gorm:"primaryKey"                json:"id"`
+	Symbol     string    `gorm:"not null"                  json:"symbol"`
+	Timestamp  time.Time `gorm:"not null;type:timestamptz" json:"timestamp"`
+	Field      string    `gorm:"not null"                  json:"field"`
+	Expected   *float64  `gorm:"type:double precision"     json:"expected"`
+	Actual     *float64  `gorm:"type:double precision"     json:"actual"`
+	DetectedAt time.Time `gorm:"not null;type:timestamptz" json:"detected_at"`
+	Repaired   bool      `gorm:"not null"                  json:"repaired"`
+}
+
+func (CandleDiscrepancy) TableName() string {
+	return "candle_discrepancies"
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// FootprintLevel is the volume traded in a 1m candle from Price up to the next level, Tick above. Buy volume was
+// traded by aggressive buyers, sell volume by aggressive sellers. Partial is set on the levels of a partial candle.
+type FootprintLevel struct {
+	Symbol     string    `gorm:"primaryKey"                  json:"symbol"`
+	Timestamp  time.Time `gorm:"primaryKey;type:timestamptz" json:"timestamp"`
+	Price      float64   `gorm:"primaryKey"                  json:"price"`
+	Tick       float64   `gorm:"not null"                    json:"tick"`
+	BuyVolume  float64   `gorm:"not null"                    json:"buy_volume"`
+	SellVolume float64   `gorm:"not null"                    json:"sell_volume"`
+	Partial    bool      `gorm:"not null;default:false"      json:"partial"`
+}
+
+func (FootprintLevel) TableName() string {
+	return "candle_footprints"
+}
+
+// SessionProfile is the volume profile of a symbol's UTC-day session as of its last candle: the point of control
+// and the value area. Partial is set on profiles missing the start of their session.
+type SessionProfile struct {
+	Symbol        string    `gorm:"primaryKey"                  json:"symbol"`
+	SessionStart  time.Time `gorm:"primaryKey;type:timestamptz" json:"session_start"`
+	POC           float64   `gorm:"column:poc;not null"         json:"poc"`
+	ValueAreaHigh float64   `gorm:"not null"                    json:"value_area_high"`
+	ValueAreaLow  float64   `gorm:"not null"                    json:"value_area_low"`
+	Volume        float64   `gorm:"not null"                    json:"volume"`
+	UpdatedAt     time.Time `gorm:"not null;type:timestamptz"   json:"updated_at"`
+	Partial       bool      `gorm:"not null;default:false"      json:"partial"`
+}
+
+func (SessionProfile) TableName() string {
+	return "session_profiles"
+}
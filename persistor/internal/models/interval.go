@@ -0,0 +1,34 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// BaseInterval is the interval candles are persisted at, every other interval is derived from it.
+const BaseInterval = "1m"
+
+var intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// ParseInterval returns the duration of a Binance style interval such as 5m, 1h or 1d.
+func ParseInterval(interval string) (time.Duration, error) {
+	d, ok := intervals[interval]
+	if !ok {
+		return 0, fmt.Errorf("unsupported interval %q", interval)
+	}
+
+	return d, nil
+}
@@ -3,12 +3,36 @@ package aggtrade
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/metrics"
 	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 )
 
+const (
+	saveTicksBatchSize = 500
+	bucketOriginTime   = "TIMESTAMPTZ '2000-01-01 00:00:00+00'"
+	tracerName         = "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/repository/aggtrade"
+)
+
+// tickColumns are updated when a saved candle is received again.
+var tickColumns = []string{"open", "high", "low", "close", "volume", "reconstructed", "partial"}
+
+// keepComplete keeps a saved candle rather than overwrite it with a less complete one: complete candles rank
+// above reconstructed ones, which miss the trades of a restart, and those above partial ones, which miss the end
+// of their minute.
+var keepComplete = clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: `
+	(CASE WHEN excluded.partial THEN 2 WHEN excluded.reconstructed THEN 1 ELSE 0 END) <=
+	(CASE WHEN agg_trade_ticks.partial THEN 2 WHEN agg_trade_ticks.reconstructed THEN 1 ELSE 0 END)`,
+}}}
+
 type repository struct {
 	db *gorm.DB
 }
@@ -20,14 +44,191 @@ func NewRepository(db *gorm.DB) *repository {
 }
 
 func (r *repository) SaveTick(ctx context.Context, tick models.AggTradeTick) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "aggtrade.SaveTick",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql"), attribute.String("symbol", tick.Symbol)))
+	defer span.End()
+
+	start := time.Now()
 	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "symbol"}, {Name: "timestamp"}},
-		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume"}),
+		DoUpdates: clause.AssignmentColumns(tickColumns),
+		Where:     keepComplete,
 	}).Create(&tick)
 
+	metrics.ObserveWrite(metrics.OpSaveTick, 1, start, result.Error)
+
 	if result.Error != nil {
+		span.RecordError(result.Error)
+		span.SetStatus(codes.Error, "upsert failed")
+
 		return fmt.Errorf("failed to save candlestick to database: %w", result.Error)
 	}
 
 	return nil
 }
+
+// SaveTicks upserts candles in batches.
+func (r *repository) SaveTicks(ctx context.Context, ticks []models.AggTradeTick) error {
+	if len(ticks) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "timestamp"}},
+		DoUpdates: clause.AssignmentColumns(tickColumns),
+		Where:     keepComplete,
+	}).CreateInBatches(ticks, saveTicksBatchSize)
+
+	metrics.ObserveWrite(metrics.OpSaveTicks, len(ticks), start, result.Error)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save candlesticks to database: %w", result.Error)
+	}
+
+	return nil
+}
+
+// SaveFootprint replaces the footprint of the candle of symbol at timestamp with levels, so the footprint of a
+// partial candle doesn't keep levels the complete one lacks. Like candles, the footprint of a partial candle doesn't
+// replace a complete one.
+func (r *repository) SaveFootprint(ctx context.Context, symbol string, timestamp time.Time, partial bool,
+	levels []models.FootprintLevel) error {
+	start := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if partial {
+			var complete bool
+
+			if err := tx.Model(&models.FootprintLevel{}).
+				Select("count(*) > 0").
+				Where("symbol = ? AND timestamp = ? AND NOT partial", symbol, timestamp).
+				Scan(&complete).Error; err != nil || complete {
+				return err
+			}
+		}
+
+		if err := tx.Where("symbol = ? AND timestamp = ?", symbol, timestamp).
+			Delete(&models.FootprintLevel{}).Error; err != nil {
+			return err
+		}
+
+		if len(levels) == 0 {
+			return nil
+		}
+
+		return tx.CreateInBatches(levels, saveTicksBatchSize).Error
+	})
+
+	metrics.ObserveWrite(metrics.OpSaveFootprint, len(levels), start, err)
+
+	if err != nil {
+		return fmt.Errorf("failed to save footprint to database: %w", err)
+	}
+
+	return nil
+}
+
+// SaveSessionProfile upserts the volume profile of a session, unless it is partial and the saved one isn't: a
+// restarted ingestor's profile misses the session's volume before the restart.
+func (r *repository) SaveSessionProfile(ctx context.Context, profile models.SessionProfile) error {
+	start := time.Now()
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "symbol"}, {Name: "session_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"poc", "value_area_high", "value_area_low", "volume", "updated_at", "partial",
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "NOT excluded.partial OR session_profiles.partial"}}},
+	}).Create(&profile)
+
+	metrics.ObserveWrite(metrics.OpSaveSessionProfile, 1, start, result.Error)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save session profile to database: %w", result.Error)
+	}
+
+	return nil
+}
+
+// DeleteTick deletes the candle of a symbol at timestamp, if any.
+func (r *repository) DeleteTick(ctx context.Context, symbol string, timestamp time.Time) error {
+	result := r.db.WithContext(ctx).
+		Where("symbol = ? AND timestamp = ?", symbol, timestamp).
+		Delete(&models.AggTradeTick{})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete candlestick from database: %w", result.Error)
+	}
+
+	return nil
+}
+
+// ListTicks returns up to limit candles of a symbol in [from, to), oldest first, read from the replica.
+func (r *repository) ListTicks(ctx context.Context, symbol string, from, to time.Time,
+	limit int) ([]models.AggTradeTick, error) {
+	var ticks []models.AggTradeTick
+
+	result := r.db.WithContext(ctx).Clauses(dbresolver.Read).
+		Where("symbol = ? AND timestamp >= ? AND timestamp < ?", symbol, from, to).
+		Order("timestamp ASC").
+		Limit(limit).
+		Find(&ticks)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list candlesticks from database: %w", result.Error)
+	}
+
+	return ticks, nil
+}
+
+// ListResampled returns up to limit candles of a symbol in [from, to) aggregated from the source interval rows
+// into buckets of interval aligned to UTC, oldest first, read from the replica. Source 1m rows are read from
+// agg_trade_ticks, coarser ones from agg_trade_candles.
+func (r *repository) ListResampled(ctx context.Context, symbol, source string, interval time.Duration, from,
+	to time.Time, limit int) ([]models.AggTradeTick, error) {
+	table, filter, args := models.AggTradeCandle{}.TableName(), "interval = ? AND ", []any{source}
+	if source == models.BaseInterval {
+		table, filter, args = models.AggTradeTick{}.TableName(), "", nil
+	}
+
+	//nolint:gosec // table names and filters are constants.
+	query := fmt.Sprintf(`SELECT symbol, date_bin(CAST(? AS interval), timestamp, %[1]s) AS timestamp,
+       (array_agg(open ORDER BY timestamp))[1] AS open, max(high) AS high, min(low) AS low,
+       (array_agg(close ORDER BY timestamp DESC))[1] AS close, sum(volume) AS volume
+FROM %[2]s
+WHERE %[3]ssymbol = ? AND timestamp >= ? AND timestamp < ?
+GROUP BY 1, 2
+ORDER BY 2 ASC
+LIMIT ?`, bucketOriginTime, table, filter)
+
+	args = append([]any{fmt.Sprintf("%d seconds", int64(interval.Seconds()))}, args...)
+	args = append(args, symbol, from, to, limit)
+
+	var ticks []models.AggTradeTick
+
+	if err := r.db.WithContext(ctx).Clauses(dbresolver.Read).Raw(query, args...).Scan(&ticks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list resampled candlesticks from database: %w", err)
+	}
+
+	return ticks, nil
+}
+
+// ListDownsampled returns up to limit candles of a symbol and interval in [from, to) that the maintenance job
+// kept after their 1m candles expired, oldest first, read from the replica.
+func (r *repository) ListDownsampled(ctx context.Context, symbol, interval string, from, to time.Time,
+	limit int) ([]models.AggTradeTick, error) {
+	var ticks []models.AggTradeTick
+
+	result := r.db.WithContext(ctx).Clauses(dbresolver.Read).
+		Table(models.AggTradeCandle{}.TableName()).
+		Where("symbol = ? AND interval = ? AND timestamp >= ? AND timestamp < ?", symbol, interval, from, to).
+		Order("timestamp ASC").
+		Limit(limit).
+		Find(&ticks)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list downsampled candlesticks from database: %w", result.Error)
+	}
+
+	return ticks, nil
+}
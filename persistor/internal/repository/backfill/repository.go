@@ -0,0 +1,77 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// GetProgress returns the progress of a backfill range, nil when it never ran.
+func (r *repository) GetProgress(ctx context.Context, symbol, source string, from,
+	to time.Time) (*models.BackfillProgress, error) {
+	var progress models.BackfillProgress
+
+	result := r.db.WithContext(ctx).
+		Where("symbol = ? AND source = ? AND start_time = ? AND end_time = ?", symbol, source, from, to).
+		Take(&progress)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil //nolint:nilnil // no progress is not an error.
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get backfill progress from database: %w", result.Error)
+	}
+
+	return &progress, nil
+}
+
+// LatestProgress returns the progress of the backfill range of a start ending last, nil when none ran.
+func (r *repository) LatestProgress(ctx context.Context, symbol, source string,
+	from time.Time) (*models.BackfillProgress, error) {
+	var progress models.BackfillProgress
+
+	result := r.db.WithContext(ctx).
+		Where("symbol = ? AND source = ? AND start_time = ?", symbol, source, from).
+		Order("end_time DESC").
+		Take(&progress)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil //nolint:nilnil // no progress is not an error.
+	}
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get latest backfill progress from database: %w", result.Error)
+	}
+
+	return &progress, nil
+}
+
+// SaveProgress upserts the progress of a backfill range.
+func (r *repository) SaveProgress(ctx context.Context, progress models.BackfillProgress) error {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "source"}, {Name: "start_time"}, {Name: "end_time"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cursor", "completed_at", "updated_at"}),
+	}).Create(&progress)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save backfill progress to database: %w", result.Error)
+	}
+
+	return nil
+}
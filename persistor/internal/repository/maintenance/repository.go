@@ -0,0 +1,264 @@
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ticksTable       = "agg_trade_ticks"
+	candlesTable     = "agg_trade_candles"
+	footprintsTable  = "candle_footprints"
+	partitionPrefix  = candlesTable + "_p"
+	partitionLayout  = "2006_01"
+	bucketOriginTime = "TIMESTAMPTZ '2000-01-01 00:00:00+00'"
+)
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// OldestTimestamp returns the timestamp of the oldest candle of an interval, zero when there is none.
+func (r *repository) OldestTimestamp(ctx context.Context, interval string) (time.Time, error) {
+	var oldest sql.NullTime
+
+	query := r.db.WithContext(ctx).Table(candlesTable).Where("interval = ?", interval)
+	if interval == models.BaseInterval {
+		query = r.db.WithContext(ctx).Table(ticksTable)
+	}
+
+	if err := query.Select("min(timestamp)").Scan(&oldest).Error; err != nil {
+		return time.Time{}, fmt.Errorf("failed to get oldest %s candle: %w", interval, err)
+	}
+
+	return oldest.Time, nil
+}
+
+// Downsample upserts candles of interval target aggregated from the source interval rows older than before, then
+// deletes those rows, in one transaction so a bucket is never left half rolled up. before must be aligned to the
+// target interval. Source 1m rows are read from agg_trade_ticks, coarser ones from agg_trade_candles, and their
+// footprints are deleted with them.
+//
+// A saved target candle is only replaced by a bucket of every source row, e.g. one backfilled again in full, as a
+// bucket backfilled in part would overwrite it with the few rows of the backfill.
+func (r *repository) Downsample(ctx context.Context, source, target string, before time.Time) (int64, int64,
+	error) {
+	bucket, err := models.ParseInterval(target)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	step, err := models.ParseInterval(source)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	from, filter, filterArgs := candlesTable, "interval = ? AND ", []any{source}
+	if source == models.BaseInterval {
+		from, filter, filterArgs = ticksTable, "", nil
+	}
+
+	//nolint:gosec // table names and filters are constants.
+	query := fmt.Sprintf(`WITH buckets AS (
+    SELECT symbol, date_bin(CAST(? AS interval), timestamp, %[2]s) AS bucket,
+           (array_agg(open ORDER BY timestamp))[1] AS open, max(high) AS high, min(low) AS low,
+           (array_agg(close ORDER BY timestamp DESC))[1] AS close, sum(volume) AS volume,
+           count(*) AS source_rows
+    FROM %[3]s
+    WHERE %[4]stimestamp < ?
+    GROUP BY symbol, bucket)
+INSERT INTO %[1]s (symbol, interval, timestamp, open, high, low, close, volume)
+SELECT b.symbol, ?, b.bucket, b.open, b.high, b.low, b.close, b.volume
+FROM buckets b
+WHERE b.source_rows = ? OR NOT EXISTS (
+    SELECT 1 FROM %[1]s c WHERE c.symbol = b.symbol AND c.interval = ? AND c.timestamp = b.bucket)
+ON CONFLICT (symbol, interval, timestamp) DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high,
+    low = EXCLUDED.low, close = EXCLUDED.close, volume = EXCLUDED.volume`,
+		candlesTable, bucketOriginTime, from, filter)
+
+	args := append([]any{fmt.Sprintf("%d seconds", int64(bucket.Seconds()))}, filterArgs...)
+	args = append(args, before, target, int64(bucket/step), target)
+
+	//nolint:gosec // table names and filters are constants.
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE %stimestamp < ?", from, filter)
+
+	var downsampled, deleted int64
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(query, args...)
+		if result.Error != nil {
+			return fmt.Errorf("failed to downsample %s candles into %s: %w", source, target, result.Error)
+		}
+
+		downsampled = result.RowsAffected
+
+		result = tx.Exec(deleteQuery, append(filterArgs, before)...)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete downsampled %s candles: %w", source, result.Error)
+		}
+
+		deleted = result.RowsAffected
+
+		if source != models.BaseInterval {
+			return nil
+		}
+
+		if err := tx.Exec("DELETE FROM "+footprintsTable+" WHERE timestamp < ?", before).Error; err != nil {
+			return fmt.Errorf("failed to delete downsampled footprints: %w", err)
+		}
+
+		return nil
+	})
+
+	return downsampled, deleted, err
+}
+
+// DeleteBefore deletes the candles of an interval older than before in batches and returns how many were deleted.
+// The footprints of 1m candles are deleted first, so none outlives its candle.
+func (r *repository) DeleteBefore(ctx context.Context, interval string, before time.Time,
+	batchSize int) (int64, error) {
+	if interval == models.BaseInterval {
+		//nolint:gosec // table names are constants.
+		query := fmt.Sprintf(`DELETE FROM %[1]s WHERE (symbol, timestamp, price) IN (
+    SELECT symbol, timestamp, price FROM %[1]s WHERE timestamp < ? LIMIT ?)`, footprintsTable)
+
+		if _, err := r.deleteInBatches(ctx, query, []any{before, batchSize}, batchSize); err != nil {
+			return 0, fmt.Errorf("failed to delete footprints: %w", err)
+		}
+	}
+
+	//nolint:gosec // table names are constants.
+	query := fmt.Sprintf(`DELETE FROM %[1]s WHERE (symbol, timestamp) IN (
+    SELECT symbol, timestamp FROM %[1]s WHERE interval = ? AND timestamp < ? LIMIT ?)
+AND interval = ?`, candlesTable)
+	args := []any{interval, before, batchSize, interval}
+
+	if interval == models.BaseInterval {
+		query = fmt.Sprintf(`DELETE FROM %[1]s WHERE (symbol, timestamp) IN (
+    SELECT symbol, timestamp FROM %[1]s WHERE timestamp < ? LIMIT ?)`, ticksTable)
+		args = []any{before, batchSize}
+	}
+
+	deleted, err := r.deleteInBatches(ctx, query, args, batchSize)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete %s candles: %w", interval, err)
+	}
+
+	return deleted, nil
+}
+
+// deleteInBatches runs a query deleting up to batchSize rows until it deletes fewer, and returns how many it deleted.
+func (r *repository) deleteInBatches(ctx context.Context, query string, args []any, batchSize int) (int64, error) {
+	var deleted int64
+
+	for {
+		result := r.db.WithContext(ctx).Exec(query, args...)
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+
+		deleted += result.RowsAffected
+
+		if result.RowsAffected < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
+
+// EnsurePartitions creates the monthly agg_trade_candles partitions covering [from, to) and returns the new ones.
+func (r *repository) EnsurePartitions(ctx context.Context, from, to time.Time) ([]string, error) {
+	existing, err := r.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+
+	for month := monthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		name := partitionPrefix + month.Format(partitionLayout)
+		if _, ok := existing[name]; ok {
+			continue
+		}
+
+		//nolint:gosec // the partition name is derived from a date.
+		query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			name, candlesTable, month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+
+		if err := r.db.WithContext(ctx).Exec(query).Error; err != nil {
+			return created, fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+
+		created = append(created, name)
+	}
+
+	return created, nil
+}
+
+// DropPartitionsBefore drops the agg_trade_candles partitions whose whole range is older than before.
+func (r *repository) DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error) {
+	existing, err := r.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+
+	for name, month := range existing {
+		if month.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+
+		if err := r.db.WithContext(ctx).Exec("DROP TABLE IF EXISTS " + name).Error; err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+
+		dropped = append(dropped, name)
+	}
+
+	return dropped, nil
+}
+
+// partitions returns the existing agg_trade_candles partitions keyed by name with the month they hold.
+func (r *repository) partitions(ctx context.Context) (map[string]time.Time, error) {
+	var names []string
+
+	err := r.db.WithContext(ctx).Raw(`SELECT c.relname FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+JOIN pg_class p ON p.oid = i.inhparent
+WHERE p.relname = ?`, candlesTable).Scan(&names).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	partitions := make(map[string]time.Time, len(names))
+
+	for _, name := range names {
+		month, err := time.Parse(partitionLayout, strings.TrimPrefix(name, partitionPrefix))
+		if err != nil {
+			// Not managed by the maintenance job.
+			continue
+		}
+
+		partitions[name] = month
+	}
+
+	return partitions, nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
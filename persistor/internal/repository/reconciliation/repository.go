@@ -0,0 +1,35 @@
+package reconciliation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	"gorm.io/gorm"
+)
+
+const discrepanciesBatchSize = 500
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *repository {
+	return &repository{
+		db: db,
+	}
+}
+
+// SaveDiscrepancies records the discrepancies found by a reconciliation run.
+func (r *repository) SaveDiscrepancies(ctx context.Context, discrepancies []models.CandleDiscrepancy) error {
+	if len(discrepancies) == 0 {
+		return nil
+	}
+
+	result := r.db.WithContext(ctx).CreateInBatches(discrepancies, discrepanciesBatchSize)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save candle discrepancies to database: %w", result.Error)
+	}
+
+	return nil
+}
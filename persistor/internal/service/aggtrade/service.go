@@ -3,15 +3,34 @@ package aggtrade
 import (
 	"context"
 	"fmt"
-	"log"
+	"time"
 
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/tracing"
 	aggregatorpb "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/metrics"
 	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 )
 
+const (
+	// saveTimeout bounds the write of a received candle, which isn't cancelled with the stream so the candle is
+	// saved when shutting down.
+	saveTimeout = 10 * time.Second
+	tracerName  = "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/aggtrade"
+)
+
+var logger = logging.Component("aggtrade")
+
 type aggTradeRepo interface {
 	SaveTick(ctx context.Context, tick models.AggTradeTick) error
+	SaveFootprint(ctx context.Context, symbol string, timestamp time.Time, partial bool,
+		levels []models.FootprintLevel) error
+	SaveSessionProfile(ctx context.Context, profile models.SessionProfile) error
 }
 
 type service struct {
@@ -24,24 +43,113 @@ func NewService(repo aggTradeRepo) *service {
 	}
 }
 
+// HandleStream saves the candles received on stream until it fails, or until ctx is done after the candle
+// being saved is.
 func (s *service) HandleStream(ctx context.Context,
 	stream grpc.ServerStreamingClient[aggregatorpb.StreamResponse]) error {
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
+			// Shutting down, the candles received so far were saved.
+			if ctx.Err() != nil {
+				logger.Info("stopped receiving candles", logging.Err(ctx.Err()))
+
+				return nil
+			}
+
 			return fmt.Errorf("error receiving from stream: %w", err)
 		}
 
-		if err := s.aggTradeRepo.SaveTick(ctx, models.AggTradeTick{
-			Symbol:    resp.Symbol,
-			Open:      resp.Open,
-			High:      resp.High,
-			Low:       resp.Low,
-			Close:     resp.Close,
-			Volume:    resp.Volume,
-			Timestamp: resp.Timestamp.AsTime(),
-		}); err != nil {
-			log.Printf("error saving tick: %v", err)
+		if change := resp.GetSymbolsChange(); change != nil {
+			logger.Info("ingestor symbols changed", "added", change.GetAdded(), "removed", change.GetRemoved())
+
+			continue
+		}
+
+		// Only 1m candles are stored, information-driven bars are streamed to the clients requesting them.
+		if barType := resp.GetBarType(); barType != aggregatorpb.BarType_BAR_TYPE_TIME &&
+			barType != aggregatorpb.BarType_BAR_TYPE_UNSPECIFIED {
+			continue
 		}
+
+		metrics.CandlesReceived.WithLabelValues(resp.Symbol).Inc()
+		metrics.CandleDelay.WithLabelValues(resp.Symbol).Observe(time.Since(resp.Timestamp.AsTime()).Seconds())
+
+		s.saveTick(ctx, resp)
+	}
+}
+
+// saveTick saves a received candle in a span continuing the trace of the trade that completed it.
+func (s *service) saveTick(ctx context.Context, resp *aggregatorpb.StreamResponse) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	ctx, span := otel.Tracer(tracerName).Start(tracing.Extract(ctx, resp.GetTraceContext()), "persistor.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("symbol", resp.GetSymbol())))
+	defer span.End()
+
+	if err := s.aggTradeRepo.SaveTick(ctx, models.AggTradeTick{
+		Symbol:        resp.Symbol,
+		Open:          resp.Open,
+		High:          resp.High,
+		Low:           resp.Low,
+		Close:         resp.Close,
+		Volume:        resp.Volume,
+		Timestamp:     resp.Timestamp.AsTime(),
+		Reconstructed: resp.GetReconstructed(),
+		Partial:       resp.GetPartial(),
+	}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "save failed")
+		logger.ErrorContext(ctx, "error saving tick", logging.KeySymbol, resp.GetSymbol(), logging.Err(err))
+	}
+
+	s.saveFootprint(ctx, resp)
+}
+
+// saveFootprint saves the footprint and session profile streamed with a candle. The footprints of reconstructed
+// candles are skipped, as they miss the trades received before the ingestor restarted.
+func (s *service) saveFootprint(ctx context.Context, resp *aggregatorpb.StreamResponse) {
+	footprint := resp.GetFootprint()
+	if footprint == nil || resp.GetReconstructed() {
+		return
+	}
+
+	levels := make([]models.FootprintLevel, 0, len(footprint.GetLevels()))
+	for _, level := range footprint.GetLevels() {
+		levels = append(levels, models.FootprintLevel{
+			Symbol:     resp.GetSymbol(),
+			Timestamp:  resp.GetTimestamp().AsTime(),
+			Price:      level.GetPrice(),
+			Tick:       footprint.GetTick(),
+			BuyVolume:  level.GetBuyVolume(),
+			SellVolume: level.GetSellVolume(),
+			Partial:    resp.GetPartial(),
+		})
+	}
+
+	err := s.aggTradeRepo.SaveFootprint(ctx, resp.GetSymbol(), resp.GetTimestamp().AsTime(), resp.GetPartial(), levels)
+	if err != nil {
+		logger.ErrorContext(ctx, "error saving footprint", logging.KeySymbol, resp.GetSymbol(), logging.Err(err))
+	}
+
+	profile := resp.GetSessionProfile()
+	if profile == nil {
+		return
+	}
+
+	if err := s.aggTradeRepo.SaveSessionProfile(ctx, models.SessionProfile{
+		Symbol:        resp.GetSymbol(),
+		SessionStart:  profile.GetSessionStart().AsTime(),
+		POC:           profile.GetPoc(),
+		ValueAreaHigh: profile.GetValueAreaHigh(),
+		ValueAreaLow:  profile.GetValueAreaLow(),
+		Volume:        profile.GetVolume(),
+		UpdatedAt:     time.Now().UTC(),
+		Partial:       profile.GetPartial(),
+	}); err != nil {
+		logger.ErrorContext(ctx, "error saving session profile", logging.KeySymbol, resp.GetSymbol(),
+			logging.Err(err))
 	}
 }
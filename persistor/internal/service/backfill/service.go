@@ -0,0 +1,341 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	ingestorbinance "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+)
+
+var logger = logging.Component("backfill")
+
+const (
+	// SourceKlines backfills the exchange's 1m klines as they are.
+	SourceKlines = "klines"
+	// SourceAggTrades re-aggregates the exchange's aggregate trades through the ingestor aggregator, so the
+	// candles are built exactly like the live ones.
+	SourceAggTrades = "aggtrades"
+)
+
+// ErrInvalidArgument is returned for backfill requests that can't be served.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+type exchangeClient interface {
+	Klines(ctx context.Context, symbol, interval string, start, end time.Time, limit int) ([]binance.Kline, error)
+	AggTrades(ctx context.Context, query binance.AggTradesQuery) ([]ingestorbinance.TradeData, error)
+}
+
+type candleRepo interface {
+	SaveTicks(ctx context.Context, ticks []models.AggTradeTick) error
+}
+
+type progressRepo interface {
+	GetProgress(ctx context.Context, symbol, source string, from, to time.Time) (*models.BackfillProgress, error)
+	LatestProgress(ctx context.Context, symbol, source string, from time.Time) (*models.BackfillProgress, error)
+	SaveProgress(ctx context.Context, progress models.BackfillProgress) error
+}
+
+// Request selects the 1m candles of a symbol in [From, To) to backfill from Source. A zero To backfills up to
+// now.
+type Request struct {
+	Symbol string
+	From   time.Time
+	To     time.Time
+	Source string
+}
+
+// Result summarizes a backfill run.
+type Result struct {
+	Symbol string
+	// ResumedFrom is where an interrupted run of the same range, or a previous run up to now, left off, zero for
+	// a new range.
+	ResumedFrom time.Time
+	Saved       int
+	// AlreadyCompleted is set when a previous run already backfilled the range.
+	AlreadyCompleted bool
+}
+
+type service struct {
+	client       exchangeClient
+	candleRepo   candleRepo
+	progressRepo progressRepo
+	now          func() time.Time
+}
+
+type options struct {
+	now func() time.Time
+}
+
+// Option represents configurable backfill service option.
+type Option func(o *options)
+
+// WithNow sets the clock resolving the end of requests up to now.
+func WithNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+func NewService(client exchangeClient, candles candleRepo, progress progressRepo, opts ...Option) *service {
+	opt := options{now: time.Now}
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return &service{
+		client:       client,
+		candleRepo:   candles,
+		progressRepo: progress,
+		now:          opt.now,
+	}
+}
+
+// Backfill upserts the 1m candles of a range page by page, recording its progress after every page so an
+// interrupted run of the same request resumes where it stopped. A request up to now continues from where the
+// latest run of the same symbol, source and start stopped. The still forming minute is never backfilled.
+func (s *service) Backfill(ctx context.Context, req Request) (Result, error) {
+	result := Result{Symbol: strings.ToUpper(req.Symbol)}
+	from := req.From.UTC().Truncate(time.Minute)
+	to := req.To.UTC().Truncate(time.Minute)
+
+	if now := s.now().UTC().Truncate(time.Minute); to.IsZero() || to.After(now) {
+		to = now
+	}
+
+	if result.Symbol == "" || !from.Before(to) {
+		return result, fmt.Errorf("%w: a symbol and a non empty time range are required", ErrInvalidArgument)
+	}
+
+	if req.Source != SourceKlines && req.Source != SourceAggTrades {
+		return result, fmt.Errorf("%w: unknown source %q, expected %s or %s", ErrInvalidArgument, req.Source,
+			SourceKlines, SourceAggTrades)
+	}
+
+	progress, err := s.progress(ctx, result.Symbol, req.Source, from, to, req.To.IsZero())
+	if err != nil {
+		return result, err
+	}
+
+	if progress.CompletedAt != nil || progress.Cursor.After(from) {
+		result.ResumedFrom = progress.Cursor
+		result.AlreadyCompleted = progress.CompletedAt != nil
+	}
+
+	if result.AlreadyCompleted {
+		return result, nil
+	}
+
+	page := s.backfillKlines
+	if req.Source == SourceAggTrades {
+		page = s.backfillAggTrades
+	}
+
+	err = page(ctx, progress, func(ticks []models.AggTradeTick, cursor time.Time) error {
+		if err := s.candleRepo.SaveTicks(ctx, ticks); err != nil {
+			return err
+		}
+
+		result.Saved += len(ticks)
+		progress.Cursor = cursor
+
+		return s.saveProgress(ctx, progress)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	completedAt := s.now().UTC()
+	progress.CompletedAt = &completedAt
+
+	return result, s.saveProgress(ctx, progress)
+}
+
+// progress returns the progress of [from, to). Without an explicit end, now has moved since the previous runs, so
+// the latest run of the same start carries its cursor over to [from, to) rather than being started over.
+func (s *service) progress(ctx context.Context, symbol, source string, from, to time.Time,
+	untilNow bool) (*models.BackfillProgress, error) {
+	var (
+		progress *models.BackfillProgress
+		err      error
+	)
+
+	if untilNow {
+		progress, err = s.progressRepo.LatestProgress(ctx, symbol, source, from)
+	} else {
+		progress, err = s.progressRepo.GetProgress(ctx, symbol, source, from, to)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case progress == nil:
+		return &models.BackfillProgress{Symbol: symbol, Source: source, StartTime: from, EndTime: to, Cursor: from}, nil
+	case !progress.EndTime.Before(to):
+		return progress, nil
+	default:
+		return &models.BackfillProgress{
+			Symbol:    symbol,
+			Source:    source,
+			StartTime: from,
+			EndTime:   to,
+			Cursor:    progress.Cursor,
+		}, nil
+	}
+}
+
+func (s *service) saveProgress(ctx context.Context, progress *models.BackfillProgress) error {
+	progress.UpdatedAt = s.now().UTC()
+
+	return s.progressRepo.SaveProgress(ctx, *progress)
+}
+
+// pageFunc persists the candles of a page, every candle before cursor included.
+type pageFunc func(ticks []models.AggTradeTick, cursor time.Time) error
+
+func (s *service) backfillKlines(ctx context.Context, progress *models.BackfillProgress, save pageFunc) error {
+	for cursor := progress.Cursor; cursor.Before(progress.EndTime); {
+		klines, err := s.client.Klines(ctx, progress.Symbol, models.BaseInterval, cursor,
+			progress.EndTime.Add(-time.Millisecond), binance.MaxKlinesLimit)
+		if err != nil {
+			return fmt.Errorf("failed to fetch klines: %w", err)
+		}
+
+		if len(klines) == 0 {
+			return save(nil, progress.EndTime)
+		}
+
+		ticks := make([]models.AggTradeTick, 0, len(klines))
+
+		for _, kline := range klines {
+			ticks = append(ticks, models.AggTradeTick{
+				Symbol:    progress.Symbol,
+				Timestamp: kline.OpenTime,
+				Open:      kline.Open,
+				High:      kline.High,
+				Low:       kline.Low,
+				Close:     kline.Close,
+				Volume:    kline.Volume,
+			})
+		}
+
+		cursor = klines[len(klines)-1].OpenTime.Add(time.Minute)
+
+		if err := save(ticks, cursor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillAggTrades walks the aggregate trades by ID once the first one of the range is found, looking for
+// it in windows as long as the exchange accepts. Only the candles the aggregator completed are saved with a
+// page, the forming one is rebuilt from its first trade when a run resumes.
+func (s *service) backfillAggTrades(ctx context.Context, progress *models.BackfillProgress, save pageFunc) error {
+	agg := aggregator.NewAggregator(aggregator.WithBufferSize(1))
+	cursor, windowStart, fromID := progress.Cursor, progress.Cursor, int64(0)
+
+	for {
+		query := binance.AggTradesQuery{Symbol: progress.Symbol, FromID: fromID, Limit: binance.MaxAggTradesLimit}
+		if fromID == 0 {
+			query.Start = windowStart
+			query.End = minTime(windowStart.Add(binance.MaxAggTradesWindow), progress.EndTime).Add(-time.Millisecond)
+		}
+
+		trades, err := s.client.AggTrades(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to fetch aggregate trades: %w", err)
+		}
+
+		// A short page by ID means we caught up with the exchange, a short window only that it is quiet.
+		var (
+			completed []models.AggTradeTick
+			done      = query.FromID > 0 && len(trades) < binance.MaxAggTradesLimit
+		)
+
+		for _, trade := range trades {
+			if !time.UnixMilli(trade.TradeTime).Before(progress.EndTime) {
+				done = true
+
+				break
+			}
+
+			if _, err := agg.AggregateTrade(trade); err != nil {
+				return fmt.Errorf("failed to aggregate trade %d: %w", trade.AggTradeID, err)
+			}
+
+			select {
+			case candle := <-agg.CandlestickChan:
+				completed = append(completed, toTick(candle))
+				cursor = candle.Timestamp.Add(time.Minute)
+			default:
+			}
+
+			fromID = trade.AggTradeID + 1
+		}
+
+		if fromID == 0 && len(trades) == 0 {
+			// Nothing traded in this window, look in the next one.
+			windowStart = minTime(windowStart.Add(binance.MaxAggTradesWindow), progress.EndTime)
+			cursor, done = windowStart, !windowStart.Before(progress.EndTime)
+		} else if fromID == 0 || done {
+			for _, candle := range agg.Flush() {
+				completed = append(completed, toTick(candle))
+			}
+
+			cursor, done = progress.EndTime, true
+		}
+
+		if err := save(completed, cursor); err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+func toTick(candle *aggregator.Candlestick) models.AggTradeTick {
+	return models.AggTradeTick{
+		Symbol:    candle.Symbol,
+		Timestamp: candle.Timestamp,
+		Open:      candle.Open,
+		High:      candle.High,
+		Low:       candle.Low,
+		Close:     candle.Close,
+		Volume:    candle.Volume,
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+
+	return b
+}
+
+// LogResult logs what a backfill run saved.
+func LogResult(req Request, result Result) {
+	l := logger.With(logging.KeySymbol, result.Symbol, "source", req.Source)
+
+	switch {
+	case result.AlreadyCompleted:
+		l.Info("backfill range already completed")
+	case !result.ResumedFrom.IsZero():
+		l.Info("backfill resumed", "resumed_from", result.ResumedFrom, "saved", result.Saved)
+	default:
+		l.Info("backfill finished", "saved", result.Saved)
+	}
+}
@@ -0,0 +1,283 @@
+package backfill_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	ingestorbinance "github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	backfillsvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/backfill"
+)
+
+var start = time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+type fakeClient struct {
+	klines    []binance.Kline
+	trades    []ingestorbinance.TradeData
+	pageLimit int
+	// failAfter makes the client fail once that many calls were served, 0 never fails.
+	failAfter int
+	calls     int
+}
+
+func (c *fakeClient) call() error {
+	c.calls++
+	if c.failAfter > 0 && c.calls > c.failAfter {
+		return errors.New("connection reset")
+	}
+
+	return nil
+}
+
+func (c *fakeClient) Klines(_ context.Context, _, _ string, from, to time.Time, _ int) ([]binance.Kline, error) {
+	if err := c.call(); err != nil {
+		return nil, err
+	}
+
+	var klines []binance.Kline
+
+	for _, kline := range c.klines {
+		if !kline.OpenTime.Before(from) && !kline.OpenTime.After(to) && len(klines) < c.pageLimit {
+			klines = append(klines, kline)
+		}
+	}
+
+	return klines, nil
+}
+
+func (c *fakeClient) AggTrades(_ context.Context, query binance.AggTradesQuery) ([]ingestorbinance.TradeData, error) {
+	if err := c.call(); err != nil {
+		return nil, err
+	}
+
+	var trades []ingestorbinance.TradeData
+
+	for _, trade := range c.trades {
+		tradeTime := time.UnixMilli(trade.TradeTime)
+
+		match := trade.AggTradeID >= query.FromID
+		if query.FromID == 0 {
+			match = !tradeTime.Before(query.Start) && !tradeTime.After(query.End)
+		}
+
+		if match && len(trades) < c.pageLimit {
+			trades = append(trades, trade)
+		}
+	}
+
+	return trades, nil
+}
+
+type fakeRepo struct {
+	ticks    map[time.Time]models.AggTradeTick
+	progress map[string]models.BackfillProgress
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		ticks:    make(map[time.Time]models.AggTradeTick),
+		progress: make(map[string]models.BackfillProgress),
+	}
+}
+
+func (r *fakeRepo) SaveTicks(_ context.Context, ticks []models.AggTradeTick) error {
+	for _, tick := range ticks {
+		r.ticks[tick.Timestamp] = tick
+	}
+
+	return nil
+}
+
+func (r *fakeRepo) GetProgress(_ context.Context, symbol, source string, from,
+	to time.Time) (*models.BackfillProgress, error) {
+	progress, ok := r.progress[symbol+source+from.String()+to.String()]
+	if !ok {
+		return nil, nil
+	}
+
+	return &progress, nil
+}
+
+func (r *fakeRepo) LatestProgress(_ context.Context, symbol, source string,
+	from time.Time) (*models.BackfillProgress, error) {
+	var latest *models.BackfillProgress
+
+	for _, progress := range r.progress {
+		if progress.Symbol == symbol && progress.Source == source && progress.StartTime.Equal(from) &&
+			(latest == nil || progress.EndTime.After(latest.EndTime)) {
+			latest = &progress
+		}
+	}
+
+	return latest, nil
+}
+
+func (r *fakeRepo) SaveProgress(_ context.Context, progress models.BackfillProgress) error {
+	r.progress[progress.Symbol+progress.Source+progress.StartTime.String()+progress.EndTime.String()] = progress
+
+	return nil
+}
+
+func minuteKlines(n int) []binance.Kline {
+	klines := make([]binance.Kline, 0, n)
+
+	for i := range n {
+		price := float64(100 + i)
+		klines = append(klines, binance.Kline{
+			OpenTime: start.Add(time.Duration(i) * time.Minute),
+			Open:     price, High: price + 1, Low: price - 1, Close: price, Volume: 1,
+		})
+	}
+
+	return klines
+}
+
+func TestService_Backfill_KlinesResumes(t *testing.T) {
+	repo := newFakeRepo()
+	client := &fakeClient{klines: minuteKlines(10), pageLimit: 4, failAfter: 2}
+	svc := backfillsvc.NewService(client, repo, repo)
+	req := backfillsvc.Request{Symbol: "btcusdt", From: start, To: start.Add(10 * time.Minute),
+		Source: backfillsvc.SourceKlines}
+
+	result, err := svc.Backfill(context.Background(), req)
+	if err == nil || result.Saved != 8 {
+		t.Fatalf("expected the first run to fail after 8 candles, got %d candles and error %v", result.Saved, err)
+	}
+
+	client.failAfter = 0
+
+	result, err = svc.Backfill(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+
+	if want := start.Add(8 * time.Minute); !result.ResumedFrom.Equal(want) || result.Saved != 2 {
+		t.Errorf("expected to resume from %v and save 2 candles, got %v and %d", want, result.ResumedFrom,
+			result.Saved)
+	}
+
+	if len(repo.ticks) != 10 {
+		t.Errorf("expected 10 candles, got %d", len(repo.ticks))
+	}
+
+	result, err = svc.Backfill(context.Background(), req)
+	if err != nil || !result.AlreadyCompleted || result.Saved != 0 {
+		t.Errorf("expected a completed range to be skipped, got %+v and error %v", result, err)
+	}
+}
+
+func TestService_Backfill_UntilNowResumes(t *testing.T) {
+	repo := newFakeRepo()
+	client := &fakeClient{klines: minuteKlines(20), pageLimit: 4, failAfter: 1}
+	now := start.Add(10 * time.Minute)
+	svc := backfillsvc.NewService(client, repo, repo, backfillsvc.WithNow(func() time.Time { return now }))
+	req := backfillsvc.Request{Symbol: "BTCUSDT", From: start, Source: backfillsvc.SourceKlines}
+
+	result, err := svc.Backfill(context.Background(), req)
+	if err == nil || result.Saved != 4 {
+		t.Fatalf("expected the first run to fail after 4 candles, got %d candles and error %v", result.Saved, err)
+	}
+
+	// Rerun a few minutes later, up to the new now.
+	client.failAfter = 0
+	now = now.Add(5*time.Minute + 30*time.Second)
+
+	result, err = svc.Backfill(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+
+	if want := start.Add(4 * time.Minute); !result.ResumedFrom.Equal(want) || result.Saved != 11 {
+		t.Errorf("expected to resume from %v and save 11 candles, got %v and %d", want, result.ResumedFrom,
+			result.Saved)
+	}
+
+	// A completed run is continued from its end.
+	now = now.Add(3 * time.Minute)
+
+	result, err = svc.Backfill(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+
+	if want := start.Add(15 * time.Minute); !result.ResumedFrom.Equal(want) || result.Saved != 3 {
+		t.Errorf("expected to continue from %v and save 3 candles, got %v and %d", want, result.ResumedFrom,
+			result.Saved)
+	}
+
+	if len(repo.ticks) != 18 {
+		t.Errorf("expected 18 candles, got %d", len(repo.ticks))
+	}
+
+	result, err = svc.Backfill(context.Background(), req)
+	if err != nil || !result.AlreadyCompleted || result.Saved != 0 {
+		t.Errorf("expected a range completed up to now to be skipped, got %+v and error %v", result, err)
+	}
+}
+
+func TestService_Backfill_AggTrades(t *testing.T) {
+	var trades []ingestorbinance.TradeData
+
+	// Two trades per minute for three minutes, the first after a quiet hour, and one trade past the range.
+	first := start.Add(90 * time.Minute)
+	for i := range 7 {
+		tradeTime := first.Add(time.Duration(i) * 30 * time.Second)
+		trades = append(trades, ingestorbinance.TradeData{
+			AggTradeID: int64(i + 1),
+			Symbol:     "BTCUSDT",
+			Price:      strconv.Itoa(100 + i),
+			Quantity:   "0.5",
+			TradeTime:  tradeTime.UnixMilli(),
+		})
+	}
+
+	repo := newFakeRepo()
+	svc := backfillsvc.NewService(&fakeClient{trades: trades, pageLimit: 3}, repo, repo)
+
+	result, err := svc.Backfill(context.Background(), backfillsvc.Request{
+		Symbol: "BTCUSDT",
+		From:   start,
+		To:     first.Add(3 * time.Minute),
+		Source: backfillsvc.SourceAggTrades,
+	})
+	if err != nil {
+		t.Fatalf("Backfill failed: %v", err)
+	}
+
+	if result.Saved != 3 {
+		t.Fatalf("expected 3 candles, got %d", result.Saved)
+	}
+
+	want := models.AggTradeTick{Symbol: "BTCUSDT", Timestamp: first.Add(2 * time.Minute), Open: 104, High: 105,
+		Low: 104, Close: 105, Volume: 1}
+	if got := repo.ticks[want.Timestamp]; !reflect.DeepEqual(got, want) {
+		t.Errorf("last candle is incorrect. \ngot: %#v \nwant: %#v", got, want)
+	}
+
+	for _, progress := range repo.progress {
+		if progress.CompletedAt == nil || !progress.Cursor.Equal(first.Add(3*time.Minute)) {
+			t.Errorf("expected a completed range, got %+v", progress)
+		}
+	}
+}
+
+func TestService_Backfill_InvalidArguments(t *testing.T) {
+	svc := backfillsvc.NewService(&fakeClient{}, newFakeRepo(), newFakeRepo())
+
+	requests := map[string]backfillsvc.Request{
+		"missing symbol": {From: start, To: start.Add(time.Hour), Source: backfillsvc.SourceKlines},
+		"empty range":    {Symbol: "BTCUSDT", From: start, To: start, Source: backfillsvc.SourceKlines},
+		"unknown source": {Symbol: "BTCUSDT", From: start, To: start.Add(time.Hour), Source: "trades"},
+	}
+
+	for name, req := range requests {
+		if _, err := svc.Backfill(context.Background(), req); !errors.Is(err, backfillsvc.ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package candles
+
+import (
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+)
+
+// Series is a chart transform of the candles of a query.
+type Series string
+
+// Series of Query.Series.
+const (
+	SeriesCandles    Series = ""
+	SeriesHeikinAshi Series = "heikin_ashi"
+	SeriesRenko      Series = "renko"
+	SeriesRange      Series = "range"
+)
+
+// seriesState is the state a series ends a page in, carried to the next page by its token.
+type seriesState struct {
+	// HeikinAshi is the last Heikin-Ashi candle.
+	HeikinAshi *aggregator.Candlestick `json:"heikin_ashi,omitempty"`
+	Renko      *aggregator.RenkoState  `json:"renko,omitempty"`
+	// Range is the range bar forming.
+	Range *aggregator.Candlestick `json:"range,omitempty"`
+}
+
+// Derive transforms candles, ordered oldest first and of a single symbol, into series. Renko bricks and range
+// bars of size are built from the candles' OHLC, not from trades: each candle is replayed as trades at its open,
+// low and high, high first for bearish candles, and close, its volume traded at the close, all at the candle's
+// timestamp. They follow the path of the candles of the requested interval, so 1m candles are the closest to the
+// bricks and bars the ingestor builds from trades.
+func Derive(candles []models.AggTradeTick, series Series, size float64) []aggregator.Candlestick {
+	derived, _ := derive(candles, series, size, nil)
+
+	return derived
+}
+
+// derive is Derive continuing the series from state, nil to start it, returning the state it ends in.
+func derive(candles []models.AggTradeTick, series Series, size float64,
+	state *seriesState) ([]aggregator.Candlestick, *seriesState) {
+	var (
+		derived []aggregator.Candlestick
+		next    seriesState
+	)
+
+	if state == nil {
+		state = &seriesState{}
+	}
+
+	switch series {
+	case SeriesHeikinAshi:
+		heikinAshi := aggregator.ResumeHeikinAshi(state.HeikinAshi)
+
+		for _, candle := range candles {
+			derived = append(derived, *heikinAshi.Next(toCandlestick(candle)))
+		}
+
+		next.HeikinAshi = heikinAshi.Prev()
+	case SeriesRenko:
+		renko := aggregator.NewRenko(size)
+		if state.Renko != nil {
+			renko = aggregator.ResumeRenko(size, *state.Renko)
+		}
+
+		derived = replay(candles, renko)
+		renkoState := renko.State()
+		next.Renko = &renkoState
+	case SeriesRange:
+		ranges := aggregator.ResumeRangeBars(size, state.Range)
+		derived = replay(candles, ranges)
+		next.Range = ranges.Forming()
+	default:
+		for _, candle := range candles {
+			derived = append(derived, *toCandlestick(candle))
+		}
+	}
+
+	return derived, &next
+}
+
+type tradeBars interface {
+	Add(symbol string, tradeTime time.Time, price, quantity float64) []*aggregator.Candlestick
+}
+
+func replay(candles []models.AggTradeTick, bars tradeBars) []aggregator.Candlestick {
+	var derived []aggregator.Candlestick
+
+	for _, candle := range candles {
+		path := []float64{candle.Open, candle.Low, candle.High}
+		if candle.Close < candle.Open {
+			path[1], path[2] = candle.High, candle.Low
+		}
+
+		var completed []*aggregator.Candlestick
+
+		for _, price := range path {
+			completed = append(completed, bars.Add(candle.Symbol, candle.Timestamp, price, 0)...)
+		}
+
+		completed = append(completed, bars.Add(candle.Symbol, candle.Timestamp, candle.Close, candle.Volume)...)
+
+		for _, bar := range completed {
+			derived = append(derived, *bar)
+		}
+	}
+
+	return derived
+}
+
+func toCandlestick(candle models.AggTradeTick) *aggregator.Candlestick {
+	return &aggregator.Candlestick{
+		Symbol:    candle.Symbol,
+		Open:      candle.Open,
+		High:      candle.High,
+		Low:       candle.Low,
+		Close:     candle.Close,
+		Volume:    candle.Volume,
+		Timestamp: candle.Timestamp,
+	}
+}
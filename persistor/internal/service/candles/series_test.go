@@ -0,0 +1,146 @@
+package candles_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	candlessvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/candles"
+)
+
+// seriesTicks are a bullish candle followed by two bearish ones, replayed as open, low, high, close for the first
+// and open, high, low, close for the others.
+func seriesTicks(start time.Time) []models.AggTradeTick {
+	return []models.AggTradeTick{
+		{Symbol: "BTCUSDT", Timestamp: start, Open: 100, High: 112, Low: 98, Close: 110, Volume: 5},
+		{Symbol: "BTCUSDT", Timestamp: start.Add(time.Minute), Open: 110, High: 111, Low: 85, Close: 88, Volume: 4},
+		{Symbol: "BTCUSDT", Timestamp: start.Add(2 * time.Minute), Open: 88, High: 92, Low: 70, Close: 75, Volume: 2},
+	}
+}
+
+// bar is a derived bar with the minutes of the candles opening and closing it, -1 when it has no close time.
+type bar struct {
+	open, high, low, close, volume float64
+	first, last                    int
+}
+
+func toBars(start time.Time, derived []aggregator.Candlestick) []bar {
+	bars := make([]bar, 0, len(derived))
+
+	for _, d := range derived {
+		last := -1
+		if d.CloseTime != nil {
+			last = int(d.CloseTime.Sub(start) / time.Minute)
+		}
+
+		bars = append(bars, bar{d.Open, d.High, d.Low, d.Close, d.Volume, int(d.Timestamp.Sub(start) / time.Minute),
+			last})
+	}
+
+	return bars
+}
+
+func assertSeries(t *testing.T, want, got []bar) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d bars, got %d: %+v", len(want), len(got), got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bar %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestDerive_Renko(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+	got := toBars(start, candlessvc.Derive(seriesTicks(start), candlessvc.SeriesRenko, 10))
+
+	// The first high completes a brick before the first close trades its volume, which the reversal at the
+	// second low then carries. The third low adds two bricks.
+	assertSeries(t, []bar{
+		{100, 110, 100, 110, 0, 0, 0},
+		{100, 100, 90, 90, 5, 0, 1},
+		{90, 90, 80, 80, 4, 1, 2},
+		{80, 80, 70, 70, 0, 2, 2},
+	}, got)
+}
+
+func TestDerive_Range(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+	got := toBars(start, candlessvc.Derive(seriesTicks(start), candlessvc.SeriesRange, 15))
+
+	// The second low takes the first bar past a range of 15, the second close opens the next one, which the
+	// third low closes. The third close is left forming.
+	assertSeries(t, []bar{
+		{100, 112, 85, 85, 5, 0, 1},
+		{88, 92, 70, 70, 4, 1, 2},
+	}, got)
+}
+
+func TestDerive_HeikinAshi(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+
+	got := toBars(start, candlessvc.Derive(seriesTicks(start), candlessvc.SeriesHeikinAshi, 0))
+
+	assertSeries(t, []bar{
+		{105, 112, 98, 105, 5, 0, -1},
+		{105, 111, 85, 98.5, 4, 1, -1},
+		{101.75, 101.75, 70, 81.25, 2, 2, -1},
+	}, got)
+}
+
+func TestService_ListCandles_Series(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+	svc := candlessvc.NewService(&fakeRepo{ticks: seriesTicks(start)})
+
+	page, err := svc.ListCandles(context.Background(), candlessvc.Query{
+		Symbol:  "btcusdt",
+		From:    start,
+		To:      start.Add(time.Hour),
+		Series:  candlessvc.SeriesRenko,
+		BoxSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(page.Candles) != 0 || len(page.Bars) != 4 || page.Bars[0].BarType != aggregator.BarRenko {
+		t.Errorf("expected 4 Renko bricks instead of candles, got %+v", page)
+	}
+}
+
+func TestService_ListCandles_SeriesContinueAcrossPages(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+	svc := candlessvc.NewService(&fakeRepo{ticks: seriesTicks(start)})
+
+	for _, series := range []candlessvc.Series{candlessvc.SeriesHeikinAshi, candlessvc.SeriesRenko,
+		candlessvc.SeriesRange} {
+		query := candlessvc.Query{Symbol: "BTCUSDT", From: start, To: start.Add(time.Hour), Series: series,
+			BoxSize: 15, PageSize: 1}
+
+		var paged []aggregator.Candlestick
+
+		for {
+			page, err := svc.ListCandles(context.Background(), query)
+			if err != nil {
+				t.Fatalf("%s: ListCandles failed: %v", series, err)
+			}
+
+			paged = append(paged, page.Bars...)
+
+			if query.PageToken = page.NextPageToken; query.PageToken == "" {
+				break
+			}
+		}
+
+		// A page per candle derives the series of all candles at once.
+		assertSeries(t, toBars(start, candlessvc.Derive(seriesTicks(start), series, 15)), toBars(start, paged))
+	}
+}
@@ -0,0 +1,400 @@
+package candles
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/aggregator"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+)
+
+const (
+	defaultPageSize = 500
+	maxPageSize     = 1000
+)
+
+// ErrInvalidArgument is returned when a query is malformed.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+type candleRepo interface {
+	ListTicks(ctx context.Context, symbol string, from, to time.Time, limit int) ([]models.AggTradeTick, error)
+	ListResampled(ctx context.Context, symbol, source string, interval time.Duration, from, to time.Time,
+		limit int) ([]models.AggTradeTick, error)
+	ListDownsampled(ctx context.Context, symbol, interval string, from, to time.Time,
+		limit int) ([]models.AggTradeTick, error)
+}
+
+// Query selects a page of candles for a symbol, interval and [From, To) time range. Series transforms them, with
+// BoxSize the box or range of Renko bricks and range bars. A PageToken is only valid with the query that returned
+// it, a zero From and To resolving to the range of its first page.
+type Query struct {
+	Symbol    string
+	Interval  string
+	From      time.Time
+	To        time.Time
+	PageSize  int
+	PageToken string
+	Series    Series
+	BoxSize   float64
+}
+
+// Page holds a page of candles of the resolved interval, NextPageToken is empty on the last page. A page covers at
+// most PageSize+1 intervals of the range, so it holds fewer candles where the range has gaps. Bars holds their
+// series instead when the query has one, continuing the series of the previous pages.
+type Page struct {
+	Interval      string
+	Candles       []models.AggTradeTick
+	Bars          []aggregator.Candlestick
+	NextPageToken string
+}
+
+// level is an interval candles are stored at: the 1m candles, or the candles the maintenance job downsampled
+// them to once they expired.
+type level struct {
+	name     string
+	duration time.Duration
+}
+
+type service struct {
+	candleRepo candleRepo
+	// levels are ordered coarsest first, the order their candles age out in.
+	levels []level
+}
+
+type options struct {
+	downsampled []string
+}
+
+// Option represents configurable candle service option.
+type Option func(o *options)
+
+// WithDownsampled sets the intervals the maintenance job downsamples expired candles to, the DownsampleTo of its
+// rules, so candle history is read from them where the 1m candles have expired.
+func WithDownsampled(intervals ...string) Option {
+	return func(o *options) {
+		o.downsampled = append(o.downsampled, intervals...)
+	}
+}
+
+func NewService(repo candleRepo, opts ...Option) *service {
+	var opt options
+
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	levels := []level{{name: models.BaseInterval, duration: time.Minute}}
+
+	for _, name := range opt.downsampled {
+		duration, err := models.ParseInterval(name)
+		// The intervals were validated with the maintenance rules, several rules may downsample to one.
+		if err != nil || slices.ContainsFunc(levels, func(l level) bool { return l.name == name }) {
+			continue
+		}
+
+		levels = append(levels, level{name: name, duration: duration})
+	}
+
+	slices.SortFunc(levels, func(a, b level) int {
+		return cmp.Compare(b.duration, a.duration)
+	})
+
+	return &service{
+		candleRepo: repo,
+		levels:     levels,
+	}
+}
+
+// ListCandles returns candles oldest first, resampled by the database from every interval they are stored at: the
+// 1m candles, and the downsampled candles where the 1m ones have expired. An interval that isn't a multiple of the
+// downsampled candles of the range is rejected.
+func (s *service) ListCandles(ctx context.Context, query Query) (Page, error) {
+	interval, token, err := normalize(&query)
+	if err != nil {
+		return Page{}, err
+	}
+
+	from := token.Cursor
+
+	// Bounding the page to one interval more than the page size bounds the rows aggregated for it, and tells
+	// whether another page follows.
+	end := query.To
+	if bound := from.Add(time.Duration(query.PageSize+1) * interval); bound.Before(end) {
+		end = bound
+	}
+
+	candles, err := s.list(ctx, query.Symbol, interval, from, end, query.PageSize+1)
+	if err != nil {
+		return Page{}, err
+	}
+
+	var next time.Time
+
+	switch {
+	case len(candles) > query.PageSize:
+		next, candles = candles[query.PageSize].Timestamp, candles[:query.PageSize]
+	case end.Before(query.To):
+		if next, err = s.next(ctx, query.Symbol, interval, end, query.To); err != nil {
+			return Page{}, err
+		}
+	}
+
+	page := Page{Interval: query.Interval, Candles: candles}
+	state := token.State
+
+	if query.Series != SeriesCandles {
+		page.Bars, state = derive(page.Candles, query.Series, query.BoxSize, token.State)
+		page.Candles = nil
+	}
+
+	if !next.IsZero() {
+		page.NextPageToken = encodePageToken(query, next, state)
+	}
+
+	return page, nil
+}
+
+// list returns up to limit candles of interval in [from, to), resampled from every level. Coarser levels hold
+// older candles, so a candle spanning the expiry of a level is merged from both.
+func (s *service) list(ctx context.Context, symbol string, interval time.Duration, from, to time.Time,
+	limit int) ([]models.AggTradeTick, error) {
+	var candles []models.AggTradeTick
+
+	for _, l := range s.levels {
+		if l.duration > interval || interval%l.duration != 0 {
+			rows, err := s.listLevel(ctx, symbol, l, l.duration, from, to, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(rows) > 0 {
+				return nil, fmt.Errorf("%w: %s candles from %s are only kept downsampled to %s, request a multiple of it",
+					ErrInvalidArgument, symbol, rows[0].Timestamp.Format(time.RFC3339), l.name)
+			}
+
+			continue
+		}
+
+		rows, err := s.listLevel(ctx, symbol, l, interval, from, to, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		candles = append(candles, rows...)
+	}
+
+	slices.SortStableFunc(candles, func(a, b models.AggTradeTick) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+
+	return Resample(candles, interval), nil
+}
+
+// next returns the start of the first candle of interval in [from, to), zero when there is none, so the next page
+// skips the gap before it.
+func (s *service) next(ctx context.Context, symbol string, interval time.Duration, from,
+	to time.Time) (time.Time, error) {
+	var next time.Time
+
+	for _, l := range s.levels {
+		rows, err := s.listLevel(ctx, symbol, l, l.duration, from, to, 1)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if len(rows) > 0 && (next.IsZero() || rows[0].Timestamp.Before(next)) {
+			next = rows[0].Timestamp.Truncate(interval)
+		}
+	}
+
+	return next, nil
+}
+
+// listLevel returns up to limit candles of interval in [from, to) resampled from the candles of a level.
+func (s *service) listLevel(ctx context.Context, symbol string, l level, interval time.Duration, from,
+	to time.Time, limit int) ([]models.AggTradeTick, error) {
+	var (
+		candles []models.AggTradeTick
+		err     error
+	)
+
+	switch {
+	case interval != l.duration:
+		candles, err = s.candleRepo.ListResampled(ctx, symbol, l.name, interval, from, to, limit)
+	case l.name == models.BaseInterval:
+		candles, err = s.candleRepo.ListTicks(ctx, symbol, from, to, limit)
+	default:
+		candles, err = s.candleRepo.ListDownsampled(ctx, symbol, l.name, from, to, limit)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s candles: %w", l.name, err)
+	}
+
+	return candles, nil
+}
+
+// Resample merges consecutive candles into buckets of the given interval, aligned to UTC.
+// The input must be ordered oldest first and belong to a single symbol.
+func Resample(ticks []models.AggTradeTick, interval time.Duration) []models.AggTradeTick {
+	if interval <= time.Minute {
+		return ticks
+	}
+
+	candles := make([]models.AggTradeTick, 0, len(ticks))
+
+	for _, tick := range ticks {
+		bucket := tick.Timestamp.UTC().Truncate(interval)
+
+		if n := len(candles); n > 0 && candles[n-1].Timestamp.Equal(bucket) {
+			last := &candles[n-1]
+			last.High = max(last.High, tick.High)
+			last.Low = min(last.Low, tick.Low)
+			last.Close = tick.Close
+			last.Volume += tick.Volume
+
+			continue
+		}
+
+		tick.Timestamp = bucket
+		candles = append(candles, tick)
+	}
+
+	return candles
+}
+
+// normalize validates query and resolves its defaults, returning its interval and the token of its page, which
+// starts at the token's cursor.
+func normalize(query *Query) (time.Duration, pageToken, error) {
+	query.Symbol = strings.ToUpper(strings.TrimSpace(query.Symbol))
+	if query.Symbol == "" {
+		return 0, pageToken{}, fmt.Errorf("%w: symbol is required", ErrInvalidArgument)
+	}
+
+	if query.Interval == "" {
+		query.Interval = models.BaseInterval
+	}
+
+	interval, err := models.ParseInterval(query.Interval)
+	if err != nil {
+		return 0, pageToken{}, fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+	}
+
+	switch query.Series {
+	case SeriesCandles, SeriesHeikinAshi:
+	case SeriesRenko, SeriesRange:
+		if query.BoxSize <= 0 || math.IsInf(query.BoxSize, 0) {
+			return 0, pageToken{}, fmt.Errorf("%w: %s series requires a positive box size", ErrInvalidArgument,
+				query.Series)
+		}
+	default:
+		return 0, pageToken{}, fmt.Errorf("%w: unknown series %q", ErrInvalidArgument, query.Series)
+	}
+
+	switch {
+	case query.PageSize <= 0:
+		query.PageSize = defaultPageSize
+	case query.PageSize > maxPageSize:
+		query.PageSize = maxPageSize
+	}
+
+	var token pageToken
+
+	if query.PageToken != "" {
+		var err error
+
+		if token, err = decodePageToken(query.PageToken); err != nil {
+			return 0, pageToken{}, err
+		}
+
+		// The range of the first page was resolved from the time of its request.
+		if query.To.IsZero() {
+			query.To = token.To
+		}
+
+		if query.From.IsZero() {
+			query.From = token.From
+		}
+	}
+
+	if query.To.IsZero() {
+		query.To = time.Now().UTC()
+	}
+
+	if query.From.IsZero() {
+		query.From = query.To.Add(-time.Duration(query.PageSize) * interval)
+	}
+
+	query.From, query.To = query.From.UTC(), query.To.UTC()
+
+	if !query.From.Before(query.To) {
+		return 0, pageToken{}, fmt.Errorf("%w: start time must be before end time", ErrInvalidArgument)
+	}
+
+	if query.PageToken == "" {
+		return interval, pageToken{Cursor: query.From.Truncate(interval)}, nil
+	}
+
+	if !token.matches(*query, interval) {
+		return 0, pageToken{}, fmt.Errorf("%w: page token does not match the query", ErrInvalidArgument)
+	}
+
+	return interval, token, nil
+}
+
+// pageToken resumes a query at Cursor, the start of the next page's first candle, and its series from State.
+type pageToken struct {
+	Symbol   string       `json:"symbol"`
+	Interval string       `json:"interval"`
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Series   Series       `json:"series,omitempty"`
+	BoxSize  float64      `json:"box_size,omitempty"`
+	Cursor   time.Time    `json:"cursor"`
+	State    *seriesState `json:"state,omitempty"`
+}
+
+// matches reports whether the token was returned for query and resumes inside its range.
+func (t pageToken) matches(query Query, interval time.Duration) bool {
+	return t.Symbol == query.Symbol && t.Interval == query.Interval && t.From.Equal(query.From) &&
+		t.To.Equal(query.To) && t.Series == query.Series && t.BoxSize == query.BoxSize &&
+		!t.Cursor.Before(query.From.Truncate(interval)) && t.Cursor.Before(query.To)
+}
+
+func encodePageToken(query Query, cursor time.Time, state *seriesState) string {
+	raw, _ := json.Marshal(pageToken{
+		Symbol:   query.Symbol,
+		Interval: query.Interval,
+		From:     query.From,
+		To:       query.To,
+		Series:   query.Series,
+		BoxSize:  query.BoxSize,
+		Cursor:   cursor.UTC(),
+		State:    state,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePageToken(token string) (pageToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return pageToken{}, fmt.Errorf("%w: malformed page token", ErrInvalidArgument)
+	}
+
+	var decoded pageToken
+
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return pageToken{}, fmt.Errorf("%w: malformed page token", ErrInvalidArgument)
+	}
+
+	return decoded, nil
+}
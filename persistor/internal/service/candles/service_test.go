@@ -0,0 +1,321 @@
+package candles_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	candlessvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/candles"
+)
+
+type fakeRepo struct {
+	ticks []models.AggTradeTick
+	// downsampled holds the downsampled candles by interval.
+	downsampled map[string][]models.AggTradeTick
+	// resampled counts the calls of ListResampled.
+	resampled int
+}
+
+func (r *fakeRepo) ListTicks(_ context.Context, symbol string, from, to time.Time,
+	limit int) ([]models.AggTradeTick, error) {
+	return filterTicks(r.ticks, symbol, from, to, limit), nil
+}
+
+func (r *fakeRepo) ListResampled(_ context.Context, symbol, source string, interval time.Duration, from,
+	to time.Time, limit int) ([]models.AggTradeTick, error) {
+	r.resampled++
+
+	rows := r.downsampled[source]
+	if source == models.BaseInterval {
+		rows = r.ticks
+	}
+
+	resampled := candlessvc.Resample(filterTicks(rows, symbol, from, to, len(rows)), interval)
+
+	return resampled[:min(limit, len(resampled))], nil
+}
+
+func (r *fakeRepo) ListDownsampled(_ context.Context, symbol, interval string, from, to time.Time,
+	limit int) ([]models.AggTradeTick, error) {
+	return filterTicks(r.downsampled[interval], symbol, from, to, limit), nil
+}
+
+func filterTicks(all []models.AggTradeTick, symbol string, from, to time.Time, limit int) []models.AggTradeTick {
+	var ticks []models.AggTradeTick
+
+	for _, tick := range all {
+		if tick.Symbol == symbol && !tick.Timestamp.Before(from) && tick.Timestamp.Before(to) && len(ticks) < limit {
+			ticks = append(ticks, tick)
+		}
+	}
+
+	return ticks
+}
+
+func minuteTicks(start time.Time, n int) []models.AggTradeTick {
+	ticks := make([]models.AggTradeTick, 0, n)
+
+	for i := range n {
+		price := float64(100 + i)
+		ticks = append(ticks, models.AggTradeTick{
+			Symbol:    "BTCUSDT",
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Open:      price,
+			High:      price + 0.5,
+			Low:       price - 0.5,
+			Close:     price + 0.25,
+			Volume:    1,
+		})
+	}
+
+	return ticks
+}
+
+func TestResample_FiveMinutes(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 2, 0, 0, time.UTC)
+
+	got := candlessvc.Resample(minuteTicks(start, 6), 5*time.Minute)
+
+	want := []models.AggTradeTick{
+		{Symbol: "BTCUSDT", Timestamp: start.Add(-2 * time.Minute), Open: 100, High: 102.5, Low: 99.5,
+			Close: 102.25, Volume: 3},
+		{Symbol: "BTCUSDT", Timestamp: start.Add(3 * time.Minute), Open: 103, High: 105.5, Low: 102.5,
+			Close: 105.25, Volume: 3},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resampled candles are incorrect. \ngot: %#v \nwant: %#v", got, want)
+	}
+}
+
+func TestResample_BaseIntervalIsUnchanged(t *testing.T) {
+	ticks := minuteTicks(time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC), 3)
+
+	if got := candlessvc.Resample(ticks, time.Minute); !reflect.DeepEqual(got, ticks) {
+		t.Errorf("1m candles should not be resampled. \ngot: %#v \nwant: %#v", got, ticks)
+	}
+}
+
+func TestService_ListCandles_Pagination(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+	svc := candlessvc.NewService(&fakeRepo{ticks: minuteTicks(start, 12)})
+	query := candlessvc.Query{
+		Symbol:   "btcusdt",
+		Interval: "5m",
+		From:     start,
+		To:       start.Add(time.Hour),
+		PageSize: 2,
+	}
+
+	page, err := svc.ListCandles(context.Background(), query)
+	if err != nil {
+		t.Fatalf("ListCandles failed: %v", err)
+	}
+
+	if len(page.Candles) != 2 || page.NextPageToken == "" {
+		t.Fatalf("expected 2 candles and a next page, got %d candles and token %q", len(page.Candles),
+			page.NextPageToken)
+	}
+
+	query.PageToken = page.NextPageToken
+
+	page, err = svc.ListCandles(context.Background(), query)
+	if err != nil {
+		t.Fatalf("ListCandles failed for second page: %v", err)
+	}
+
+	if len(page.Candles) != 1 || page.NextPageToken != "" {
+		t.Fatalf("expected 1 candle on the last page, got %d candles and token %q", len(page.Candles),
+			page.NextPageToken)
+	}
+
+	if want := start.Add(10 * time.Minute); !page.Candles[0].Timestamp.Equal(want) {
+		t.Errorf("timestamp mismatch: got %v, want %v", page.Candles[0].Timestamp, want)
+	}
+
+	if page.Candles[0].Volume != 2 {
+		t.Errorf("volume mismatch: got %.2f, want %.2f", page.Candles[0].Volume, 2.0)
+	}
+}
+
+func TestService_ListCandles_PageSkipsGaps(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 0, 0, 0, 0, time.UTC)
+	later := start.Add(72 * time.Hour)
+	repo := &fakeRepo{ticks: append(minuteTicks(start, 30), minuteTicks(later, 30)...)}
+	svc := candlessvc.NewService(repo)
+	query := candlessvc.Query{Symbol: "BTCUSDT", Interval: "1h", From: start, To: start.Add(30 * 24 * time.Hour),
+		PageSize: 2}
+
+	page, err := svc.ListCandles(context.Background(), query)
+	if err != nil {
+		t.Fatalf("ListCandles failed: %v", err)
+	}
+
+	if len(page.Candles) != 1 || page.NextPageToken == "" || repo.resampled != 1 {
+		t.Fatalf("expected 1 candle resampled by the repository and a next page, got %d candles, token %q and %d "+
+			"resamples", len(page.Candles), page.NextPageToken, repo.resampled)
+	}
+
+	query.PageToken = page.NextPageToken
+
+	page, err = svc.ListCandles(context.Background(), query)
+	if err != nil {
+		t.Fatalf("ListCandles failed for second page: %v", err)
+	}
+
+	if len(page.Candles) != 1 || !page.Candles[0].Timestamp.Equal(later) || page.NextPageToken != "" {
+		t.Errorf("expected the last page to hold the candle of %v, got %+v and token %q", later, page.Candles,
+			page.NextPageToken)
+	}
+}
+
+func TestService_ListCandles_PageTokenMatchesQuery(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 10, 0, 0, 0, time.UTC)
+	svc := candlessvc.NewService(&fakeRepo{ticks: minuteTicks(start, 12)})
+	query := candlessvc.Query{Symbol: "BTCUSDT", Interval: "1m", From: start, PageSize: 5}
+
+	page, err := svc.ListCandles(context.Background(), query)
+	if err != nil || page.NextPageToken == "" {
+		t.Fatalf("expected a next page, got token %q and error %v", page.NextPageToken, err)
+	}
+
+	// The end the first page resolved to now is kept by the token.
+	next := query
+	next.PageToken = page.NextPageToken
+
+	if page, err = svc.ListCandles(context.Background(), next); err != nil || len(page.Candles) != 5 {
+		t.Fatalf("expected 5 candles on the second page, got %d and error %v", len(page.Candles), err)
+	}
+
+	mismatches := map[string]func(q *candlessvc.Query){
+		"symbol":   func(q *candlessvc.Query) { q.Symbol = "ETHUSDT" },
+		"interval": func(q *candlessvc.Query) { q.Interval = "5m" },
+		"range":    func(q *candlessvc.Query) { q.From = start.Add(time.Minute) },
+		"series":   func(q *candlessvc.Query) { q.Series = candlessvc.SeriesHeikinAshi },
+	}
+
+	for name, mismatch := range mismatches {
+		q := next
+		mismatch(&q)
+
+		if _, err := svc.ListCandles(context.Background(), q); !errors.Is(err, candlessvc.ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
+
+func TestService_ListCandles_DownsampledBeforeTicks(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 0, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{
+		downsampled: map[string][]models.AggTradeTick{"1h": {
+			{Symbol: "BTCUSDT", Timestamp: start, Open: 1, High: 2, Low: 1, Close: 2, Volume: 10},
+			{Symbol: "BTCUSDT", Timestamp: start.Add(time.Hour), Open: 2, High: 3, Low: 2, Close: 3, Volume: 10},
+		}},
+		ticks: minuteTicks(start.Add(2*time.Hour), 90),
+	}
+	svc := candlessvc.NewService(repo, candlessvc.WithDownsampled("1h"))
+
+	page, err := svc.ListCandles(context.Background(), candlessvc.Query{
+		Symbol:   "BTCUSDT",
+		Interval: "1h",
+		From:     start,
+		To:       start.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("ListCandles failed: %v", err)
+	}
+
+	if len(page.Candles) != 4 {
+		t.Fatalf("expected 2 downsampled and 2 resampled candles, got %d", len(page.Candles))
+	}
+
+	for i, candle := range page.Candles {
+		if want := start.Add(time.Duration(i) * time.Hour); !candle.Timestamp.Equal(want) {
+			t.Errorf("candle %d timestamp mismatch: got %v, want %v", i, candle.Timestamp, want)
+		}
+	}
+
+	if page.Candles[2].Volume != 60 || page.Candles[3].Volume != 30 {
+		t.Errorf("volume mismatch: got %.2f and %.2f, want 60 and 30", page.Candles[2].Volume,
+			page.Candles[3].Volume)
+	}
+}
+
+func TestService_ListCandles_ResamplesDownsampled(t *testing.T) {
+	start := time.Date(2025, time.January, 27, 0, 0, 0, 0, time.UTC)
+
+	var hours []models.AggTradeTick
+
+	for i := range 22 {
+		price := float64(10 + i)
+		hours = append(hours, models.AggTradeTick{Symbol: "BTCUSDT", Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Open: price, High: price + 1, Low: price - 1, Close: price + 0.5, Volume: 60})
+	}
+
+	// The 1m candles expired before 22:00, the rest of the day is still kept as 1m candles.
+	repo := &fakeRepo{
+		downsampled: map[string][]models.AggTradeTick{"1h": hours},
+		ticks:       minuteTicks(start.Add(22*time.Hour), 180),
+	}
+	svc := candlessvc.NewService(repo, candlessvc.WithDownsampled("1h"))
+
+	page, err := svc.ListCandles(context.Background(), candlessvc.Query{
+		Symbol:   "BTCUSDT",
+		Interval: "1d",
+		From:     start,
+		To:       start.Add(7 * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("ListCandles failed: %v", err)
+	}
+
+	want := []models.AggTradeTick{
+		{Symbol: "BTCUSDT", Timestamp: start, Open: 10, High: 219.5, Low: 9, Close: 219.25, Volume: 22*60 + 120},
+		{Symbol: "BTCUSDT", Timestamp: start.Add(24 * time.Hour), Open: 220, High: 279.5, Low: 219.5, Close: 279.25,
+			Volume: 60},
+	}
+
+	if !reflect.DeepEqual(page.Candles, want) {
+		t.Errorf("daily candles are incorrect. \ngot: %#v \nwant: %#v", page.Candles, want)
+	}
+
+	// Finer intervals can't be built from the 1h candles of the expired range, only from the 1m candles.
+	for _, interval := range []string{"1m", "30m"} {
+		_, err := svc.ListCandles(context.Background(), candlessvc.Query{Symbol: "BTCUSDT", Interval: interval,
+			From: start, To: start.Add(time.Hour)})
+		if !errors.Is(err, candlessvc.ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", interval, err)
+		}
+	}
+
+	page, err = svc.ListCandles(context.Background(), candlessvc.Query{Symbol: "BTCUSDT", Interval: "30m",
+		From: start.Add(22 * time.Hour), To: start.Add(23 * time.Hour)})
+	if err != nil || len(page.Candles) != 2 {
+		t.Errorf("expected 2 candles of 30m where the 1m candles are kept, got %d and error %v", len(page.Candles),
+			err)
+	}
+}
+
+func TestService_ListCandles_InvalidArguments(t *testing.T) {
+	svc := candlessvc.NewService(&fakeRepo{})
+	now := time.Now().UTC()
+
+	queries := map[string]candlessvc.Query{
+		"missing symbol":   {Interval: "1m"},
+		"unknown interval": {Symbol: "BTCUSDT", Interval: "7m"},
+		"inverted range":   {Symbol: "BTCUSDT", From: now, To: now.Add(-time.Hour)},
+		"bad page token":   {Symbol: "BTCUSDT", PageToken: "not a token"},
+		"unknown series":   {Symbol: "BTCUSDT", Series: "kagi"},
+		"renko no box":     {Symbol: "BTCUSDT", Series: candlessvc.SeriesRenko},
+		"range negative":   {Symbol: "BTCUSDT", Series: candlessvc.SeriesRange, BoxSize: -1},
+	}
+
+	for name, query := range queries {
+		if _, err := svc.ListCandles(context.Background(), query); !errors.Is(err, candlessvc.ErrInvalidArgument) {
+			t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+)
+
+const day = 24 * time.Hour
+
+// Rule keeps candles of Interval for Retention and, when DownsampleTo is set,
+// rolls them up into that coarser interval before deleting them.
+type Rule struct {
+	Interval     string
+	Retention    time.Duration
+	DownsampleTo string
+}
+
+// ParseRules parses rule specs of the form <interval>:<retention>[:<downsample-to>], e.g. "1m:90d:1h" or "1h:1825d".
+// Retention accepts a number of days suffixed with d or any time.ParseDuration value.
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	seen := make(map[string]bool, len(specs))
+
+	for _, spec := range specs {
+		rule, err := parseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention rule %q: %w", spec, err)
+		}
+
+		if seen[rule.Interval] {
+			return nil, fmt.Errorf("duplicate retention rule for interval %s", rule.Interval)
+		}
+
+		seen[rule.Interval] = true
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func parseRule(spec string) (Rule, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Rule{}, fmt.Errorf("expected <interval>:<retention>[:<downsample-to>]")
+	}
+
+	interval, err := models.ParseInterval(parts[0])
+	if err != nil {
+		return Rule{}, err
+	}
+
+	retention, err := parseRetention(parts[1])
+	if err != nil {
+		return Rule{}, err
+	}
+
+	rule := Rule{Interval: parts[0], Retention: retention}
+
+	if len(parts) == 3 {
+		target, err := models.ParseInterval(parts[2])
+		if err != nil {
+			return Rule{}, err
+		}
+
+		if target <= interval || target%interval != 0 {
+			return Rule{}, fmt.Errorf("%s is not a multiple of %s", parts[2], parts[0])
+		}
+
+		rule.DownsampleTo = parts[2]
+	}
+
+	return rule, nil
+}
+
+func parseRetention(value string) (time.Duration, error) {
+	var (
+		retention time.Duration
+		err       error
+	)
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+
+		n, err = strconv.Atoi(days)
+		retention = time.Duration(n) * day
+	} else {
+		retention, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid retention %q: %w", value, err)
+	}
+
+	if retention <= 0 {
+		return 0, fmt.Errorf("retention %q must be positive", value)
+	}
+
+	return retention, nil
+}
+
+// cutoff returns the time before which the rule's candles expire. When downsampling, it is aligned down to
+// the target interval so only complete target buckets are rolled up and deleted.
+func (r Rule) cutoff(now time.Time) time.Time {
+	cutoff := now.UTC().Add(-r.Retention)
+
+	if r.DownsampleTo != "" {
+		target, _ := models.ParseInterval(r.DownsampleTo)
+		cutoff = cutoff.Truncate(target)
+	}
+
+	return cutoff
+}
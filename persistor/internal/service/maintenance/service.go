@@ -0,0 +1,255 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/ingestor/pkg/logging"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+)
+
+var logger = logging.Component("maintenance")
+
+const (
+	defaultBatchSize       = 10000
+	defaultPartitionsAhead = 3
+)
+
+type maintenanceRepo interface {
+	OldestTimestamp(ctx context.Context, interval string) (time.Time, error)
+	Downsample(ctx context.Context, source, target string, before time.Time) (int64, int64, error)
+	DeleteBefore(ctx context.Context, interval string, before time.Time, batchSize int) (int64, error)
+	EnsurePartitions(ctx context.Context, from, to time.Time) ([]string, error)
+	DropPartitionsBefore(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// Config configures the maintenance job.
+type Config struct {
+	Rules []Rule
+	// BatchSize is the number of rows deleted per statement, or per symbol and transaction when downsampling.
+	BatchSize int
+	// PartitionsAhead is the number of future monthly partitions kept ready.
+	PartitionsAhead int
+}
+
+// RuleReport describes what a single rule pruned.
+type RuleReport struct {
+	Rule
+	Cutoff      time.Time
+	Downsampled int64
+	Deleted     int64
+}
+
+// Report describes what a maintenance run changed.
+type Report struct {
+	Rules             []RuleReport
+	PartitionsCreated []string
+	PartitionsDropped []string
+}
+
+type service struct {
+	repo   maintenanceRepo
+	config Config
+	now    func() time.Time
+}
+
+func NewService(repo maintenanceRepo, cfg Config) *service {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	if cfg.PartitionsAhead <= 0 {
+		cfg.PartitionsAhead = defaultPartitionsAhead
+	}
+
+	// Finer intervals first, so their rows are rolled up before the coarser rules prune the result.
+	cfg.Rules = slices.Clone(cfg.Rules)
+	slices.SortFunc(cfg.Rules, func(a, b Rule) int {
+		da, _ := models.ParseInterval(a.Interval)
+		db, _ := models.ParseInterval(b.Interval)
+
+		return int(da - db)
+	})
+
+	return &service{
+		repo:   repo,
+		config: cfg,
+		now:    time.Now,
+	}
+}
+
+// Start runs the maintenance job immediately and then at every tick until ctx is done.
+func (s *service) Start(ctx context.Context, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.Run(ctx)
+		if err != nil {
+			logger.Error("maintenance run failed", logging.Err(err))
+		}
+
+		LogReport(report)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run applies partition management and every retention rule once.
+func (s *service) Run(ctx context.Context) (Report, error) {
+	var report Report
+
+	now := s.now().UTC()
+
+	created, err := s.ensurePartitions(ctx, now)
+	report.PartitionsCreated = created
+
+	if err != nil {
+		return report, err
+	}
+
+	for _, rule := range s.config.Rules {
+		ruleReport, err := s.applyRule(ctx, rule, now)
+		report.Rules = append(report.Rules, ruleReport)
+
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if before, ok := s.partitionsExpiry(now); ok {
+		report.PartitionsDropped, err = s.repo.DropPartitionsBefore(ctx, before)
+	}
+
+	return report, err
+}
+
+func (s *service) applyRule(ctx context.Context, rule Rule, now time.Time) (RuleReport, error) {
+	var err error
+
+	report := RuleReport{Rule: rule, Cutoff: rule.cutoff(now)}
+
+	if rule.DownsampleTo != "" {
+		return s.downsample(ctx, report)
+	}
+
+	report.Deleted, err = s.repo.DeleteBefore(ctx, rule.Interval, report.Cutoff, s.config.BatchSize)
+
+	return report, err
+}
+
+// downsample rolls the rows of a rule up and deletes them, oldest first, in windows of whole target buckets
+// holding about BatchSize rows per symbol. Each window is rolled up and deleted at once, so a run stopping
+// midway leaves no bucket half deleted for the next run to roll up again.
+func (s *service) downsample(ctx context.Context, report RuleReport) (RuleReport, error) {
+	source, _ := models.ParseInterval(report.Interval)
+	target, _ := models.ParseInterval(report.DownsampleTo)
+	window := target * time.Duration(max(1, s.config.BatchSize/int(target/source)))
+
+	for {
+		oldest, err := s.repo.OldestTimestamp(ctx, report.Interval)
+		if err != nil || oldest.IsZero() || !oldest.Before(report.Cutoff) {
+			return report, err
+		}
+
+		before := oldest.UTC().Truncate(target).Add(window)
+		if before.After(report.Cutoff) {
+			before = report.Cutoff
+		}
+
+		downsampled, deleted, err := s.repo.Downsample(ctx, report.Interval, report.DownsampleTo, before)
+		report.Downsampled += downsampled
+		report.Deleted += deleted
+
+		if err != nil || deleted == 0 {
+			return report, err
+		}
+	}
+}
+
+// ensurePartitions makes sure downsampled rows always have a partition to land in: from the oldest row
+// that may be rolled up until PartitionsAhead months from now.
+func (s *service) ensurePartitions(ctx context.Context, now time.Time) ([]string, error) {
+	from := now
+
+	for _, rule := range s.config.Rules {
+		if rule.DownsampleTo == "" {
+			continue
+		}
+
+		oldest, err := s.repo.OldestTimestamp(ctx, rule.Interval)
+		if err != nil {
+			return nil, err
+		}
+
+		if !oldest.IsZero() && oldest.Before(from) {
+			from = oldest
+		}
+	}
+
+	return s.repo.EnsurePartitions(ctx, from, now.AddDate(0, s.config.PartitionsAhead+1, 0))
+}
+
+// partitionsExpiry returns the time before which whole partitions can be dropped. Partitions hold every
+// downsampled interval, so that is only possible when each of them has a retention rule, and only past
+// the longest of those retentions.
+func (s *service) partitionsExpiry(now time.Time) (time.Time, bool) {
+	var expiry time.Time
+
+	for _, rule := range s.config.Rules {
+		if rule.DownsampleTo == "" {
+			continue
+		}
+
+		idx := slices.IndexFunc(s.config.Rules, func(r Rule) bool { return r.Interval == rule.DownsampleTo })
+		if idx < 0 {
+			return time.Time{}, false
+		}
+
+		if cutoff := s.config.Rules[idx].cutoff(now); expiry.IsZero() || cutoff.Before(expiry) {
+			expiry = cutoff
+		}
+	}
+
+	return expiry, !expiry.IsZero()
+}
+
+// LogReport logs what a maintenance run pruned.
+func LogReport(report Report) {
+	for _, rule := range report.Rules {
+		if rule.DownsampleTo != "" {
+			logger.Info("downsampled candles", logging.KeyInterval, rule.Interval, "before", rule.Cutoff,
+				"downsampled", rule.Downsampled, "downsample_to", rule.DownsampleTo)
+		}
+
+		logger.Info("deleted candles", logging.KeyInterval, rule.Interval, "before", rule.Cutoff,
+			"deleted", rule.Deleted)
+	}
+
+	for _, name := range report.PartitionsCreated {
+		logger.Info("created partition", "partition", name)
+	}
+
+	for _, name := range report.PartitionsDropped {
+		logger.Info("dropped partition", "partition", name)
+	}
+}
+
+// String summarizes the report in one line.
+func (r Report) String() string {
+	var downsampled, deleted int64
+
+	for _, rule := range r.Rules {
+		downsampled += rule.Downsampled
+		deleted += rule.Deleted
+	}
+
+	return fmt.Sprintf("downsampled %d, deleted %d, partitions created %d, dropped %d",
+		downsampled, deleted, len(r.PartitionsCreated), len(r.PartitionsDropped))
+}
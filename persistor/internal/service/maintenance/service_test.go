@@ -0,0 +1,174 @@
+package maintenance_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	maintenancesvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/maintenance"
+)
+
+type fakeRepo struct {
+	calls      []string
+	cutoffs    map[string]time.Time
+	dropBefore time.Time
+	// oldest is the timestamp of the oldest row by interval, rows are gone once it is zero.
+	oldest map[string]time.Time
+	// downsampled are the before of every Downsample call.
+	downsampled []time.Time
+}
+
+func (r *fakeRepo) OldestTimestamp(_ context.Context, interval string) (time.Time, error) {
+	return r.oldest[interval], nil
+}
+
+func (r *fakeRepo) Downsample(_ context.Context, source, target string, before time.Time) (int64, int64, error) {
+	r.calls = append(r.calls, fmt.Sprintf("downsample %s>%s", source, target))
+	r.cutoffs[source] = before
+	r.downsampled = append(r.downsampled, before)
+
+	if r.oldest[source].Before(before) {
+		r.oldest[source] = before
+	}
+
+	return 1, 2, nil
+}
+
+func (r *fakeRepo) DeleteBefore(_ context.Context, interval string, before time.Time, _ int) (int64, error) {
+	r.calls = append(r.calls, "delete "+interval)
+	r.cutoffs[interval] = before
+
+	return 2, nil
+}
+
+func (r *fakeRepo) EnsurePartitions(_ context.Context, _, _ time.Time) ([]string, error) {
+	r.calls = append(r.calls, "ensure partitions")
+
+	return nil, nil
+}
+
+func (r *fakeRepo) DropPartitionsBefore(_ context.Context, before time.Time) ([]string, error) {
+	r.calls = append(r.calls, "drop partitions")
+	r.dropBefore = before
+
+	return nil, nil
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := maintenancesvc.ParseRules([]string{"1m:90d:1h", "1h:43800h"})
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	want := []maintenancesvc.Rule{
+		{Interval: "1m", Retention: 90 * 24 * time.Hour, DownsampleTo: "1h"},
+		{Interval: "1h", Retention: 43800 * time.Hour},
+	}
+
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("parsed rules are incorrect. \ngot: %#v \nwant: %#v", rules, want)
+	}
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	specs := map[string][]string{
+		"missing retention":      {"1m"},
+		"unknown interval":       {"7m:90d"},
+		"negative retention":     {"1m:-1d"},
+		"finer downsample":       {"1h:90d:1m"},
+		"non multiple":           {"2h:90d:3m"},
+		"duplicate interval":     {"1m:90d", "1m:30d"},
+		"malformed day duration": {"1m:ninetyd"},
+	}
+
+	for name, spec := range specs {
+		if _, err := maintenancesvc.ParseRules(spec); err == nil {
+			t.Errorf("%s: expected an error for %q", name, spec)
+		}
+	}
+}
+
+func TestService_Run(t *testing.T) {
+	// The oldest 1m row is within a day of the cutoff, so it is rolled up in one window.
+	repo := &fakeRepo{cutoffs: make(map[string]time.Time),
+		oldest: map[string]time.Time{"1m": time.Now().Add(-91 * 24 * time.Hour)}}
+	rules := []maintenancesvc.Rule{
+		{Interval: "1h", Retention: 5 * 365 * 24 * time.Hour},
+		{Interval: "1m", Retention: 90 * 24 * time.Hour, DownsampleTo: "1h"},
+	}
+	svc := maintenancesvc.NewService(repo, maintenancesvc.Config{Rules: rules})
+
+	before := time.Now()
+
+	report, err := svc.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	wantCalls := []string{"ensure partitions", "downsample 1m>1h", "delete 1h", "drop partitions"}
+	if !reflect.DeepEqual(repo.calls, wantCalls) {
+		t.Errorf("unexpected call order. \ngot: %v \nwant: %v", repo.calls, wantCalls)
+	}
+
+	cutoff := repo.cutoffs["1m"]
+	if !cutoff.Equal(cutoff.Truncate(time.Hour)) || cutoff.After(before.Add(-90*24*time.Hour)) {
+		t.Errorf("1m cutoff %v should be aligned to the hour and at least 90 days old", cutoff)
+	}
+
+	if !repo.dropBefore.Equal(repo.cutoffs["1h"]) {
+		t.Errorf("partitions should expire with the 1h rule: got %v, want %v", repo.dropBefore, repo.cutoffs["1h"])
+	}
+
+	if got := report.String(); got != "downsampled 1, deleted 4, partitions created 0, dropped 0" {
+		t.Errorf("unexpected report summary: %s", got)
+	}
+}
+
+func TestService_Run_DownsamplesInWindows(t *testing.T) {
+	now := time.Now().UTC()
+	repo := &fakeRepo{cutoffs: make(map[string]time.Time),
+		oldest: map[string]time.Time{"1m": now.Add(-95*24*time.Hour - 90*time.Second)}}
+	svc := maintenancesvc.NewService(repo, maintenancesvc.Config{
+		Rules: []maintenancesvc.Rule{{Interval: "1m", Retention: 90 * 24 * time.Hour, DownsampleTo: "1h"}},
+		// A day of 1m candles per window.
+		BatchSize: 24 * 60,
+	})
+
+	report, err := svc.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if n := len(repo.downsampled); n != 5 && n != 6 {
+		t.Fatalf("expected 5 days rolled up a day at a time, got %d windows: %v", n, repo.downsampled)
+	}
+
+	for i, before := range repo.downsampled {
+		if !before.Equal(before.Truncate(time.Hour)) {
+			t.Errorf("window %d ends at %v, not at a whole hour", i, before)
+		}
+	}
+
+	if last := repo.downsampled[len(repo.downsampled)-1]; !last.Equal(report.Rules[0].Cutoff) {
+		t.Errorf("expected the last window to end at the cutoff %v, got %v", report.Rules[0].Cutoff, last)
+	}
+}
+
+func TestService_Run_KeepsPartitionsWithoutTargetRule(t *testing.T) {
+	repo := &fakeRepo{cutoffs: make(map[string]time.Time)}
+	svc := maintenancesvc.NewService(repo, maintenancesvc.Config{
+		Rules: []maintenancesvc.Rule{{Interval: "1m", Retention: 24 * time.Hour, DownsampleTo: "1d"}},
+	})
+
+	if _, err := svc.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	for _, call := range repo.calls {
+		if call == "drop partitions" {
+			t.Errorf("partitions must be kept when the downsampled interval has no retention rule")
+		}
+	}
+}
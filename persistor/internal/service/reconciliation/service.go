@@ -0,0 +1,254 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+)
+
+type klinesClient interface {
+	Klines(ctx context.Context, symbol, interval string, start, end time.Time, limit int) ([]binance.Kline, error)
+}
+
+type candleRepo interface {
+	ListTicks(ctx context.Context, symbol string, from, to time.Time, limit int) ([]models.AggTradeTick, error)
+	SaveTick(ctx context.Context, tick models.AggTradeTick) error
+	DeleteTick(ctx context.Context, symbol string, timestamp time.Time) error
+}
+
+type discrepancyRepo interface {
+	SaveDiscrepancies(ctx context.Context, discrepancies []models.CandleDiscrepancy) error
+}
+
+// Tolerance holds the relative differences accepted between our candles and the exchange's.
+type Tolerance struct {
+	Price  float64
+	Volume float64
+}
+
+// Request selects the 1m candles of a symbol in [From, To) to reconcile.
+type Request struct {
+	Symbol string
+	From   time.Time
+	To     time.Time
+	// Repair overwrites our candles with the exchange's where they differ, and deletes the candles the exchange
+	// doesn't have.
+	Repair bool
+}
+
+// Result summarizes a reconciliation run.
+type Result struct {
+	Symbol        string
+	Compared      int
+	Discrepancies []models.CandleDiscrepancy
+	// Unexpected counts our candles the exchange doesn't have, among the discrepancies.
+	Unexpected int
+	Repaired   int
+}
+
+type service struct {
+	client          klinesClient
+	candleRepo      candleRepo
+	discrepancyRepo discrepancyRepo
+	tolerance       Tolerance
+}
+
+func NewService(client klinesClient, candles candleRepo, discrepancies discrepancyRepo,
+	tolerance Tolerance) *service {
+	return &service{
+		client:          client,
+		candleRepo:      candles,
+		discrepancyRepo: discrepancies,
+		tolerance:       tolerance,
+	}
+}
+
+// Reconcile compares our 1m candles with the exchange's klines page by page, records the discrepancies
+// and optionally repairs our candles, deleting the ones the exchange doesn't have. The still forming minute is
+// never compared.
+func (s *service) Reconcile(ctx context.Context, req Request) (Result, error) {
+	result := Result{Symbol: strings.ToUpper(req.Symbol)}
+	from := req.From.UTC().Truncate(time.Minute)
+	to := req.To.UTC()
+
+	if now := time.Now().UTC().Truncate(time.Minute); to.IsZero() || to.After(now) {
+		to = now
+	}
+
+	if result.Symbol == "" || !from.Before(to) {
+		return result, errors.New("a symbol and a non empty time range are required")
+	}
+
+	for from.Before(to) {
+		klines, err := s.client.Klines(ctx, result.Symbol, models.BaseInterval, from, to.Add(-time.Millisecond),
+			binance.MaxKlinesLimit)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch klines: %w", err)
+		}
+
+		pageEnd := to
+		if len(klines) == binance.MaxKlinesLimit {
+			pageEnd = klines[len(klines)-1].OpenTime.Add(time.Minute)
+		}
+
+		ticks, err := s.candleRepo.ListTicks(ctx, result.Symbol, from, pageEnd, int(pageEnd.Sub(from)/time.Minute))
+		if err != nil {
+			return result, err
+		}
+
+		discrepancies := Compare(result.Symbol, klines, ticks, s.tolerance)
+		result.Compared += len(klines)
+
+		if req.Repair {
+			repaired, err := s.repair(ctx, result.Symbol, klines, discrepancies)
+			result.Repaired += repaired
+
+			if err != nil {
+				return result, err
+			}
+		}
+
+		if err := s.discrepancyRepo.SaveDiscrepancies(ctx, discrepancies); err != nil {
+			return result, err
+		}
+
+		for _, discrepancy := range discrepancies {
+			if discrepancy.Field == models.DiscrepancyUnexpected {
+				result.Unexpected++
+			}
+		}
+
+		result.Discrepancies = append(result.Discrepancies, discrepancies...)
+		from = pageEnd
+	}
+
+	return result, nil
+}
+
+// repair overwrites our candles that have discrepancies with the exchange's kline and deletes the ones it doesn't
+// have, marking them repaired.
+func (s *service) repair(ctx context.Context, symbol string, klines []binance.Kline,
+	discrepancies []models.CandleDiscrepancy) (int, error) {
+	byTime := make(map[int64]binance.Kline, len(klines))
+	for _, kline := range klines {
+		byTime[kline.OpenTime.UnixMilli()] = kline
+	}
+
+	repaired := make(map[int64]bool)
+
+	for i := range discrepancies {
+		key := discrepancies[i].Timestamp.UnixMilli()
+
+		if discrepancies[i].Field == models.DiscrepancyUnexpected {
+			if err := s.candleRepo.DeleteTick(ctx, symbol, discrepancies[i].Timestamp); err != nil {
+				return len(repaired), fmt.Errorf("failed to delete candle %s: %w", discrepancies[i].Timestamp, err)
+			}
+
+			repaired[key] = true
+			discrepancies[i].Repaired = true
+
+			continue
+		}
+
+		kline, ok := byTime[key]
+		if !ok {
+			continue
+		}
+
+		if !repaired[key] {
+			if err := s.candleRepo.SaveTick(ctx, models.AggTradeTick{
+				Symbol:    symbol,
+				Timestamp: kline.OpenTime,
+				Open:      kline.Open,
+				High:      kline.High,
+				Low:       kline.Low,
+				Close:     kline.Close,
+				Volume:    kline.Volume,
+			}); err != nil {
+				return len(repaired), fmt.Errorf("failed to repair candle %s: %w", kline.OpenTime, err)
+			}
+
+			repaired[key] = true
+		}
+
+		discrepancies[i].Repaired = true
+	}
+
+	return len(repaired), nil
+}
+
+// Compare returns a discrepancy for every field of our candles that differs from the exchange's klines beyond
+// the tolerance, for every kline we don't have and every candle the exchange doesn't have.
+func Compare(symbol string, klines []binance.Kline, ticks []models.AggTradeTick,
+	tolerance Tolerance) []models.CandleDiscrepancy {
+	now := time.Now().UTC()
+	ours := make(map[int64]models.AggTradeTick, len(ticks))
+
+	for _, tick := range ticks {
+		ours[tick.Timestamp.UnixMilli()] = tick
+	}
+
+	var discrepancies []models.CandleDiscrepancy
+
+	for _, kline := range klines {
+		key := kline.OpenTime.UnixMilli()
+
+		tick, ok := ours[key]
+		if !ok {
+			discrepancies = append(discrepancies, models.CandleDiscrepancy{
+				Symbol: symbol, Timestamp: kline.OpenTime, Field: models.DiscrepancyMissing, DetectedAt: now,
+			})
+
+			continue
+		}
+
+		delete(ours, key)
+
+		for _, field := range []struct {
+			name             string
+			expected, actual float64
+			tolerance        float64
+		}{
+			{"open", kline.Open, tick.Open, tolerance.Price},
+			{"high", kline.High, tick.High, tolerance.Price},
+			{"low", kline.Low, tick.Low, tolerance.Price},
+			{"close", kline.Close, tick.Close, tolerance.Price},
+			{"volume", kline.Volume, tick.Volume, tolerance.Volume},
+		} {
+			if within(field.expected, field.actual, field.tolerance) {
+				continue
+			}
+
+			discrepancies = append(discrepancies, models.CandleDiscrepancy{
+				Symbol:     symbol,
+				Timestamp:  kline.OpenTime,
+				Field:      field.name,
+				Expected:   &field.expected,
+				Actual:     &field.actual,
+				DetectedAt: now,
+			})
+		}
+	}
+
+	for _, tick := range ticks {
+		if _, ok := ours[tick.Timestamp.UnixMilli()]; !ok {
+			continue
+		}
+
+		discrepancies = append(discrepancies, models.CandleDiscrepancy{
+			Symbol: symbol, Timestamp: tick.Timestamp.UTC(), Field: models.DiscrepancyUnexpected, DetectedAt: now,
+		})
+	}
+
+	return discrepancies
+}
+
+func within(expected, actual, tolerance float64) bool {
+	return math.Abs(expected-actual) <= tolerance*math.Max(math.Abs(expected), math.Abs(actual))
+}
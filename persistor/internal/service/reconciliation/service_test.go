@@ -0,0 +1,156 @@
+package reconciliation_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/clients/binance"
+	"github.com/majidmvulle/binance-trading-chart-service/persistor/internal/models"
+	reconciliationsvc "github.com/majidmvulle/binance-trading-chart-service/persistor/internal/service/reconciliation"
+)
+
+var start = time.Date(2025, time.January, 27, 10, 30, 0, 0, time.UTC)
+
+type fakeClient struct {
+	klines []binance.Kline
+}
+
+func (c *fakeClient) Klines(_ context.Context, _, _ string, from, to time.Time, limit int) ([]binance.Kline, error) {
+	var klines []binance.Kline
+
+	for _, kline := range c.klines {
+		if !kline.OpenTime.Before(from) && !kline.OpenTime.After(to) && len(klines) < limit {
+			klines = append(klines, kline)
+		}
+	}
+
+	return klines, nil
+}
+
+type fakeRepo struct {
+	ticks         map[time.Time]models.AggTradeTick
+	discrepancies []models.CandleDiscrepancy
+}
+
+func (r *fakeRepo) ListTicks(_ context.Context, _ string, from, to time.Time, _ int) ([]models.AggTradeTick, error) {
+	var ticks []models.AggTradeTick
+
+	for ts := from; ts.Before(to); ts = ts.Add(time.Minute) {
+		if tick, ok := r.ticks[ts]; ok {
+			ticks = append(ticks, tick)
+		}
+	}
+
+	return ticks, nil
+}
+
+func (r *fakeRepo) SaveTick(_ context.Context, tick models.AggTradeTick) error {
+	r.ticks[tick.Timestamp] = tick
+
+	return nil
+}
+
+func (r *fakeRepo) DeleteTick(_ context.Context, _ string, timestamp time.Time) error {
+	delete(r.ticks, timestamp)
+
+	return nil
+}
+
+func (r *fakeRepo) SaveDiscrepancies(_ context.Context, discrepancies []models.CandleDiscrepancy) error {
+	r.discrepancies = append(r.discrepancies, discrepancies...)
+
+	return nil
+}
+
+func kline(minute int, closePrice, volume float64) binance.Kline {
+	return binance.Kline{
+		OpenTime: start.Add(time.Duration(minute) * time.Minute),
+		Open:     100, High: 102, Low: 99, Close: closePrice, Volume: volume,
+	}
+}
+
+func tick(minute int, closePrice, volume float64) models.AggTradeTick {
+	return models.AggTradeTick{
+		Symbol:    "BTCUSDT",
+		Timestamp: start.Add(time.Duration(minute) * time.Minute),
+		Open:      100, High: 102, Low: 99, Close: closePrice, Volume: volume,
+	}
+}
+
+func TestCompare(t *testing.T) {
+	klines := []binance.Kline{kline(0, 101, 10), kline(1, 101, 10), kline(2, 101, 10)}
+	ticks := []models.AggTradeTick{tick(0, 101.0000001, 10.001), tick(1, 100, 10), tick(3, 101, 1)}
+
+	discrepancies := reconciliationsvc.Compare("BTCUSDT", klines, ticks,
+		reconciliationsvc.Tolerance{Price: 1e-6, Volume: 1e-3})
+
+	want := []struct {
+		minute int
+		field  string
+	}{
+		{1, "close"},
+		{2, models.DiscrepancyMissing},
+		{3, models.DiscrepancyUnexpected},
+	}
+
+	if len(discrepancies) != len(want) {
+		t.Fatalf("expected %d discrepancies, got %d: %+v", len(want), len(discrepancies), discrepancies)
+	}
+
+	for i, w := range want {
+		got := discrepancies[i]
+		if got.Field != w.field || !got.Timestamp.Equal(start.Add(time.Duration(w.minute)*time.Minute)) {
+			t.Errorf("discrepancy %d: got %s at %v, want %s at minute %d", i, got.Field, got.Timestamp, w.field,
+				w.minute)
+		}
+	}
+
+	if *discrepancies[0].Expected != 101 || *discrepancies[0].Actual != 100 {
+		t.Errorf("close values mismatch: got expected %.2f actual %.2f", *discrepancies[0].Expected,
+			*discrepancies[0].Actual)
+	}
+}
+
+func TestService_Reconcile_Repair(t *testing.T) {
+	repo := &fakeRepo{ticks: map[time.Time]models.AggTradeTick{
+		start:                      tick(0, 101, 10),
+		start.Add(time.Minute):     tick(1, 100, 10),
+		start.Add(3 * time.Minute): tick(3, 101, 1),
+	}}
+	client := &fakeClient{klines: []binance.Kline{kline(0, 101, 10), kline(1, 101, 10), kline(2, 101, 10)}}
+	svc := reconciliationsvc.NewService(client, repo, repo, reconciliationsvc.Tolerance{Price: 1e-6, Volume: 1e-3})
+
+	result, err := svc.Reconcile(context.Background(), reconciliationsvc.Request{
+		Symbol: "btcusdt",
+		From:   start,
+		To:     start.Add(4 * time.Minute),
+		Repair: true,
+	})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if result.Compared != 3 || result.Repaired != 3 || result.Unexpected != 1 || len(repo.discrepancies) != 3 {
+		t.Fatalf("expected 3 compared, 3 repaired, 1 unexpected and 3 recorded, got %d, %d, %d and %d",
+			result.Compared, result.Repaired, result.Unexpected, len(repo.discrepancies))
+	}
+
+	for _, discrepancy := range repo.discrepancies {
+		if !discrepancy.Repaired {
+			t.Errorf("discrepancy %+v should be marked repaired", discrepancy)
+		}
+	}
+
+	if got := repo.ticks[start.Add(time.Minute)].Close; got != 101 {
+		t.Errorf("close price not repaired: got %.2f, want %.2f", got, 101.0)
+	}
+
+	if _, ok := repo.ticks[start.Add(3*time.Minute)]; ok {
+		t.Error("expected the candle the exchange doesn't have to be deleted")
+	}
+
+	if _, ok := repo.ticks[start.Add(2*time.Minute)]; !ok {
+		t.Error("missing candle not repaired")
+	}
+}